@@ -1,13 +1,19 @@
 package api
 
 import (
+	"context"
+	"medical-qa-assistant/internal/blobstore"
 	"medical-qa-assistant/internal/config"
 	"medical-qa-assistant/internal/handlers"
+	"medical-qa-assistant/internal/logger"
 	"medical-qa-assistant/internal/middleware"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/phi"
 	"medical-qa-assistant/internal/repositories"
 	"medical-qa-assistant/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -19,7 +25,7 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -32,9 +38,21 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	// 初始化仓储层
 	userRepo := repositories.NewUserRepository(db)
 	documentRepo := repositories.NewDocumentRepository(db)
+	indexJobRepo := repositories.NewIndexJobRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	kbRepo := repositories.NewKnowledgeBaseRepository(db)
+	batchRepo := repositories.NewUploadBatchRepository(db)
+	versionRepo := repositories.NewDocumentVersionRepository(db)
+	redactionRepo := repositories.NewDocumentRedactionRepository(db)
+
+	// 文档检索优先使用 ngram 全文索引，数据库不支持时退回 LIKE
+	if err := documentRepo.EnsureFullTextIndex(); err != nil {
+		logger.L.Warn("fulltext index unavailable, document search falls back to LIKE", zap.Error(err))
+	}
 
 	// 初始化服务层
 	authService := services.NewAuthService(userRepo, cfg.JWTSecret)
+	kbService := services.NewKnowledgeBaseService(kbRepo)
 
 	// RAG 嵌入向量：始终使用 OpenAI 作为嵌入提供方，无论 LLM_PROVIDER 如何设置
 	// 问答（对话）仍可通过 LLM_PROVIDER 在 OpenAI 和 DeepSeek 之间切换
@@ -45,20 +63,84 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 		cfg.ChromaBaseURL,
 		cfg.ChromaCollection,
 	)
-	documentService := services.NewDocumentService(documentRepo, ragService)
 
+	// 文档索引由后台 worker 池异步执行，启动时会恢复未完成的任务；
+	// 状态变化通过事件中心推送给订阅的 SSE 连接
+	documentEvents := services.NewDocumentEventHub()
+	indexWorker := services.NewIndexWorker(
+		indexJobRepo,
+		documentRepo,
+		ragService,
+		documentEvents,
+		cfg.IndexWorkers,
+		cfg.IndexMaxAttempts,
+		cfg.IndexPollInterval,
+		cfg.IndexRetryBackoff,
+	)
+	indexWorker.Start(context.Background())
+
+	// 文档写入通过 outbox 事件驱动向量库变更，分发器保证 MySQL 与 Chroma 最终一致
+	outboxDispatcher := services.NewOutboxDispatcher(
+		outboxRepo,
+		documentRepo,
+		ragService,
+		indexWorker,
+		cfg.OutboxMaxAttempts,
+		cfg.IndexPollInterval,
+		cfg.IndexRetryBackoff,
+	)
+	outboxDispatcher.Start(context.Background())
+
+	// 上传的病历等文档入库前去除患者身份信息，避免发送给第三方嵌入和对话接口
+	redactor, err := phi.NewRedactor(cfg.PHIRedaction, cfg.PHIRedactionKinds)
+	if err != nil {
+		logger.L.Fatal("invalid PHI redaction config", zap.Error(err))
+	}
+	documentService := services.NewDocumentService(documentRepo, versionRepo, redactionRepo, outboxRepo, kbRepo, ragService, outboxDispatcher, documentEvents, services.DuplicateOptions{
+		Policy:        cfg.DuplicatePolicy,
+		NearThreshold: cfg.NearDuplicateThreshold,
+	}, newBlobStore(cfg), redactor)
+	batchService := services.NewUploadBatchService(batchRepo, documentRepo, kbRepo, documentService)
+	// 为早期文档补算内容指纹，供重复检测使用
+	go documentService.BackfillFingerprints()
+
+	// MySQL 与 Chroma 的对账，可定时执行，也可由管理员手动触发
+	reconcileService := services.NewReconcileService(documentRepo, ragService, indexWorker)
+	reconcileService.Start(context.Background(), cfg.ReconcileInterval, cfg.ReconcileRepair)
+
+	qaOptions := services.QAOptions{
+		FullDocumentMaxChars:  cfg.FullDocumentMaxChars,
+		SupersededPolicy:      cfg.SupersededPolicy,
+		StaleDocumentAgeYears: cfg.StaleDocumentAgeYears,
+		QuestionPHIPolicy:     cfg.QuestionPHIPolicy,
+	}
+	// 提问中的身份信息替换为编号占位符，同一对话内保持一致；识别的类别与文档相同
+	questionRedactor, err := phi.NewRedactor(phi.ModePseudonymize, cfg.PHIRedactionKinds)
+	if err != nil {
+		logger.L.Fatal("invalid PHI redaction config", zap.Error(err))
+	}
 	var qaService *services.QAService
 	switch cfg.LLMProvider {
 	case "deepseek":
-		qaService = services.NewQAService(cfg.DeepSeekKey, cfg.DeepSeekModel, cfg.DeepSeekBaseURL, ragService)
+		qaService = services.NewQAService(cfg.DeepSeekKey, cfg.DeepSeekModel, cfg.DeepSeekBaseURL, ragService, kbRepo, documentRepo, questionRedactor, qaOptions)
 	default:
-		qaService = services.NewQAService(cfg.OpenAIKey, cfg.OpenAIModel, cfg.OpenAIBaseURL, ragService)
+		qaService = services.NewQAService(cfg.OpenAIKey, cfg.OpenAIModel, cfg.OpenAIBaseURL, ragService, kbRepo, documentRepo, questionRedactor, qaOptions)
 	}
 
+	searchService := services.NewSearchService(ragService, documentRepo, kbRepo)
+
 	// 初始化处理器
 	authHandler := handlers.NewAuthHandler(authService)
-	documentHandler := handlers.NewDocumentHandler(documentService)
+	documentHandler := handlers.NewDocumentHandler(documentService, batchService, handlers.UploadLimits{
+		MaxFileBytes:    cfg.UploadMaxFileBytes,
+		MaxRequestBytes: cfg.UploadMaxRequestBytes,
+		MaxBatchBytes:   cfg.UploadMaxBatchBytes,
+		MaxBatchFiles:   cfg.UploadMaxBatchFiles,
+	})
 	qaHandler := handlers.NewQAHandler(qaService)
+	adminHandler := handlers.NewAdminHandler(reconcileService, documentService)
+	kbHandler := handlers.NewKnowledgeBaseHandler(kbService)
+	searchHandler := handlers.NewSearchHandler(searchService)
 
 	// 公开路由
 	api := router.Group("/api/v1")
@@ -72,12 +154,66 @@ func SetupRoutes(db *gorm.DB, cfg *config.Config) *gin.Engine {
 	{
 		protected.POST("/documents", documentHandler.Create)
 		protected.POST("/documents/upload", documentHandler.Upload)
+		protected.POST("/documents/batches", documentHandler.BatchUpload)
+		protected.GET("/documents/batches/:id", documentHandler.BatchProgress)
 		protected.GET("/documents", documentHandler.List)
+		protected.GET("/documents/events", documentHandler.Events)
+		protected.GET("/documents/duplicates", documentHandler.Duplicates)
+		protected.GET("/documents/search", documentHandler.Search)
 		protected.GET("/documents/:id", documentHandler.Get)
+		protected.PUT("/documents/:id", documentHandler.Update)
+		protected.GET("/documents/:id/file", documentHandler.DownloadFile)
+		protected.GET("/documents/:id/chunks", documentHandler.Chunks)
+		protected.GET("/documents/:id/redactions", documentHandler.ListRedactions)
+		protected.GET("/documents/:id/versions", documentHandler.ListVersions)
+		protected.GET("/documents/:id/versions/diff", documentHandler.DiffVersions)
+		protected.GET("/documents/:id/versions/:version", documentHandler.GetVersion)
+		protected.POST("/documents/:id/versions/:version/restore", documentHandler.RestoreVersion)
+		protected.PATCH("/documents/:id/metadata", documentHandler.UpdateMetadata)
 		protected.DELETE("/documents/:id", documentHandler.Delete)
+		protected.POST("/knowledge-bases", kbHandler.Create)
+		protected.GET("/knowledge-bases", kbHandler.List)
+		protected.GET("/knowledge-bases/:id", kbHandler.Get)
+		protected.PUT("/knowledge-bases/:id", kbHandler.Update)
+		protected.DELETE("/knowledge-bases/:id", kbHandler.Delete)
 		protected.POST("/qa/ask", qaHandler.Ask)
 		protected.POST("/qa/ask/stream", qaHandler.AskStream)
+		protected.POST("/search", searchHandler.Search)
+	}
+
+	admin := protected.Group("/admin")
+	admin.Use(middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.POST("/reconcile", adminHandler.Reconcile)
+		admin.GET("/documents/review-due", adminHandler.ReviewDue)
 	}
 
 	return router
 }
+
+// newBlobStore 按配置创建原始文件存储，BLOB_STORE=none 时返回 nil，上传只保存提取的文本
+func newBlobStore(cfg *config.Config) blobstore.BlobStore {
+	switch cfg.BlobStore {
+	case "none":
+		return nil
+	case "s3":
+		store, err := blobstore.NewS3Store(blobstore.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			logger.L.Fatal("failed to init s3 blob store", zap.Error(err))
+		}
+		return store
+	default:
+		store, err := blobstore.NewLocalStore(cfg.BlobLocalDir)
+		if err != nil {
+			logger.L.Fatal("failed to init local blob store", zap.Error(err), zap.String("dir", cfg.BlobLocalDir))
+		}
+		return store
+	}
+}
@@ -42,7 +42,7 @@ func main() {
 	}
 
 	// 自动迁移（文档块和向量存储在 Chroma 中，不在 MySQL）
-	if err := db.AutoMigrate(&models.User{}, &models.Document{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Document{}, &models.DocumentVersion{}, &models.DocumentRedaction{}, &models.IndexJob{}, &models.OutboxEvent{}, &models.KnowledgeBase{}, &models.UploadBatch{}, &models.UploadBatchItem{}); err != nil {
 		logger.L.Fatal("failed to migrate database", zap.Error(err))
 	}
 
@@ -0,0 +1,38 @@
+// Package blobstore 保存上传的原始文件（PDF、DOCX 等），文档表中只记录对象的键
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrNotFound 表示对象不存在
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey 表示对象键为空、是绝对路径或包含 ".."
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// BlobStore 是原始文件的存储后端
+type BlobStore interface {
+	// Put 写入对象，已存在时覆盖
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get 打开对象，调用方负责关闭；对象不存在时返回 ErrNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// validKey 检查对象键是形如 "documents/1/abc" 的相对路径
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"documents/1/abc", true},
+		{"a", true},
+		{"a/.hidden", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"../secret", false},
+		{"documents/../../secret", false},
+		{"documents/..", false},
+		{"documents/./abc", false},
+		{"documents//abc", false},
+		{"documents/", false},
+		{`documents\..\secret`, false},
+	}
+	for _, tt := range tests {
+		if got := validKey(tt.key); got != tt.want {
+			t.Errorf("validKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(root, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "documents/1/abc", []byte("hello"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := store.Get(ctx, "documents/1/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get = %q", data)
+	}
+
+	// 覆盖写入后不留下临时文件
+	if err := store.Put(ctx, "documents/1/abc", []byte("world"), ""); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "blobs", "documents", "1"))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want 1", len(entries))
+	}
+
+	if err := store.Delete(ctx, "documents/1/abc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "documents/1/abc"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "documents/1/abc"); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+
+	for _, key := range []string{"../outside", "/abs/path", "documents/../../outside"} {
+		if err := store.Put(ctx, key, []byte("x"), ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Get(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "outside")); !os.IsNotExist(err) {
+		t.Error("write escaped the store root")
+	}
+}
@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -30,6 +33,52 @@ type Config struct {
 	AliyunEmbeddingModel string
 	AliyunEmbeddingKey string
 	AliyunEmbeddingBaseURL string
+
+	// 异步索引配置
+	IndexWorkers      int
+	IndexMaxAttempts  int
+	IndexPollInterval time.Duration
+	IndexRetryBackoff time.Duration
+	OutboxMaxAttempts int
+
+	// MySQL/Chroma 对账配置
+	ReconcileInterval time.Duration
+	ReconcileRepair   bool
+
+	// 问答配置：只选定一篇文档且不超过该字符数时使用全文而非检索
+	FullDocumentMaxChars int
+
+	// 已被取代文档的处理策略（downrank | exclude）及文档过时提醒年限
+	SupersededPolicy      string
+	StaleDocumentAgeYears int
+
+	// 上传大小限制（字节）：单个文件及整个请求体
+	UploadMaxFileBytes    int64
+	UploadMaxRequestBytes int64
+	UploadMaxBatchBytes   int64 // 批量上传的请求体
+	UploadMaxBatchFiles   int   // 批量上传的文件数量
+
+	// 重复文档检测：完全重复的处理策略（reject | warn）及近似重复的相似度阈值
+	DuplicatePolicy        string
+	NearDuplicateThreshold float64
+
+	// 原始文件存储：local 为本地目录，s3 为 S3 兼容服务，none 不保存原始文件
+	BlobStore    string
+	BlobLocalDir string
+	S3Endpoint   string
+	S3Region     string
+	S3Bucket     string
+	S3AccessKey  string
+	S3SecretKey  string
+	S3PathStyle  bool
+
+	// 入库前去除患者身份信息：off、mask（替换为类别标签）或 pseudonymize（替换为编号化名），
+	// PHIRedactionKinds 为空时识别全部类别
+	PHIRedaction      string
+	PHIRedactionKinds []string
+
+	// 提问中身份信息的处理策略：off、mask（替换为占位符，同一对话内一致）或 block（拒绝提问）
+	QuestionPHIPolicy string
 }
 
 func Load() *Config {
@@ -56,6 +105,41 @@ func Load() *Config {
 		AliyunEmbeddingModel: getEnv("ALIYUN_EMBEDDING_MODEL", "text-embedding-v4"),
 		AliyunEmbeddingKey: getEnv("ALIYUN_EMBEDDING_KEY", ""),
 		AliyunEmbeddingBaseURL: getEnv("ALIYUN_EMBEDING_BASEURL", "https://dashscope.aliyuncs.com/compatible-mode/v1"),
+
+		IndexWorkers:      getEnvInt("INDEX_WORKERS", 2),
+		IndexMaxAttempts:  getEnvInt("INDEX_MAX_ATTEMPTS", 5),
+		IndexPollInterval: getEnvDuration("INDEX_POLL_INTERVAL", 5*time.Second),
+		IndexRetryBackoff: getEnvDuration("INDEX_RETRY_BACKOFF", 10*time.Second),
+		OutboxMaxAttempts: getEnvInt("OUTBOX_MAX_ATTEMPTS", 10),
+
+		ReconcileInterval: getEnvDuration("RECONCILE_INTERVAL", 0), // 0 表示不定时执行
+		ReconcileRepair:   getEnvBool("RECONCILE_REPAIR", false),
+
+		FullDocumentMaxChars: getEnvInt("FULL_DOCUMENT_MAX_CHARS", 6000),
+
+		SupersededPolicy:      getEnv("SUPERSEDED_POLICY", "downrank"),
+		StaleDocumentAgeYears: getEnvInt("STALE_DOCUMENT_AGE_YEARS", 5), // 0 表示不提醒
+
+		UploadMaxFileBytes:    int64(getEnvInt("UPLOAD_MAX_FILE_BYTES", 20<<20)),
+		UploadMaxRequestBytes: int64(getEnvInt("UPLOAD_MAX_REQUEST_BYTES", 25<<20)),
+		UploadMaxBatchBytes:   int64(getEnvInt("UPLOAD_MAX_BATCH_BYTES", 200<<20)),
+		UploadMaxBatchFiles:   getEnvInt("UPLOAD_MAX_BATCH_FILES", 500),
+
+		DuplicatePolicy:        getEnv("DUPLICATE_POLICY", "reject"),
+		NearDuplicateThreshold: getEnvFloat("NEAR_DUPLICATE_THRESHOLD", 0.9),
+
+		BlobStore:    getEnv("BLOB_STORE", "local"),
+		BlobLocalDir: getEnv("BLOB_LOCAL_DIR", "./data/blobs"),
+		S3Endpoint:   getEnv("S3_ENDPOINT", ""),
+		S3Region:     getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:     getEnv("S3_BUCKET", ""),
+		S3AccessKey:  getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:  getEnv("S3_SECRET_KEY", ""),
+		S3PathStyle:  getEnvBool("S3_PATH_STYLE", true),
+
+		PHIRedaction:      getEnv("PHI_REDACTION", "mask"),
+		PHIRedactionKinds: getEnvList("PHI_REDACTION_KINDS"),
+		QuestionPHIPolicy: getEnv("QUESTION_PHI_POLICY", "mask"),
 	}
 }
 
@@ -65,3 +149,51 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList 读取逗号分隔的列表，未设置时返回 nil
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration 解析 time.ParseDuration 格式的时长，例如 "5s"、"2m"
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
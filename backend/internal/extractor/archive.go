@@ -0,0 +1,138 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// 压缩包相关错误
+var (
+	// ErrInvalidArchive 表示文件不是有效的 ZIP 压缩包
+	ErrInvalidArchive = errors.New("invalid zip archive")
+	// ErrArchiveTooLarge 表示条目数量或解压后的总大小超过限制
+	ErrArchiveTooLarge = errors.New("zip archive exceeds limits")
+	// ErrSuspiciousArchive 表示压缩比异常（疑似 zip 炸弹）
+	ErrSuspiciousArchive = errors.New("zip archive has a suspicious compression ratio")
+	// ErrUnsafePath 表示条目路径为绝对路径或跳出压缩包根目录
+	ErrUnsafePath = errors.New("unsafe path in zip archive")
+	// ErrEntryTooLarge 表示单个条目解压后超过限制
+	ErrEntryTooLarge = errors.New("zip entry exceeds size limit")
+)
+
+// 解压后小于该大小的条目不检查压缩比，重复性高的小文本压缩比本来就可能很高
+const ratioCheckMinBytes = 1 << 20
+
+// ArchiveLimits 是展开压缩包时的限制
+type ArchiveLimits struct {
+	MaxEntries    int   // 文件条目数量（不含目录和被忽略的系统文件）
+	MaxEntryBytes int64 // 单个条目解压后的大小
+	MaxTotalBytes int64 // 全部条目解压后的总大小
+	MaxRatio      int64 // 单个条目的最大压缩比
+}
+
+// ArchiveEntry 是压缩包中的一个文件。Err 非空表示该条目被拒绝，Data 为空
+type ArchiveEntry struct {
+	Name string
+	Data []byte
+	Err  error
+}
+
+// IsZip 判断内容是否为普通 ZIP 压缩包（DOCX 等基于 zip 的文档格式不算）
+func IsZip(data []byte) bool {
+	return DetectMIME(data) == "application/zip"
+}
+
+// WalkZip 依次解压压缩包中的文件并交给 fn 处理，每次只解压一个条目；
+// 条目内容交给 fn 后是否继续保留由调用方决定，MaxTotalBytes 限制的是全部条目解压后的总量。
+// 条目大小按实际解压的字节计算，不信任 zip 头中声明的大小。
+// 不安全的路径和超大的条目作为单个条目的错误返回；条目过多、总大小超限或疑似 zip 炸弹时中止并返回错误
+func WalkZip(data []byte, limits ArchiveLimits, fn func(entry ArchiveEntry)) error {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ErrInvalidArchive
+	}
+
+	var entries int
+	var total int64
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || isArchiveJunk(file.Name) {
+			continue
+		}
+		entries++
+		if entries > limits.MaxEntries {
+			return fmt.Errorf("%w: more than %d files", ErrArchiveTooLarge, limits.MaxEntries)
+		}
+
+		name, ok := safeArchivePath(file.Name)
+		if !ok {
+			fn(ArchiveEntry{Name: file.Name, Err: ErrUnsafePath})
+			continue
+		}
+		if file.UncompressedSize64 > uint64(limits.MaxEntryBytes) {
+			fn(ArchiveEntry{Name: name, Err: ErrEntryTooLarge})
+			continue
+		}
+
+		content, err := readZipEntry(file, limits.MaxEntryBytes)
+		if err != nil {
+			fn(ArchiveEntry{Name: name, Err: err})
+			continue
+		}
+		if size := int64(len(content)); size >= ratioCheckMinBytes {
+			if compressed := int64(file.CompressedSize64); compressed == 0 || size/compressed > limits.MaxRatio {
+				return ErrSuspiciousArchive
+			}
+		}
+		total += int64(len(content))
+		if total > limits.MaxTotalBytes {
+			return fmt.Errorf("%w: more than %d bytes uncompressed", ErrArchiveTooLarge, limits.MaxTotalBytes)
+		}
+		fn(ArchiveEntry{Name: name, Data: content})
+	}
+	return nil
+}
+
+func readZipEntry(file *zip.File, maxBytes int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(io.LimitReader(rc, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, ErrEntryTooLarge
+	}
+	return content, nil
+}
+
+// safeArchivePath 规范化条目路径，拒绝绝对路径、盘符和 ".." 跳出根目录的路径
+func safeArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	return path.Clean(name), true
+}
+
+// isArchiveJunk 判断是否为 macOS 等系统在打包时附带的文件
+func isArchiveJunk(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "__MACOSX/") {
+		return true
+	}
+	base := path.Base(name)
+	return strings.HasPrefix(base, ".") || base == "Thumbs.db" || base == "desktop.ini"
+}
@@ -0,0 +1,196 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+type zipFile struct {
+	name string
+	data []byte
+}
+
+func buildZip(t *testing.T, files ...zipFile) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(f.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+var testArchiveLimits = ArchiveLimits{MaxEntries: 10, MaxEntryBytes: 1 << 10, MaxTotalBytes: 4 << 10, MaxRatio: 100}
+
+// walk 收集 WalkZip 交给回调的条目
+func walk(data []byte, limits ArchiveLimits) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	err := WalkZip(data, limits, func(entry ArchiveEntry) {
+		entries = append(entries, entry)
+	})
+	return entries, err
+}
+
+func TestWalkZip(t *testing.T) {
+	data := buildZip(t,
+		zipFile{"指南/", nil},
+		zipFile{"指南/糖尿病.txt", []byte("胰岛素")},
+		zipFile{"指南/./高血压.md", []byte("# 降压")},
+		zipFile{"__MACOSX/指南/._糖尿病.txt", []byte("junk")},
+		zipFile{"指南/.DS_Store", []byte("junk")},
+		zipFile{`Windows\Thumbs.db`, []byte("junk")},
+		zipFile{"../逃逸.txt", []byte("x")},
+		zipFile{"/etc/passwd", []byte("x")},
+		zipFile{`C:\temp\x.txt`, []byte("x")},
+		zipFile{"大文件.txt", bytes.Repeat([]byte("a"), 2<<10)},
+	)
+	entries, err := walk(data, testArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name string
+		data string
+		err  error
+	}{
+		{"指南/糖尿病.txt", "胰岛素", nil},
+		{"指南/高血压.md", "# 降压", nil},
+		{"../逃逸.txt", "", ErrUnsafePath},
+		{"/etc/passwd", "", ErrUnsafePath},
+		{`C:\temp\x.txt`, "", ErrUnsafePath},
+		{"大文件.txt", "", ErrEntryTooLarge},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries: %+v", len(entries), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Name != w.name || string(e.Data) != w.data || !errors.Is(e.Err, w.err) {
+			t.Errorf("entry %d = {%q %q %v}, want {%q %q %v}", i, e.Name, e.Data, e.Err, w.name, w.data, w.err)
+		}
+	}
+}
+
+func TestWalkZipLimits(t *testing.T) {
+	small := zipFile{"a.txt", bytes.Repeat([]byte("x"), 1000)}
+	tests := []struct {
+		name   string
+		data   []byte
+		limits ArchiveLimits
+		want   error
+	}{
+		{"not a zip", []byte("plain text"), testArchiveLimits, ErrInvalidArchive},
+		{"too many entries", buildZip(t, small, small, small),
+			ArchiveLimits{MaxEntries: 2, MaxEntryBytes: 1 << 10, MaxTotalBytes: 1 << 20, MaxRatio: 100}, ErrArchiveTooLarge},
+		{"total too large", buildZip(t, small, small, small),
+			ArchiveLimits{MaxEntries: 10, MaxEntryBytes: 1 << 10, MaxTotalBytes: 2500, MaxRatio: 100}, ErrArchiveTooLarge},
+		// 2 MiB 的零字节压缩后只有几 KiB，压缩比远超 100
+		{"zip bomb ratio", buildZip(t, zipFile{"bomb.txt", make([]byte, 2<<20)}),
+			ArchiveLimits{MaxEntries: 10, MaxEntryBytes: 4 << 20, MaxTotalBytes: 8 << 20, MaxRatio: 100}, ErrSuspiciousArchive},
+		{"zero budget", buildZip(t, small), ArchiveLimits{MaxEntries: 10, MaxEntryBytes: 1 << 10, MaxRatio: 100}, ErrArchiveTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := walk(tt.data, tt.limits); !errors.Is(err, tt.want) {
+				t.Errorf("WalkZip = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// 小于 ratioCheckMinBytes 的高压缩比条目不视为 zip 炸弹
+	entries, err := walk(buildZip(t, zipFile{"repeat.txt", make([]byte, 1000)}), testArchiveLimits)
+	if err != nil || len(entries) != 1 || entries[0].Err != nil {
+		t.Errorf("small repetitive entry: %v, %+v", err, entries)
+	}
+}
+
+// zip 头中声明的大小小于实际内容时，按实际解压的字节拒绝，不会读出超过上限的数据
+func TestWalkZipUntrustedHeaderSize(t *testing.T) {
+	content := bytes.Repeat([]byte("y"), 4<<10)
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "lying.txt",
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(len(content)),
+		UncompressedSize64: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(content)
+	zw.Close()
+
+	entries, err := walk(b.Bytes(), testArchiveLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Err == nil || len(entries[0].Data) != 0 {
+		t.Errorf("entries = %+v, want one rejected entry", entries)
+	}
+}
+
+func TestIsZip(t *testing.T) {
+	if !IsZip(buildZip(t, zipFile{"a.txt", []byte("a")})) {
+		t.Error("plain zip not detected")
+	}
+	if IsZip(buildZip(t, zipFile{"[Content_Types].xml", []byte("<Types/>")}, zipFile{"word/document.xml", []byte("<w:document/>")})) {
+		t.Error("docx should not be treated as an archive")
+	}
+	if IsZip([]byte("PK but not really")) {
+		t.Error("text detected as zip")
+	}
+}
+
+func TestSafeArchivePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"dir/./b.txt", "dir/b.txt", true},
+		{`dir\sub\c.txt`, "dir/sub/c.txt", true},
+		{"dir//d.txt", "dir/d.txt", true},
+		{"..a/b.txt", "..a/b.txt", true},
+		{"", "", false},
+		{"../x", "", false},
+		{"dir/../../x", "", false},
+		{`..\x`, "", false},
+		{"/abs", "", false},
+		{`\abs`, "", false},
+		{"C:/x", "", false},
+		{"c:x", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := safeArchivePath(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("safeArchivePath(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestIsArchiveJunk(t *testing.T) {
+	junk := []string{"__MACOSX/a/._b.txt", ".DS_Store", "dir/.hidden", "Thumbs.db", `dir\desktop.ini`}
+	for _, name := range junk {
+		if !isArchiveJunk(name) {
+			t.Errorf("isArchiveJunk(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"a.txt", "dir/MACOSX.txt", "thumbs.db.txt"} {
+		if isArchiveJunk(name) {
+			t.Errorf("isArchiveJunk(%q) = true", name)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package extractor
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// 检测到的文本编码名称
+const (
+	EncodingUTF8    = "UTF-8"
+	EncodingUTF16LE = "UTF-16LE"
+	EncodingUTF16BE = "UTF-16BE"
+	EncodingGB18030 = "GB18030" // 兼容 GBK 和 GB2312
+	EncodingBig5    = "Big5"
+)
+
+// ErrUnknownEncoding 表示文本既不是有效的 UTF-8，也无法可靠地识别为中文编码
+var ErrUnknownEncoding = errors.New("unable to detect text encoding")
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// HTML 中 <meta charset="gbk"> 或 content="text/html; charset=gb2312" 形式的编码声明
+var htmlCharsetDecl = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-z0-9_-]+)`)
+
+// 常用汉字（简体及对应繁体），用于判断 GB18030 与 Big5 哪种解码结果更像正常中文
+var commonHan = func() map[rune]struct{} {
+	const chars = "的一是不了人我在有他这中大来上个国到说们为子和你地出道也时年得就那要下以生会自着去之过家学对可她里后小么心多天而能好都然没日于起还发成事只作当想看文无开手十用主行方又如前所本见经头面公同三已老从动两长知民样现分将外但身些与高意进把法此实回二理美点月明其种声全工己话儿者向情部正名定女问力机给等几很业最间新什打便位因重被走电四第门相次东政海口使教西再平真听世气信北少关并内加化由却代军产入先山五太水万市眼体别处总才场师书比住员九笑性通目华报立马命张活难神数件安表原车白应路期叫死常提感金何更反合放做系计或司利受光王果亲界及今京务制解各任至清物台象记边共风战干接它许八特觉望直服林题建南度统色字请交爱让认算论百吃义科怎元社术结六功指思非流每青管夫连远资队跟带花快条院变联言权往展该领传近留红治决周保达办运武半候七必城父强步完革深区即求品士转量空甚众技轻程告江语英基派满式息写识极令黄德收脸钱党未持取设始版双历越史商千片容研像找友孩站广改议形委早房音火际则首单据导影失拿网香似专石若兵弟谁校读志飞观争究包组造落视济喜离虽坏兴切调血压病症药医疗患糖尿肾肝肺胃癌炎痛热咳嗽检查诊断剂量服用注射副作用禁忌慎儿童孕妇" +
+		"這來個國說們為時會著過學對裡後麼發無開頭從動兩長樣現將與進點種聲話兒問機給幾業間電門東聽氣關並內卻軍產萬體別處總場師書員報馬張難數車應親務記邊風戰許覺題統請愛讓認論義術結連遠資隊帶條變聯權該領傳紅決達辦運強區轉眾輕語滿寫識極黃臉錢黨設雙歷廣議際則單據導網專誰讀飛觀爭組視濟離雖壞興調壓藥醫療腎檢診斷劑副禁孕婦"
+	set := make(map[rune]struct{}, utf8.RuneCountInString(chars))
+	for _, r := range chars {
+		set[r] = struct{}{}
+	}
+	return set
+}()
+
+// DecodeText 检测文本编码并转换为 UTF-8，返回转换后的内容和检测到的编码。
+// 依次检查 BOM、无 BOM 的 UTF-16（按 NUL 字节位置）、UTF-8 有效性、HTML 中声明的编码，
+// 最后在 GB18030、Big5 与 UTF-16 之间按常用字比例选择
+func DecodeText(data []byte) ([]byte, string, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return data[len(bomUTF8):], EncodingUTF8, nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		out, err := decodeWith(xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM), data)
+		return out, EncodingUTF16LE, err
+	case bytes.HasPrefix(data, bomUTF16BE):
+		out, err := decodeWith(xunicode.UTF16(xunicode.BigEndian, xunicode.UseBOM), data)
+		return out, EncodingUTF16BE, err
+	}
+	// 含 ASCII 的 UTF-16 文本同时也是有效的 UTF-8（NUL 是合法字符），须在 UTF-8 检查之前识别
+	if len(data)%2 == 0 {
+		if name, enc := utf16ByNULs(data); enc != nil {
+			out, err := decodeWith(enc, data)
+			return out, name, err
+		}
+	}
+	if utf8.Valid(data) {
+		return data, EncodingUTF8, nil
+	}
+
+	if name, enc := declaredCharset(data); enc != nil {
+		if out, err := decodeWith(enc, data); err == nil && bytes.Count(out, []byte(string(utf8.RuneError))) == 0 {
+			return out, name, nil
+		}
+	}
+
+	// 按顺序比较，得分相同时取靠前的编码；两者都不像中文时，宁可拒绝也不要存入乱码
+	candidates := []struct {
+		name string
+		enc  encoding.Encoding
+	}{
+		{EncodingGB18030, simplifiedchinese.GB18030},
+		{EncodingBig5, traditionalchinese.Big5},
+	}
+	if len(data)%2 == 0 {
+		candidates = append(candidates, utf16Candidates...)
+	}
+	var best []byte
+	bestName, bestScore := "", minChineseScore
+	for _, c := range candidates {
+		out, err := decodeWith(c.enc, data)
+		if err != nil {
+			continue
+		}
+		if score := chineseScore(out); score > bestScore || (score == bestScore && best == nil) {
+			best, bestName, bestScore = out, c.name, score
+		}
+	}
+	if best == nil {
+		return nil, "", ErrUnknownEncoding
+	}
+	return best, bestName, nil
+}
+
+// 判定为中文文本所需的最低常用字得分
+const minChineseScore = 0.2
+
+// 判断无 BOM 的 UTF-16 时只检查开头的这些字节
+const utf16SampleBytes = 4096
+
+// utf16Candidates 是无 BOM 时尝试的两种字节序
+var utf16Candidates = []struct {
+	name string
+	enc  encoding.Encoding
+}{
+	{EncodingUTF16LE, xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM)},
+	{EncodingUTF16BE, xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM)},
+}
+
+// utf16Sample 返回用于判断 UTF-16 的开头部分（偶数长度）
+func utf16Sample(data []byte) []byte {
+	return data[:min(len(data), utf16SampleBytes)&^1]
+}
+
+// utf16ByNULs 按 NUL 字节的位置识别无 BOM 的 UTF-16 文本：ASCII 字符（换行、空格、数字、英文标点）
+// 的高字节为 0，小端序时 NUL 集中在奇数位置，大端序时集中在偶数位置。UTF-8 和 GB18030 文本中没有 NUL
+func utf16ByNULs(data []byte) (string, encoding.Encoding) {
+	sample := utf16Sample(data)
+	units := len(sample) / 2
+	if units < 2 {
+		return "", nil
+	}
+	var even, odd int
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+	// 至少 1/8 的码元带 NUL，且几乎都在同一侧；两侧都有大量 NUL 的是二进制数据
+	var c int
+	switch {
+	case odd*8 >= units && even*20 <= odd:
+		c = 0
+	case even*8 >= units && odd*20 <= even:
+		c = 1
+	default:
+		return "", nil
+	}
+	if out, err := decodeWith(utf16Candidates[c].enc, sample); err != nil || !readableText(string(out)) {
+		return "", nil
+	}
+	return utf16Candidates[c].name, utf16Candidates[c].enc
+}
+
+// looksLikeUTF16 判断嗅探为二进制的内容是否是无 BOM 的 UTF-16 文本：按 NUL 字节位置，
+// 或（纯中文文本没有 NUL 特征时）按解码结果的常用字比例。返回开头部分解码后的文本
+func looksLikeUTF16(data []byte) ([]byte, bool) {
+	if len(data)%2 != 0 {
+		return nil, false
+	}
+	sample := utf16Sample(data)
+	if _, enc := utf16ByNULs(sample); enc != nil {
+		out, err := decodeWith(enc, sample)
+		return out, err == nil
+	}
+	for _, c := range utf16Candidates {
+		out, err := decodeWith(c.enc, sample)
+		if err == nil && readableText(string(out)) && chineseScore(out) >= minChineseScore {
+			return out, true
+		}
+	}
+	return nil, false
+}
+
+// declaredCharset 读取 HTML 开头声明的中文编码
+func declaredCharset(data []byte) (string, encoding.Encoding) {
+	head := data
+	if len(head) > 2048 {
+		head = head[:2048]
+	}
+	m := htmlCharsetDecl.FindSubmatch(head)
+	if m == nil {
+		return "", nil
+	}
+	switch strings.ToLower(string(m[1])) {
+	case "gbk", "gb2312", "gb18030", "x-gbk":
+		return EncodingGB18030, simplifiedchinese.GB18030
+	case "big5", "big5-hkscs":
+		return EncodingBig5, traditionalchinese.Big5
+	}
+	return "", nil
+}
+
+func decodeWith(enc encoding.Encoding, data []byte) ([]byte, error) {
+	return enc.NewDecoder().Bytes(data)
+}
+
+// chineseScore 返回解码结果中常用汉字占全部汉字的比例，每个替换字符（解码失败）都会拉低得分
+func chineseScore(text []byte) float64 {
+	var han, common, invalid int
+	for _, r := range string(text) {
+		switch {
+		case r == utf8.RuneError:
+			invalid++
+		case unicode.Is(unicode.Han, r):
+			han++
+			if _, ok := commonHan[r]; ok {
+				common++
+			}
+		}
+	}
+	if han == 0 {
+		return 0
+	}
+	return float64(common)/float64(han) - float64(invalid)/float64(han)
+}
@@ -0,0 +1,197 @@
+package extractor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+const (
+	simplifiedSample  = "糖尿病患者的血糖控制目标应个体化，胰岛素剂量需要根据体重和血糖监测结果调整。"
+	traditionalSample = "糖尿病患者的血糖控制目標應個體化，胰島素劑量需要根據體重和血糖監測結果調整。"
+)
+
+func encodeWith(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	out, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("encode %q: %v", s, err)
+	}
+	return out
+}
+
+func TestDecodeText(t *testing.T) {
+	utf16le := xunicode.UTF16(xunicode.LittleEndian, xunicode.UseBOM)
+	utf16be := xunicode.UTF16(xunicode.BigEndian, xunicode.UseBOM)
+	// 只含生僻字的文本按常用字比例无法识别，有 meta 声明时仍按声明的编码解码
+	rare := "魑魅魍魉"
+
+	tests := []struct {
+		name     string
+		data     []byte
+		want     string
+		encoding string
+	}{
+		{"ascii", []byte("Insulin 10 U"), "Insulin 10 U", EncodingUTF8},
+		{"utf-8", []byte(simplifiedSample), simplifiedSample, EncodingUTF8},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, simplifiedSample...), simplifiedSample, EncodingUTF8},
+		{"utf-16le bom", encodeWith(t, utf16le, simplifiedSample), simplifiedSample, EncodingUTF16LE},
+		{"utf-16be bom", encodeWith(t, utf16be, traditionalSample), traditionalSample, EncodingUTF16BE},
+		{"gbk", encodeWith(t, simplifiedchinese.GBK, simplifiedSample), simplifiedSample, EncodingGB18030},
+		{"gb18030 with ascii", encodeWith(t, simplifiedchinese.GB18030, "HbA1c < 7%：\n"+simplifiedSample),
+			"HbA1c < 7%：\n" + simplifiedSample, EncodingGB18030},
+		{"big5", encodeWith(t, traditionalchinese.Big5, traditionalSample), traditionalSample, EncodingBig5},
+		{"meta charset gb2312",
+			encodeWith(t, simplifiedchinese.GBK, `<html><head><meta http-equiv="Content-Type" content="text/html; charset=gb2312"></head><body>`+simplifiedSample+`</body></html>`),
+			`<html><head><meta http-equiv="Content-Type" content="text/html; charset=gb2312"></head><body>` + simplifiedSample + `</body></html>`,
+			EncodingGB18030},
+		{"meta charset big5",
+			encodeWith(t, traditionalchinese.Big5, `<meta charset="Big5"><p>`+traditionalSample+`</p>`),
+			`<meta charset="Big5"><p>` + traditionalSample + `</p>`,
+			EncodingBig5},
+		{"meta charset gbk rare characters",
+			encodeWith(t, simplifiedchinese.GBK, `<meta charset="gbk"><p>`+rare+`</p>`),
+			`<meta charset="gbk"><p>` + rare + `</p>`,
+			EncodingGB18030},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, enc, err := DecodeText(tt.data)
+			if err != nil {
+				t.Fatalf("DecodeText: %v", err)
+			}
+			if enc != tt.encoding {
+				t.Errorf("encoding = %q, want %q", enc, tt.encoding)
+			}
+			if string(got) != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeTextUnknown(t *testing.T) {
+	tests := map[string][]byte{
+		// 无效 UTF-8，且按 GB18030 或 Big5 解码都不像中文
+		"binary": bytes.Repeat([]byte{0x81, 0x30, 0xff, 0xfe, 0x80, 0x00}, 20),
+		// 没有编码声明时，只含生僻字的 GBK 文本宁可拒绝也不猜测
+		"rare characters without declaration": encodeWith(t, simplifiedchinese.GBK, "<p>魑魅魍魉</p>"),
+	}
+	for name, data := range tests {
+		if _, _, err := DecodeText(data); !errors.Is(err, ErrUnknownEncoding) {
+			t.Errorf("%s: DecodeText = %v, want ErrUnknownEncoding", name, err)
+		}
+	}
+}
+
+func TestDeclaredCharset(t *testing.T) {
+	tests := []struct {
+		head string
+		want string
+	}{
+		{`<meta charset="gbk">`, EncodingGB18030},
+		{`<META CHARSET=GB18030>`, EncodingGB18030},
+		{`<meta http-equiv="Content-Type" content="text/html; charset=x-gbk">`, EncodingGB18030},
+		{`<meta charset='big5-hkscs'>`, EncodingBig5},
+		{`<meta charset="utf-8">`, ""},
+		{`<meta charset="shift_jis">`, ""},
+		{`<p>charset=gbk</p>`, ""},
+	}
+	for _, tt := range tests {
+		name, enc := declaredCharset([]byte(tt.head))
+		if name != tt.want || (enc != nil) != (tt.want != "") {
+			t.Errorf("declaredCharset(%q) = %q, %v; want %q", tt.head, name, enc, tt.want)
+		}
+	}
+}
+
+func TestChineseScore(t *testing.T) {
+	if got := chineseScore([]byte("no han here")); got != 0 {
+		t.Errorf("score without han = %v, want 0", got)
+	}
+	common := chineseScore([]byte(simplifiedSample))
+	// 把 Big5 字节误当作 GB18030 解码会得到大量生僻字
+	misdecoded, _ := decodeWith(simplifiedchinese.GB18030, encodeWith(t, traditionalchinese.Big5, traditionalSample))
+	if wrong := chineseScore(misdecoded); wrong >= common {
+		t.Errorf("misdecoded score %.2f should be below correct score %.2f", wrong, common)
+	}
+}
+
+// 没有 BOM 的 UTF-16：含 ASCII 时按 NUL 字节位置识别字节序，纯中文时按常用字比例识别
+func TestDecodeTextUTF16WithoutBOM(t *testing.T) {
+	le := xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM)
+	be := xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM)
+	mixed := "HbA1c < 7%\n" + simplifiedSample
+	tests := []struct {
+		name     string
+		data     []byte
+		want     string
+		encoding string
+	}{
+		{"le ascii", encodeWith(t, le, "Insulin 10 U\n"), "Insulin 10 U\n", EncodingUTF16LE},
+		{"be ascii", encodeWith(t, be, "Insulin 10 U\n"), "Insulin 10 U\n", EncodingUTF16BE},
+		{"le mixed", encodeWith(t, le, mixed), mixed, EncodingUTF16LE},
+		{"be mixed", encodeWith(t, be, mixed), mixed, EncodingUTF16BE},
+		{"le chinese only", encodeWith(t, le, simplifiedSample), simplifiedSample, EncodingUTF16LE},
+		{"be chinese only", encodeWith(t, be, traditionalSample), traditionalSample, EncodingUTF16BE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, enc, err := DecodeText(tt.data)
+			if err != nil {
+				t.Fatalf("DecodeText: %v", err)
+			}
+			if enc != tt.encoding || string(got) != tt.want {
+				t.Errorf("DecodeText = %q (%s), want %q (%s)", got, enc, tt.want, tt.encoding)
+			}
+		})
+	}
+}
+
+// 无 BOM 的 UTF-16 会被标准嗅探判为二进制，按解码后的内容重新嗅探；真正的二进制数据仍然拒绝
+func TestExtractUTF16WithoutBOM(t *testing.T) {
+	le := xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM)
+	be := xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM)
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		encoding string
+		text     string
+	}{
+		{"plain text", encodeWith(t, le, "HbA1c < 7%\n"+simplifiedSample), "text/plain", EncodingUTF16LE, "HbA1c < 7%\n" + simplifiedSample},
+		{"chinese only", encodeWith(t, be, simplifiedSample), "text/plain", EncodingUTF16BE, simplifiedSample},
+		{"html", encodeWith(t, le, "<html><body><p>"+simplifiedSample+"</p></body></html>"), "text/html", EncodingUTF16LE, simplifiedSample},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Extract(tt.data)
+			if err != nil {
+				t.Fatalf("Extract: %v (mime %s)", err, result.MIMEType)
+			}
+			if result.MIMEType != tt.mimeType || result.Encoding != tt.encoding {
+				t.Errorf("mime %s encoding %s, want %s %s", result.MIMEType, result.Encoding, tt.mimeType, tt.encoding)
+			}
+			if !strings.Contains(result.Text, tt.text) {
+				t.Errorf("text = %q, want it to contain %q", result.Text, tt.text)
+			}
+		})
+	}
+
+	binary := map[string][]byte{
+		"nul on both sides": bytes.Repeat([]byte{0x00, 0x00, 0x01, 0x02}, 64),
+		"odd length":        append(encodeWith(t, le, "Insulin 10 U\n"), 0x00),
+		"control units":     encodeWith(t, le, strings.Repeat("\x01\x02\x03a", 32)),
+	}
+	for name, data := range binary {
+		if _, err := Extract(data); !errors.Is(err, ErrUnsupportedType) {
+			t.Errorf("%s: Extract = %v, want ErrUnsupportedType", name, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DOCX 文件中单个 XML 部件解压后的最大字节数
+const maxDOCXPartSize = 64 << 20
+
+// DOCX 提取 Word（OOXML）文档的段落、标题和表格。
+// 标题按大纲级别输出为 Markdown 形式（"## 标题"），供分块器识别章节结构；表格每行输出为 "单元格 | 单元格"
+type DOCX struct{}
+
+func (DOCX) Extract(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", errors.New("invalid docx file")
+	}
+
+	document, err := readZipPart(zr, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	if document == nil {
+		return "", errors.New("invalid docx file: missing word/document.xml")
+	}
+
+	headingLevels := map[string]int{}
+	if styles, err := readZipPart(zr, "word/styles.xml"); err == nil && styles != nil {
+		headingLevels = parseDOCXHeadingStyles(styles)
+	}
+	return parseDOCXBody(document, headingLevels)
+}
+
+// readZipPart 读取 zip 中的指定文件，不存在时返回 nil
+func readZipPart(zr *zip.Reader, name string) ([]byte, error) {
+	for _, file := range zr.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxDOCXPartSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxDOCXPartSize {
+			return nil, errors.New("docx part too large")
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+// 样式名形如 "heading 1"，中文 Word 中也可能是 "标题 1"
+var docxHeadingName = regexp.MustCompile(`^(?i:heading|标题)\s*(\d)$`)
+
+// parseDOCXHeadingStyles 返回段落样式 ID 到标题级别（1 开始）的映射
+func parseDOCXHeadingStyles(data []byte) map[string]int {
+	levels := make(map[string]int)
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var styleID, name string
+	outline := -1
+	inParagraphStyle := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "style":
+				inParagraphStyle = xmlAttr(t, "type") == "paragraph"
+				styleID, name, outline = xmlAttr(t, "styleId"), "", -1
+			case "name":
+				name = xmlAttr(t, "val")
+			case "outlineLvl":
+				if n, err := strconv.Atoi(xmlAttr(t, "val")); err == nil {
+					outline = n
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local != "style" || !inParagraphStyle || styleID == "" {
+				continue
+			}
+			switch {
+			case outline >= 0 && outline < 9:
+				levels[styleID] = outline + 1
+			case strings.EqualFold(name, "title"):
+				levels[styleID] = 1
+			default:
+				if m := docxHeadingName.FindStringSubmatch(name); m != nil {
+					levels[styleID], _ = strconv.Atoi(m[1])
+				}
+			}
+			inParagraphStyle = false
+		}
+	}
+	return levels
+}
+
+// docxParagraph 是正在读取的段落
+type docxParagraph struct {
+	text  strings.Builder
+	level int // 标题级别，0 为正文
+}
+
+// parseDOCXBody 按文档顺序输出段落和表格
+func parseDOCXBody(data []byte, headingLevels map[string]int) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var out strings.Builder
+	var para *docxParagraph
+
+	// 表格可以嵌套，每层记录当前行的单元格和当前单元格的文本
+	type tableState struct {
+		row  []string
+		cell *strings.Builder
+	}
+	var tables []*tableState
+
+	writeLine := func(line string) {
+		if line = strings.TrimSpace(line); line != "" {
+			out.WriteString(line)
+			out.WriteString("\n")
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.New("invalid docx document xml")
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tbl":
+				tables = append(tables, &tableState{})
+			case "tc":
+				if len(tables) > 0 {
+					tables[len(tables)-1].cell = &strings.Builder{}
+				}
+			case "p":
+				para = &docxParagraph{}
+			case "pStyle":
+				if para != nil {
+					para.level = headingLevels[xmlAttr(t, "val")]
+				}
+			case "outlineLvl":
+				if n, err := strconv.Atoi(xmlAttr(t, "val")); err == nil && para != nil && n < 9 {
+					para.level = n + 1
+				}
+			case "t":
+				var text string
+				if err := dec.DecodeElement(&text, &t); err == nil && para != nil {
+					para.text.WriteString(text)
+				}
+			case "tab":
+				if para != nil {
+					para.text.WriteString("\t")
+				}
+			case "br", "cr":
+				if para != nil {
+					para.text.WriteString("\n")
+				}
+			}
+
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "p":
+				if para == nil {
+					continue
+				}
+				text := strings.TrimSpace(para.text.String())
+				if len(tables) > 0 && tables[len(tables)-1].cell != nil {
+					cell := tables[len(tables)-1].cell
+					if cell.Len() > 0 && text != "" {
+						cell.WriteString(" ")
+					}
+					cell.WriteString(strings.ReplaceAll(text, "\n", " "))
+				} else if para.level > 0 && text != "" {
+					writeLine(strings.Repeat("#", min(para.level, 6)) + " " + strings.ReplaceAll(text, "\n", " "))
+				} else {
+					writeLine(text)
+				}
+				para = nil
+			case "tc":
+				if len(tables) > 0 {
+					table := tables[len(tables)-1]
+					if table.cell != nil {
+						table.row = append(table.row, strings.TrimSpace(table.cell.String()))
+						table.cell = nil
+					}
+				}
+			case "tr":
+				if len(tables) > 0 {
+					table := tables[len(tables)-1]
+					row := strings.Join(table.row, " | ")
+					table.row = nil
+					// 嵌套表格的行并入外层单元格，保持文档顺序
+					if len(tables) > 1 && tables[len(tables)-2].cell != nil {
+						cell := tables[len(tables)-2].cell
+						if cell.Len() > 0 {
+							cell.WriteString("; ")
+						}
+						cell.WriteString(row)
+					} else {
+						writeLine(row)
+					}
+				}
+			case "tbl":
+				if len(tables) > 0 {
+					tables = tables[:len(tables)-1]
+				}
+			}
+		}
+	}
+
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// xmlAttr 按本地名读取属性，忽略命名空间前缀（如 w:val）
+func xmlAttr(el xml.StartElement, local string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,157 @@
+// Package extractor 从上传的文件中提取可供分块和嵌入的纯文本
+package extractor
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PageBreak 分隔不同页的文本。分页格式（如 PDF）按页拼接，分块时据此记录页码
+const PageBreak = "\f"
+
+// ErrUnsupportedType 表示没有可处理该 MIME 类型的提取器
+var ErrUnsupportedType = errors.New("unsupported file type")
+
+// ErrNoText 表示文件中没有可提取的文本，例如扫描版 PDF
+var ErrNoText = errors.New("no extractable text in file")
+
+// ErrEmptyContent 表示提取后只剩空白
+var ErrEmptyContent = errors.New("file has no text content")
+
+// ErrBinaryContent 表示提取结果主要由控制字符或无法解码的字符组成，不是可读文本
+var ErrBinaryContent = errors.New("file content is binary, not text")
+
+// 控制字符和替换字符占比超过该值时视为二进制内容
+const maxBinaryRatio = 0.1
+
+// Extractor 将文件内容转换为纯文本
+type Extractor interface {
+	Extract(data []byte) (string, error)
+}
+
+// MIMEDocx 是 Word（OOXML）文档的 MIME 类型
+const MIMEDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// 按 MIME 类型注册的提取器
+var extractors = map[string]Extractor{
+	"text/plain":      PlainText{},
+	"text/html":       HTML{},
+	"application/pdf": PDF{},
+	MIMEDocx:          DOCX{},
+}
+
+// DetectMIME 根据文件内容嗅探 MIME 类型（不含参数），不信任客户端声明的类型和扩展名。
+// 无 BOM 的 UTF-16 文本含 NUL 等字节，会被嗅探为二进制，此时按解码后的开头部分重新嗅探
+func DetectMIME(data []byte) string {
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(data))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	if mediaType == "application/octet-stream" {
+		if text, ok := looksLikeUTF16(data); ok {
+			mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(text))
+		}
+	}
+	// DOCX 本质是 zip 包，按其中是否包含 word/document.xml 区分
+	if mediaType == "application/zip" && isDOCX(data) {
+		return MIMEDocx
+	}
+	return mediaType
+}
+
+func isDOCX(data []byte) bool {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return false
+	}
+	for _, file := range zr.File {
+		if file.Name == "word/document.xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// ForMIME 返回处理该 MIME 类型的提取器
+func ForMIME(mimeType string) (Extractor, error) {
+	ext, ok := extractors[mimeType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+	return ext, nil
+}
+
+// Result 是一次提取的结果
+type Result struct {
+	Text     string
+	MIMEType string // 嗅探到的文件类型
+	Encoding string // 文本类文件（纯文本、HTML）检测到的原始编码，二进制格式为空
+}
+
+// Extract 嗅探文件类型并提取文本。文本类文件先检测编码并转换为 UTF-8。
+// 出错时返回的 Result 仍包含嗅探到的 MIME 类型
+func Extract(data []byte) (*Result, error) {
+	result := &Result{MIMEType: DetectMIME(data)}
+	ext, err := ForMIME(result.MIMEType)
+	if err != nil {
+		return result, err
+	}
+
+	if isTextMIME(result.MIMEType) {
+		data, result.Encoding, err = DecodeText(data)
+		if err != nil {
+			return result, err
+		}
+	}
+	result.Text, err = ext.Extract(data)
+	if err != nil {
+		return result, err
+	}
+	return result, checkText(result.Text)
+}
+
+// checkText 拒绝空白或以不可读字符为主的提取结果。
+// 嗅探只检查文件开头，文件后部混入的二进制数据在这里才能发现
+func checkText(text string) error {
+	if strings.TrimSpace(text) == "" {
+		return ErrEmptyContent
+	}
+	if !readableText(text) {
+		return ErrBinaryContent
+	}
+	return nil
+}
+
+// readableText 判断文本中控制字符和替换字符（换行、制表、换页除外）的比例不超过 maxBinaryRatio
+func readableText(text string) bool {
+	var total, bad int
+	for _, r := range text {
+		total++
+		switch {
+		case r == '\n' || r == '\r' || r == '\t' || r == '\f':
+		case r == utf8.RuneError || unicode.IsControl(r):
+			bad++
+		}
+	}
+	return total > 0 && float64(bad)/float64(total) <= maxBinaryRatio
+}
+
+func isTextMIME(mimeType string) bool {
+	return mimeType == "text/plain" || mimeType == "text/html"
+}
+
+// PlainText 原样返回 UTF-8 文本，其他编码需先经 DecodeText 转换
+type PlainText struct{}
+
+func (PlainText) Extract(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", errors.New("text file is not valid UTF-8")
+	}
+	return string(data), nil
+}
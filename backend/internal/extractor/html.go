@@ -0,0 +1,223 @@
+package extractor
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML 从保存的网页中提取正文：优先取 <main>/<article>，并去掉脚本、样式、导航、页眉页脚等非正文内容。
+// 标题输出为 Markdown 形式（"## 标题"），列表项以 "- " 开头，表格每行输出为 "单元格 | 单元格"
+type HTML struct{}
+
+func (HTML) Extract(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	// 正文容器内的 <header> 通常包含文章标题，需要保留
+	w := &htmlWriter{}
+	root := findMainContent(doc)
+	if root == nil {
+		root = doc
+	} else {
+		w.keepHeaders = true
+	}
+	w.walk(root)
+
+	text := strings.TrimSpace(w.sb.String())
+	if text == "" {
+		return "", ErrNoText
+	}
+	return text, nil
+}
+
+// 整个子树都不属于正文的元素
+var htmlSkippedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Iframe: true, atom.Svg: true, atom.Button: true,
+	atom.Select: true, atom.Head: true,
+}
+
+// 这些 role 表示导航、横幅等页面框架
+var htmlSkippedRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true,
+	"search": true, "menu": true, "menubar": true,
+}
+
+// class/id 中出现这些词的元素通常是导航、侧栏或广告
+var htmlBoilerplateHints = []string{
+	"nav", "menu", "sidebar", "footer", "header", "breadcrumb", "comment", "advert", "share", "cookie",
+}
+
+// findMainContent 返回 <main>、role="main" 或唯一的 <article> 元素
+func findMainContent(doc *html.Node) *html.Node {
+	var mains, articles []*html.Node
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.DataAtom == atom.Main || htmlAttr(n, "role") == "main":
+				mains = append(mains, n)
+			case n.DataAtom == atom.Article:
+				articles = append(articles, n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+
+	if len(mains) > 0 {
+		return mains[0]
+	}
+	if len(articles) == 1 {
+		return articles[0]
+	}
+	return nil
+}
+
+func isBoilerplate(n *html.Node, keepHeaders bool) bool {
+	if keepHeaders && n.DataAtom == atom.Header {
+		return false
+	}
+	if htmlSkippedElements[n.DataAtom] || htmlSkippedRoles[htmlAttr(n, "role")] {
+		return true
+	}
+	if _, hidden := htmlAttrOK(n, "hidden"); hidden || htmlAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	// 正文容器自身不做 class/id 判断，以免误伤 <main class="main-content"> 之类
+	if n.DataAtom == atom.Main || n.DataAtom == atom.Article || n.DataAtom == atom.Body {
+		return false
+	}
+	hints := strings.ToLower(htmlAttr(n, "class") + " " + htmlAttr(n, "id"))
+	for _, word := range strings.FieldsFunc(hints, func(r rune) bool { return r == ' ' || r == '-' || r == '_' }) {
+		for _, hint := range htmlBoilerplateHints {
+			if word == hint {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// htmlWriter 按块级元素输出文本行
+type htmlWriter struct {
+	sb          strings.Builder
+	line        strings.Builder
+	row         []string
+	cell        *strings.Builder
+	keepHeaders bool
+}
+
+func (w *htmlWriter) flushLine() {
+	line := strings.Join(strings.Fields(w.line.String()), " ")
+	w.line.Reset()
+	if line != "" {
+		w.sb.WriteString(line)
+		w.sb.WriteString("\n")
+	}
+}
+
+func (w *htmlWriter) text(s string) {
+	if w.cell != nil {
+		w.cell.WriteString(s)
+		return
+	}
+	w.line.WriteString(s)
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+		if isBoilerplate(n, w.keepHeaders) {
+			return
+		}
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.flushLine()
+		level := int(n.Data[1] - '0')
+		var heading strings.Builder
+		w.collectText(n, &heading)
+		if title := strings.Join(strings.Fields(heading.String()), " "); title != "" {
+			w.sb.WriteString(strings.Repeat("#", level) + " " + title + "\n")
+		}
+		return
+	case atom.Br:
+		if w.cell != nil {
+			w.cell.WriteString(" ")
+		} else {
+			w.flushLine()
+		}
+		return
+	case atom.Li:
+		w.flushLine()
+		w.line.WriteString("- ")
+	case atom.Tr:
+		w.flushLine()
+		w.row = nil
+	case atom.Td, atom.Th:
+		w.cell = &strings.Builder{}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	switch n.DataAtom {
+	case atom.Td, atom.Th:
+		if w.cell != nil {
+			w.row = append(w.row, strings.Join(strings.Fields(w.cell.String()), " "))
+			w.cell = nil
+		}
+	case atom.Tr:
+		if row := strings.Join(w.row, " | "); strings.Trim(row, " |") != "" {
+			w.sb.WriteString(row + "\n")
+		}
+		w.row = nil
+	case atom.P, atom.Div, atom.Section, atom.Li, atom.Ul, atom.Ol, atom.Dl, atom.Dt, atom.Dd,
+		atom.Blockquote, atom.Pre, atom.Table, atom.Figcaption, atom.Article, atom.Main:
+		if w.cell == nil {
+			w.flushLine()
+		}
+	}
+}
+
+// collectText 收集子树中的文本，跳过非正文元素
+func (w *htmlWriter) collectText(n *html.Node, sb *strings.Builder) {
+	if n.Type == html.TextNode {
+		sb.WriteString(n.Data)
+		return
+	}
+	if n.Type == html.ElementNode && isBoilerplate(n, w.keepHeaders) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.collectText(c, sb)
+	}
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	value, _ := htmlAttrOK(n, key)
+	return value
+}
+
+func htmlAttrOK(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,342 @@
+package extractor
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 页面树和表单 XObject 的最大嵌套深度
+const maxPDFDepth = 16
+
+// PDF 相关错误
+var (
+	// ErrEncryptedPDF 表示 PDF 已加密，无法提取文本
+	ErrEncryptedPDF = errors.New("encrypted pdf is not supported")
+	// ErrPDFTooLarge 表示流解码后超过单个流或整个文件的大小上限（疑似压缩炸弹）
+	ErrPDFTooLarge = errors.New("pdf streams decompress beyond size limit")
+)
+
+// PDF 是纯 Go 实现的 PDF 文本提取器，按页输出文本并以 PageBreak 分隔。
+// 支持 FlateDecode 压缩流、对象流，以及通过 ToUnicode CMap 映射的 CID 字体（中文 PDF 常见）；
+// 不支持加密文档，扫描版（纯图片）PDF 会返回 ErrNoText
+type PDF struct{}
+
+func (PDF) Extract(data []byte) (string, error) {
+	f, err := parsePDF(data)
+	if err != nil {
+		return "", err
+	}
+	if f.exhausted {
+		return "", ErrPDFTooLarge
+	}
+	for _, trailer := range f.trailers {
+		if _, ok := trailer["Encrypt"]; ok {
+			return "", ErrEncryptedPDF
+		}
+	}
+
+	pages := f.pages()
+	if len(pages) == 0 {
+		return "", ErrNoText
+	}
+	texts := make([]string, len(pages))
+	hasText := false
+	for i, page := range pages {
+		texts[i] = f.pageText(page)
+		if f.exhausted {
+			return "", ErrPDFTooLarge
+		}
+		if strings.TrimSpace(texts[i]) != "" {
+			hasText = true
+		}
+	}
+	if !hasText {
+		return "", ErrNoText
+	}
+	return strings.Join(texts, PageBreak), nil
+}
+
+// pdfPage 是页面字典及其（可能继承自父节点的）资源字典
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// pages 按文档顺序返回所有页面，找不到页面树时退化为按对象编号收集 /Type /Page
+func (f *pdfFile) pages() []pdfPage {
+	var root pdfDict
+	for i := len(f.trailers) - 1; i >= 0 && root == nil; i-- {
+		root = f.dict(f.trailers[i]["Root"])
+	}
+	if root == nil {
+		for _, obj := range f.objects {
+			if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Catalog") {
+				root = dict
+				break
+			}
+		}
+	}
+
+	var pages []pdfPage
+	if root != nil {
+		visited := make(map[int]bool)
+		f.walkPages(root["Pages"], nil, visited, 0, &pages)
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	nums := make([]int, 0, len(f.objects))
+	for num := range f.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	for _, num := range nums {
+		if dict, ok := f.objects[num].(pdfDict); ok && dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: f.dict(dict["Resources"])})
+		}
+	}
+	return pages
+}
+
+func (f *pdfFile) walkPages(node interface{}, inherited pdfDict, visited map[int]bool, depth int, pages *[]pdfPage) {
+	if depth > maxPDFDepth {
+		return
+	}
+	if ref, ok := node.(pdfRef); ok {
+		if visited[ref.num] {
+			return
+		}
+		visited[ref.num] = true
+	}
+	dict := f.dict(node)
+	if dict == nil {
+		return
+	}
+
+	resources := inherited
+	if own := f.dict(dict["Resources"]); own != nil {
+		resources = own
+	}
+	if dict["Type"] == pdfName("Page") || (dict["Kids"] == nil && dict["Contents"] != nil) {
+		*pages = append(*pages, pdfPage{dict: dict, resources: resources})
+		return
+	}
+	for _, kid := range f.array(dict["Kids"]) {
+		f.walkPages(kid, resources, visited, depth+1, pages)
+	}
+}
+
+// pageText 解码页面的全部内容流并提取文本
+func (f *pdfFile) pageText(page pdfPage) string {
+	var content []byte
+	switch v := f.resolve(page.dict["Contents"]).(type) {
+	case *pdfStream:
+		content, _ = f.decodeStream(v)
+	case pdfArray:
+		for _, item := range v {
+			if stream, ok := f.resolve(item).(*pdfStream); ok {
+				if data, err := f.decodeStream(stream); err == nil {
+					content = append(content, data...)
+					content = append(content, '\n')
+				}
+			}
+		}
+	}
+
+	w := &textWriter{}
+	f.runContent(content, page.resources, w, 0)
+	return w.String()
+}
+
+// textWriter 收集文本，合并多余的换行和空格
+type textWriter struct {
+	sb           strings.Builder
+	last         rune
+	pendingSpace bool
+}
+
+func (w *textWriter) write(s string) {
+	for _, r := range s {
+		if r == '\r' {
+			r = '\n'
+		}
+		if r == '\n' {
+			w.newline()
+			continue
+		}
+		if w.pendingSpace && r != ' ' {
+			w.sb.WriteByte(' ')
+		}
+		w.pendingSpace = false
+		w.sb.WriteRune(r)
+		w.last = r
+	}
+}
+
+func (w *textWriter) newline() {
+	w.pendingSpace = false
+	if w.sb.Len() > 0 && w.last != '\n' {
+		w.sb.WriteByte('\n')
+		w.last = '\n'
+	}
+}
+
+// space 在单词之间补一个空格，延迟到下一个字符写入时才输出；中文字符之间不需要
+func (w *textWriter) space() {
+	if w.sb.Len() > 0 && w.last != ' ' && w.last != '\n' && !unicode.Is(unicode.Han, w.last) {
+		w.pendingSpace = true
+	}
+}
+
+func (w *textWriter) String() string {
+	return strings.TrimSpace(w.sb.String())
+}
+
+// runContent 解释内容流中与文本相关的操作符，其余操作符忽略
+func (f *pdfFile) runContent(content []byte, resources pdfDict, w *textWriter, depth int) {
+	if depth > maxPDFDepth || len(content) == 0 {
+		return
+	}
+
+	fonts := make(map[pdfName]*pdfFont)
+	fontDict := f.dict(resources["Font"])
+	font := &pdfFont{}
+	var lineY float64
+
+	l := &pdfLexer{data: content}
+	var operands []interface{}
+	for {
+		obj, err := l.next()
+		if err != nil {
+			return
+		}
+		op, ok := obj.(pdfKeyword)
+		if !ok {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch op {
+		case "Tf":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					if cached, ok := fonts[name]; ok {
+						font = cached
+					} else {
+						font = f.loadFont(fontDict[name])
+						fonts[name] = font
+					}
+				}
+			}
+		case "Tj":
+			if s, ok := lastString(operands); ok {
+				w.write(font.decode(s))
+			}
+		case "'", "\"":
+			w.newline()
+			if s, ok := lastString(operands); ok {
+				w.write(font.decode(s))
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				arr, _ := operands[len(operands)-1].(pdfArray)
+				for _, item := range arr {
+					switch v := item.(type) {
+					case pdfString:
+						w.write(font.decode(v))
+					case float64:
+						// 较大的负偏移（千分之一字号）通常表示单词间距
+						if v <= -250 {
+							w.space()
+						}
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := operands[1].(float64); ok && ty != 0 {
+					w.newline()
+				} else {
+					w.space()
+				}
+			}
+		case "T*":
+			w.newline()
+		case "Tm":
+			if len(operands) >= 6 {
+				if y, ok := operands[5].(float64); ok {
+					if y != lineY {
+						w.newline()
+					} else {
+						w.space()
+					}
+					lineY = y
+				}
+			}
+		case "ET":
+			w.space()
+		case "Do":
+			if len(operands) >= 1 {
+				if name, ok := operands[0].(pdfName); ok {
+					f.runXObject(name, resources, w, depth)
+				}
+			}
+		case "BI":
+			skipInlineImage(l)
+		}
+		operands = operands[:0]
+	}
+}
+
+// runXObject 提取表单 XObject（常用于页眉页脚和复用的版式）中的文本
+func (f *pdfFile) runXObject(name pdfName, resources pdfDict, w *textWriter, depth int) {
+	stream, ok := f.resolve(f.dict(resources["XObject"])[name]).(*pdfStream)
+	if !ok || stream.dict["Subtype"] != pdfName("Form") {
+		return
+	}
+	data, err := f.decodeStream(stream)
+	if err != nil {
+		return
+	}
+	formResources := resources
+	if own := f.dict(stream.dict["Resources"]); own != nil {
+		formResources = own
+	}
+	f.runContent(data, formResources, w, depth+1)
+}
+
+// skipInlineImage 跳过 BI ... ID <二进制数据> EI
+func skipInlineImage(l *pdfLexer) {
+	idx := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if idx < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += idx + len("ID") + 1
+	for l.pos < len(l.data) {
+		idx := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if idx < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		end := l.pos + idx
+		after := end + len("EI")
+		if end > 0 && isPDFSpace(l.data[end-1]) && (after >= len(l.data) || isPDFSpace(l.data[after])) {
+			l.pos = after
+			return
+		}
+		l.pos = after
+	}
+}
+
+func lastString(operands []interface{}) (pdfString, bool) {
+	if len(operands) == 0 {
+		return nil, false
+	}
+	s, ok := operands[len(operands)-1].(pdfString)
+	return s, ok
+}
@@ -0,0 +1,290 @@
+package extractor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 单个 bfrange 允许展开的最大码数，防止恶意 CMap 占用过多内存
+const maxCMapRange = 1 << 16
+
+// pdfFont 将内容流中的字符串按字体编码转换为 Unicode 文本
+type pdfFont struct {
+	toUnicode *toUnicodeCMap
+	// composite 为 Type0 字体，字符码默认两个字节
+	composite bool
+	// ucs2 表示 CID 即 UCS-2/UTF-16 编码（如 UniGB-UCS2-H），无需 ToUnicode
+	ucs2 bool
+	// differences 为简单字体 /Encoding /Differences 中定义的单字节映射
+	differences map[byte]rune
+}
+
+// loadFont 读取字体字典。中文 PDF 通常使用带 ToUnicode CMap 的 Type0 字体
+func (f *pdfFile) loadFont(obj interface{}) *pdfFont {
+	dict := f.dict(obj)
+	font := &pdfFont{}
+	if dict == nil {
+		return font
+	}
+
+	font.composite = f.resolve(dict["Subtype"]) == pdfName("Type0")
+	if stream, ok := f.resolve(dict["ToUnicode"]).(*pdfStream); ok {
+		if data, err := f.decodeStream(stream); err == nil {
+			font.toUnicode = parseToUnicode(data)
+		}
+	}
+
+	switch enc := f.resolve(dict["Encoding"]).(type) {
+	case pdfName:
+		name := string(enc)
+		font.ucs2 = font.composite && (strings.Contains(name, "UCS2") || strings.Contains(name, "UTF16"))
+	case pdfDict:
+		if diffs := f.array(enc["Differences"]); len(diffs) > 0 {
+			font.differences = make(map[byte]rune)
+			code := 0
+			for _, item := range diffs {
+				switch v := f.resolve(item).(type) {
+				case float64:
+					code = int(v)
+				case pdfName:
+					if r, ok := glyphRune(string(v)); ok && code >= 0 && code < 256 {
+						font.differences[byte(code)] = r
+					}
+					code++
+				}
+			}
+		}
+	}
+	return font
+}
+
+// decode 将字符串字节转换为文本，无法映射的字符码被忽略
+func (font *pdfFont) decode(s []byte) string {
+	var sb strings.Builder
+	if font.toUnicode != nil {
+		for len(s) > 0 {
+			n := font.toUnicode.codeLength(s, font.composite)
+			if n > len(s) {
+				n = len(s)
+			}
+			if text, ok := font.toUnicode.mapping[string(s[:n])]; ok {
+				sb.WriteString(text)
+			} else if !font.composite {
+				sb.WriteRune(font.simpleRune(s[0]))
+			}
+			s = s[n:]
+		}
+		return sb.String()
+	}
+
+	if font.composite {
+		if !font.ucs2 {
+			// Identity-H 等编码下 CID 与 Unicode 无关，没有 ToUnicode 无法还原
+			return ""
+		}
+		return decodeUTF16BE(s)
+	}
+
+	for _, c := range s {
+		sb.WriteRune(font.simpleRune(c))
+	}
+	return sb.String()
+}
+
+// simpleRune 映射单字节字符码：优先 Differences，否则按 WinAnsi 近似为 Latin-1
+func (font *pdfFont) simpleRune(c byte) rune {
+	if r, ok := font.differences[c]; ok {
+		return r
+	}
+	if r, ok := winAnsiSpecials[c]; ok {
+		return r
+	}
+	if c < 0x20 && c != '\t' && c != '\n' && c != '\r' {
+		return ' '
+	}
+	return rune(c)
+}
+
+// WinAnsiEncoding 中与 Latin-1 不同的常用字符
+var winAnsiSpecials = map[byte]rune{
+	0x80: '€', 0x85: '…', 0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”',
+	0x95: '•', 0x96: '–', 0x97: '—', 0x99: '™', 0xA0: ' ',
+}
+
+// 常见字形名到 Unicode 的映射，覆盖 /Differences 中最常出现的标点
+var glyphNames = map[string]rune{
+	"space": ' ', "period": '.', "comma": ',', "colon": ':', "semicolon": ';',
+	"hyphen": '-', "endash": '–', "emdash": '—', "quoteright": '’', "quoteleft": '‘',
+	"quotedblleft": '“', "quotedblright": '”', "quotesingle": '\'', "quotedbl": '"',
+	"parenleft": '(', "parenright": ')', "bracketleft": '[', "bracketright": ']',
+	"slash": '/', "percent": '%', "plus": '+', "equal": '=', "less": '<', "greater": '>',
+	"question": '?', "exclam": '!', "bullet": '•', "degree": '°', "plusminus": '±',
+	"multiply": '×', "mu": 'μ', "fi": 'ﬁ', "fl": 'ﬂ', "zero": '0', "one": '1',
+	"two": '2', "three": '3', "four": '4', "five": '5', "six": '6', "seven": '7',
+	"eight": '8', "nine": '9', "ampersand": '&', "asterisk": '*', "underscore": '_',
+}
+
+// glyphRune 解析字形名：单个字母、uniXXXX、uXXXX[XX] 以及常见标点名
+func glyphRune(name string) (rune, bool) {
+	if r, ok := glyphNames[name]; ok {
+		return r, true
+	}
+	if len(name) == 1 {
+		return rune(name[0]), true
+	}
+	for _, prefix := range []string{"uni", "u"} {
+		if strings.HasPrefix(name, prefix) && len(name) >= len(prefix)+4 {
+			if v, err := strconv.ParseUint(name[len(prefix):len(prefix)+4], 16, 32); err == nil {
+				return rune(v), true
+			}
+		}
+	}
+	return 0, false
+}
+
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// toUnicodeCMap 是字体 ToUnicode CMap 中字符码到 Unicode 文本的映射
+type toUnicodeCMap struct {
+	codespaces []codespaceRange
+	mapping    map[string]string
+}
+
+type codespaceRange struct {
+	lo, hi []byte
+}
+
+// codeLength 按 codespacerange 确定下一个字符码的字节数
+func (m *toUnicodeCMap) codeLength(s []byte, composite bool) int {
+	for _, cs := range m.codespaces {
+		n := len(cs.lo)
+		if n == 0 || n > len(s) {
+			continue
+		}
+		inRange := true
+		for i := 0; i < n; i++ {
+			if s[i] < cs.lo[i] || s[i] > cs.hi[i] {
+				inRange = false
+				break
+			}
+		}
+		if inRange {
+			return n
+		}
+	}
+	if composite {
+		return 2
+	}
+	return 1
+}
+
+// parseToUnicode 解析 CMap 中的 codespacerange、bfchar 和 bfrange
+func parseToUnicode(data []byte) *toUnicodeCMap {
+	cmap := &toUnicodeCMap{mapping: make(map[string]string)}
+	l := &pdfLexer{data: data}
+
+	var mode pdfKeyword
+	var operands []interface{}
+	for {
+		obj, err := l.next()
+		if err != nil {
+			break
+		}
+		kw, isKeyword := obj.(pdfKeyword)
+		if !isKeyword {
+			operands = append(operands, obj)
+			continue
+		}
+
+		switch kw {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			mode = kw
+		case "endcodespacerange":
+			for i := 0; i+1 < len(operands); i += 2 {
+				lo, ok1 := operands[i].(pdfString)
+				hi, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 && len(lo) == len(hi) {
+					cmap.codespaces = append(cmap.codespaces, codespaceRange{lo: lo, hi: hi})
+				}
+			}
+			mode = ""
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok1 := operands[i].(pdfString)
+				dst, ok2 := operands[i+1].(pdfString)
+				if ok1 && ok2 {
+					cmap.mapping[string(src)] = decodeUTF16BE(dst)
+				}
+			}
+			mode = ""
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				cmap.addRange(operands[i], operands[i+1], operands[i+2])
+			}
+			mode = ""
+		}
+		if mode == "" || kw == mode {
+			operands = operands[:0]
+		}
+	}
+	return cmap
+}
+
+// addRange 展开一条 bfrange：目标为字符串时末字节递增，为数组时逐个对应
+func (m *toUnicodeCMap) addRange(loObj, hiObj, dstObj interface{}) {
+	lo, ok1 := loObj.(pdfString)
+	hi, ok2 := hiObj.(pdfString)
+	if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+		return
+	}
+	start, end := bytesToUint(lo), bytesToUint(hi)
+	if end < start || end-start >= maxCMapRange {
+		return
+	}
+
+	code := make([]byte, len(lo))
+	for c := start; c <= end; c++ {
+		uintToBytes(c, code)
+		switch dst := dstObj.(type) {
+		case pdfString:
+			if len(dst) < 2 {
+				return
+			}
+			next := bytes.Clone(dst)
+			last := uint32(next[len(next)-2])<<8 | uint32(next[len(next)-1])
+			last += c - start
+			next[len(next)-2], next[len(next)-1] = byte(last>>8), byte(last)
+			m.mapping[string(code)] = decodeUTF16BE(next)
+		case pdfArray:
+			if int(c-start) >= len(dst) {
+				return
+			}
+			if s, ok := dst[c-start].(pdfString); ok {
+				m.mapping[string(code)] = decodeUTF16BE(s)
+			}
+		}
+	}
+}
+
+func bytesToUint(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func uintToBytes(v uint32, out []byte) {
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = byte(v)
+		v >>= 8
+	}
+}
@@ -0,0 +1,542 @@
+package extractor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// 单个流解压后的最大字节数，以及整个文件所有流累计解码的最大字节数（含重复解码），防止压缩炸弹
+const (
+	maxPDFStreamSize   = 64 << 20
+	maxPDFDecodedBytes = 256 << 20
+)
+
+// 对象编号上限（PDF 规范附录 C 的实现限制），超出的对象定义视为损坏并忽略
+const maxPDFObjectNum = 8388607
+
+// PDF 对象类型。数字统一为 float64，布尔为 bool，null 为 nil
+type (
+	pdfName    string
+	pdfKeyword string
+	pdfString  []byte
+	pdfArray   []interface{}
+	pdfDict    map[pdfName]interface{}
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+var errPDFSyntax = errors.New("malformed pdf")
+
+// pdfLexer 按 PDF 语法读取对象，同时用于解析文件体、内容流和 CMap
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func isPDFSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isPDFDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// readRegular 读取到下一个空白或分隔符为止
+func (l *pdfLexer) readRegular() []byte {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelim(l.data[l.pos]) {
+		l.pos++
+	}
+	return l.data[start:l.pos]
+}
+
+// next 读取下一个对象；遇到未知的关键字（如内容流中的操作符）时返回 pdfKeyword。
+// 数组、字典的结束符也以关键字形式返回，由调用方判断
+func (l *pdfLexer) next() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, io.EOF
+	}
+
+	c := l.data[l.pos]
+	switch {
+	case c == '/':
+		l.pos++
+		return pdfName(decodeNameEscapes(l.readRegular())), nil
+	case c == '(':
+		return l.readLiteralString()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.readDict()
+	case c == '<':
+		return l.readHexString()
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '[':
+		l.pos++
+		return l.readArray()
+	case c == ']', c == '{', c == '}', c == ')', c == '>':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+
+	tok := l.readRegular()
+	if len(tok) == 0 {
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+	if n, err := strconv.ParseFloat(string(tok), 64); err == nil {
+		return l.maybeRef(tok, n), nil
+	}
+	switch string(tok) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	return pdfKeyword(tok), nil
+}
+
+// maybeRef 判断整数后面是否紧跟 "G R"，是则组成间接引用
+func (l *pdfLexer) maybeRef(tok []byte, n float64) interface{} {
+	num, err := strconv.Atoi(string(tok))
+	if err != nil || num < 0 {
+		return n
+	}
+	save := l.pos
+	l.skipSpace()
+	gen, err := strconv.Atoi(string(l.readRegular()))
+	if err == nil {
+		l.skipSpace()
+		if l.pos < len(l.data) && l.data[l.pos] == 'R' &&
+			(l.pos+1 == len(l.data) || isPDFSpace(l.data[l.pos+1]) || isPDFDelim(l.data[l.pos+1])) {
+			l.pos++
+			return pdfRef{num: num, gen: gen}
+		}
+	}
+	l.pos = save
+	return n
+}
+
+func (l *pdfLexer) readArray() (pdfArray, error) {
+	arr := pdfArray{}
+	for {
+		obj, err := l.next()
+		if err != nil {
+			return nil, errPDFSyntax
+		}
+		if kw, ok := obj.(pdfKeyword); ok && kw == "]" {
+			return arr, nil
+		}
+		arr = append(arr, obj)
+	}
+}
+
+func (l *pdfLexer) readDict() (pdfDict, error) {
+	dict := pdfDict{}
+	for {
+		obj, err := l.next()
+		if err != nil {
+			return nil, errPDFSyntax
+		}
+		if kw, ok := obj.(pdfKeyword); ok && kw == ">>" {
+			return dict, nil
+		}
+		key, ok := obj.(pdfName)
+		if !ok {
+			continue
+		}
+		value, err := l.next()
+		if err != nil {
+			return nil, errPDFSyntax
+		}
+		if kw, ok := value.(pdfKeyword); ok && kw == ">>" {
+			return dict, nil
+		}
+		dict[key] = value
+	}
+}
+
+func (l *pdfLexer) readLiteralString() (pdfString, error) {
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out, nil
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out, nil
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// 续行
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (l *pdfLexer) readHexString() (pdfString, error) {
+	l.pos++ // <
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		return nil, errPDFSyntax
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range l.data[l.pos : l.pos+end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	l.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	if _, err := hex.Decode(out, digits); err != nil {
+		return nil, errPDFSyntax
+	}
+	return out, nil
+}
+
+// decodeNameEscapes 解码名字中的 #xx 转义
+func decodeNameEscapes(raw []byte) string {
+	if bytes.IndexByte(raw, '#') < 0 {
+		return string(raw)
+	}
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '#' && i+2 < len(raw) {
+			if v, err := strconv.ParseUint(string(raw[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, raw[i])
+	}
+	return string(out)
+}
+
+// pdfFile 保存解析后的全部间接对象
+type pdfFile struct {
+	objects  map[int]interface{}
+	trailers []pdfDict
+	// decoded 是已解码的流字节总数；exhausted 表示超过了单个流或整个文件的解码上限
+	decoded   int
+	exhausted bool
+}
+
+var (
+	pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfTrailer      = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// parsePDF 扫描文件中所有 "N G obj" 定义，不依赖 xref 表，因此能容忍偏移错误的文件。
+// 增量更新时后出现的定义覆盖之前的定义
+func parsePDF(data []byte) (*pdfFile, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, errors.New("not a pdf file")
+	}
+
+	f := &pdfFile{objects: make(map[int]interface{})}
+	parsedEnd := 0
+	for _, m := range pdfObjectHeader.FindAllSubmatchIndex(data, -1) {
+		// 跳过出现在上一个对象（通常是二进制流）内部的伪匹配
+		if m[0] < parsedEnd {
+			continue
+		}
+		num, err := strconv.Atoi(string(data[m[2]:m[3]]))
+		if err != nil || num > maxPDFObjectNum {
+			continue
+		}
+		l := &pdfLexer{data: data, pos: m[1]}
+		obj, err := l.next()
+		if err != nil {
+			continue
+		}
+		if dict, ok := obj.(pdfDict); ok {
+			if raw, ok := l.readStreamData(dict); ok {
+				obj = &pdfStream{dict: dict, raw: raw}
+				if dict["Type"] == pdfName("XRef") {
+					f.trailers = append(f.trailers, dict)
+				}
+			}
+		}
+		f.objects[num] = obj
+		parsedEnd = l.pos
+	}
+
+	// 传统 trailer 字典
+	for _, idx := range pdfTrailer.FindAllIndex(data, -1) {
+		l := &pdfLexer{data: data, pos: idx[0] + len("trailer")}
+		if obj, err := l.next(); err == nil {
+			if dict, ok := obj.(pdfDict); ok {
+				f.trailers = append(f.trailers, dict)
+			}
+		}
+	}
+
+	if err := f.expandObjectStreams(); err != nil {
+		return nil, err
+	}
+	if len(f.objects) == 0 {
+		return nil, errPDFSyntax
+	}
+	return f, nil
+}
+
+// readStreamData 读取字典之后的 stream ... endstream 数据
+func (l *pdfLexer) readStreamData(dict pdfDict) ([]byte, bool) {
+	save := l.pos
+	l.skipSpace()
+	if !bytes.HasPrefix(l.data[l.pos:], []byte("stream")) {
+		l.pos = save
+		return nil, false
+	}
+	start := l.pos + len("stream")
+	if start < len(l.data) && l.data[start] == '\r' {
+		start++
+	}
+	if start < len(l.data) && l.data[start] == '\n' {
+		start++
+	}
+
+	// 优先使用直接给出的 /Length，校验失败时回退为搜索 endstream
+	if n, ok := dict["Length"].(float64); ok && n >= 0 && start+int(n) <= len(l.data) {
+		end := start + int(n)
+		rest := bytes.TrimLeft(l.data[end:min(end+32, len(l.data))], "\x00\t\n\f\r ")
+		if bytes.HasPrefix(rest, []byte("endstream")) {
+			l.pos = end
+			return l.data[start:end], true
+		}
+	}
+	idx := bytes.Index(l.data[start:], []byte("endstream"))
+	if idx < 0 {
+		return l.data[start:], true
+	}
+	end := start + idx
+	raw := bytes.TrimRight(l.data[start:end], "\r\n")
+	l.pos = end
+	return raw, true
+}
+
+// expandObjectStreams 解出对象流（/Type /ObjStm）中压缩存放的对象，不覆盖直接定义的对象
+func (f *pdfFile) expandObjectStreams() error {
+	for _, obj := range f.objects {
+		stream, ok := obj.(*pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := f.decodeStream(stream)
+		if err != nil {
+			continue
+		}
+		n, _ := f.resolve(stream.dict["N"]).(float64)
+		first, _ := f.resolve(stream.dict["First"]).(float64)
+		if first <= 0 || int(first) > len(data) {
+			continue
+		}
+
+		header := &pdfLexer{data: data[:int(first)]}
+		for i := 0; i < int(n); i++ {
+			numObj, err1 := header.next()
+			offObj, err2 := header.next()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			num, ok1 := numObj.(float64)
+			off, ok2 := offObj.(float64)
+			if !ok1 || !ok2 || num < 0 || num > maxPDFObjectNum || off < 0 || int(first+off) >= len(data) {
+				continue
+			}
+			if _, exists := f.objects[int(num)]; exists {
+				continue
+			}
+			l := &pdfLexer{data: data, pos: int(first + off)}
+			if value, err := l.next(); err == nil {
+				f.objects[int(num)] = value
+			}
+		}
+	}
+	return nil
+}
+
+// resolve 解引用间接对象，最多跟随若干层以防循环引用
+func (f *pdfFile) resolve(obj interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := obj.(pdfRef)
+		if !ok {
+			return obj
+		}
+		obj = f.objects[ref.num]
+	}
+	return nil
+}
+
+func (f *pdfFile) dict(obj interface{}) pdfDict {
+	switch v := f.resolve(obj).(type) {
+	case pdfDict:
+		return v
+	case *pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+func (f *pdfFile) array(obj interface{}) pdfArray {
+	arr, _ := f.resolve(obj).(pdfArray)
+	return arr
+}
+
+// decodeStream 按 /Filter 解码流数据，支持 FlateDecode、ASCIIHexDecode 和 ASCII85Decode
+func (f *pdfFile) decodeStream(stream *pdfStream) ([]byte, error) {
+	var filters pdfArray
+	switch v := f.resolve(stream.dict["Filter"]).(type) {
+	case pdfName:
+		filters = pdfArray{v}
+	case pdfArray:
+		filters = v
+	}
+
+	data := stream.raw
+	for _, filter := range filters {
+		if f.exhausted {
+			return nil, ErrPDFTooLarge
+		}
+		name, _ := f.resolve(filter).(pdfName)
+		var err error
+		switch name {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data, min(maxPDFStreamSize, maxPDFDecodedBytes-f.decoded))
+		case "ASCIIHexDecode", "AHx":
+			l := &pdfLexer{data: append(append([]byte{'<'}, bytes.TrimSuffix(bytes.TrimSpace(data), []byte(">"))...), '>')}
+			data, err = l.readHexString()
+		case "ASCII85Decode", "A85":
+			data, err = decodeASCII85(data)
+		default:
+			return nil, fmt.Errorf("unsupported pdf filter %q", name)
+		}
+		if errors.Is(err, ErrPDFTooLarge) {
+			f.exhausted = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.decoded += len(data)
+		if f.decoded > maxPDFDecodedBytes {
+			f.exhausted = true
+			return nil, ErrPDFTooLarge
+		}
+	}
+	return data, nil
+}
+
+// inflate 解压 zlib 数据，解压结果超过 limit 字节时返回 ErrPDFTooLarge。
+// 许多 PDF 的压缩流末尾不完整，已解出的部分仍然返回
+func inflate(data []byte, limit int) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if len(out) > limit {
+		return nil, ErrPDFTooLarge
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeASCII85(data []byte) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	data = bytes.TrimPrefix(data, []byte("<~"))
+	if idx := bytes.Index(data, []byte("~>")); idx >= 0 {
+		data = data[:idx]
+	}
+	out := make([]byte, 4*len(data)+4) // "z" 可展开为 4 个字节
+	n, _, err := ascii85.Decode(out, data, true)
+	if err != nil {
+		return nil, err
+	}
+	return out[:n], nil
+}
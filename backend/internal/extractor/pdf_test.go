@@ -0,0 +1,274 @@
+package extractor
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildPDF 按顺序拼出对象编号从 1 开始的 PDF，不写 xref 表（解析器按 "N G obj" 扫描对象）。
+// 空字符串表示该编号不作为直接对象出现（例如存放在对象流中）
+func buildPDF(trailer string, objects ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		if obj == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	fmt.Fprintf(&b, "trailer\n%s\n%%%%EOF\n", trailer)
+	return b.Bytes()
+}
+
+func pdfStreamObject(dict string, data []byte) string {
+	return fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data)
+}
+
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// simplePDF 返回每页一个内容流、使用 Helvetica 的 PDF
+func simplePDF(contents ...string) []byte {
+	kids := make([]string, len(contents))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 页面树，页面对象编号确定后再填写
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	for i, content := range contents {
+		page := len(objects) + 1
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", page+1),
+			pdfStreamObject("", []byte(content)),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(contents))
+	return buildPDF("<< /Root 1 0 R >>", objects...)
+}
+
+// toUnicode 将 2 字节 CID 0001-0004 映射为“糖尿病”和一个空格
+const toUnicode = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<0000> <FFFF>
+endcodespacerange
+2 beginbfchar
+<0001> <7CD6>
+<0004> <0020>
+endbfchar
+1 beginbfrange
+<0002> <0003> [<5C3F> <75C5>]
+endbfrange
+endcmap
+end end`
+
+func TestPDFExtract(t *testing.T) {
+	cidPDF := buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 6 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /Encoding /Identity-H /ToUnicode 5 0 R >>",
+		pdfStreamObject("/Filter /FlateDecode", deflate([]byte(toUnicode))),
+		pdfStreamObject("", []byte("BT /F1 12 Tf <000100020003> Tj ET")),
+	)
+	xobjectPDF := buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> /XObject << /X1 6 0 R >> >> /Contents 4 0 R >>",
+		pdfStreamObject("", []byte("BT /F1 12 Tf (Body) Tj ET /X1 Do")),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		pdfStreamObject("/Type /XObject /Subtype /Form", []byte("BT /F1 8 Tf (Footer) Tj ET")),
+	)
+	// 页面对象放在对象流中，只有目录是直接对象
+	pagesObj := "<< /Type /Pages /Kids [3 0 R] /Count 1 >> "
+	objStmHeader := fmt.Sprintf("2 0 3 %d ", len(pagesObj))
+	objStmBody := pagesObj + "<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>"
+	objStmPDF := buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"",
+		"",
+		pdfStreamObject("", []byte("BT /F1 12 Tf (From object stream) Tj ET")),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		pdfStreamObject(fmt.Sprintf("/Type /ObjStm /N 2 /First %d /Filter /FlateDecode", len(objStmHeader)),
+			deflate([]byte(objStmHeader+objStmBody))),
+	)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"single page", simplePDF("BT /F1 12 Tf 72 720 Td (Hello World) Tj ET"), "Hello World"},
+		{"two pages", simplePDF("BT (Page one) Tj ET", "BT (Page two) Tj ET"), "Page one" + PageBreak + "Page two"},
+		{"line breaks", simplePDF("BT (First) Tj 0 -14 Td (Second) Tj T* (Third) Tj ET"), "First\nSecond\nThird"},
+		{"TJ spacing", simplePDF("BT [(Insu) 20 (lin) -300 (dose)] TJ ET"), "Insulin dose"},
+		{"escapes", simplePDF(`BT (a\(b\) \101\nc) Tj ET`), "a(b) A\nc"},
+		{"flate content", buildPDF("<< /Root 1 0 R >>",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+			pdfStreamObject("/Filter /FlateDecode", deflate([]byte("BT (Compressed text) Tj ET"))),
+		), "Compressed text"},
+		{"cid font with ToUnicode", cidPDF, "糖尿病"},
+		{"form xobject", xobjectPDF, "Body Footer"},
+		{"object stream", objStmPDF, "From object stream"},
+		// 没有页面树时按对象编号排序收集页面，而不是按出现顺序
+		{"no page tree", []byte("%PDF-1.4\n" +
+			"900 0 obj << /Type /Page /Contents 901 0 R >> endobj\n" +
+			"901 0 obj " + pdfStreamObject("", []byte("BT (Second) Tj ET")) + " endobj\n" +
+			"3 0 obj << /Type /Page /Contents 4 0 R >> endobj\n" +
+			"4 0 obj " + pdfStreamObject("", []byte("BT (First) Tj ET")) + " endobj\n"),
+			"First" + PageBreak + "Second"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PDF{}.Extract(tt.data)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Extract = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 超过 maxPDFObjectNum 的对象编号
+const hugeObjectNumPDF = "%PDF-1.4\n999999999999999 0 obj<<>>endobj"
+
+func TestPDFExtractErrors(t *testing.T) {
+	encrypted := buildPDF("<< /Root 1 0 R /Encrypt 5 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		pdfStreamObject("", []byte("BT (secret) Tj ET")),
+		"<< /Filter /Standard /V 2 >>",
+	)
+	// 单个内容流解压后超过 maxPDFStreamSize
+	bomb := buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		pdfStreamObject("/Filter /FlateDecode", deflate(make([]byte, maxPDFStreamSize+1))),
+	)
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"encrypted", encrypted, ErrEncryptedPDF},
+		{"image only", simplePDF("q 100 0 0 100 0 0 cm /Im1 Do Q"), ErrNoText},
+		{"no pages", buildPDF("<< /Root 1 0 R >>", "<< /Type /Catalog >>"), ErrNoText},
+		{"bomb stream", bomb, ErrPDFTooLarge},
+		// 没有页面树时不按编号范围遍历对象，超大编号不会导致长时间循环
+		{"huge object number", []byte(hugeObjectNumPDF), errPDFSyntax},
+		{"huge object number with page", []byte(hugeObjectNumPDF + "\n1 0 obj<< /Type /Pages >>endobj"), ErrNoText},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := (PDF{}).Extract(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Extract = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := (PDF{}).Extract([]byte("not a pdf")); err == nil {
+		t.Error("expected error for non-pdf input")
+	}
+}
+
+// 多个流各自未超过单流上限，但累计解码量超过整个文件的上限
+func TestPDFDecodedBytesBudget(t *testing.T) {
+	f := &pdfFile{objects: map[int]interface{}{}}
+	stream := &pdfStream{
+		dict: pdfDict{"Filter": pdfName("FlateDecode")},
+		raw:  deflate(bytes.Repeat([]byte("x"), 1024)),
+	}
+	if _, err := f.decodeStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	if f.decoded != 1024 {
+		t.Errorf("decoded = %d, want 1024", f.decoded)
+	}
+
+	f.decoded = maxPDFDecodedBytes - 100
+	if _, err := f.decodeStream(stream); !errors.Is(err, ErrPDFTooLarge) {
+		t.Errorf("decodeStream over budget = %v, want ErrPDFTooLarge", err)
+	}
+	if !f.exhausted {
+		t.Error("budget should be marked exhausted")
+	}
+	// 用尽之后即使是很小的流也不再解码
+	small := &pdfStream{dict: pdfDict{"Filter": pdfName("ASCIIHexDecode")}, raw: []byte("4142>")}
+	if _, err := f.decodeStream(small); !errors.Is(err, ErrPDFTooLarge) {
+		t.Errorf("decodeStream after exhaustion = %v, want ErrPDFTooLarge", err)
+	}
+}
+
+func TestPDFDecodeFilters(t *testing.T) {
+	f := &pdfFile{objects: map[int]interface{}{}}
+	tests := []struct {
+		name   string
+		filter interface{}
+		raw    []byte
+		want   string
+	}{
+		{"none", nil, []byte("plain"), "plain"},
+		{"hex", pdfName("ASCIIHexDecode"), []byte("48 65 6C6C 6F>"), "Hello"},
+		{"ascii85", pdfName("ASCII85Decode"), []byte("<~87cURD]i,\"Ebo80~>"), "Hello World!"},
+		{"chain", pdfArray{pdfName("ASCIIHexDecode"), pdfName("FlateDecode")},
+			[]byte(fmt.Sprintf("%x>", deflate([]byte("chained")))), "chained"},
+	}
+	for _, tt := range tests {
+		dict := pdfDict{}
+		if tt.filter != nil {
+			dict["Filter"] = tt.filter
+		}
+		got, err := f.decodeStream(&pdfStream{dict: dict, raw: tt.raw})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := f.decodeStream(&pdfStream{dict: pdfDict{"Filter": pdfName("DCTDecode")}}); err == nil {
+		t.Error("expected error for unsupported filter")
+	}
+}
+
+func FuzzExtractPDF(f *testing.F) {
+	f.Add(simplePDF("BT /F1 12 Tf (Hello) Tj ET"))
+	f.Add(simplePDF("BT [(a) -300 (b)] TJ 0 -12 Td (c) ' ET", "BT (p2) Tj ET"))
+	f.Add(buildPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 2 0 R] /Count 1 >>", // 页面树中的循环引用
+		"<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type0 /ToUnicode 6 0 R >>",
+		pdfStreamObject("/Filter /FlateDecode", deflate([]byte("BT /F1 1 Tf <0001> Tj ET"))),
+		pdfStreamObject("", []byte(toUnicode)),
+	))
+	f.Add([]byte("%PDF-1.7\n1 0 obj << /Length 99999 >> stream\nBT (x) Tj ET"))
+	f.Add([]byte("%PDF-"))
+	f.Add([]byte(hugeObjectNumPDF))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		text, err := PDF{}.Extract(data)
+		if err == nil && strings.TrimSpace(text) == "" {
+			t.Errorf("Extract returned empty text without error")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+// Package fingerprint 计算文档内容指纹：精确哈希用于识别完全相同的内容，SimHash 用于识别近似重复
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// shingleSize 是 SimHash 使用的字符 n-gram 长度，中文没有空格分词，按字符切分更稳定
+const shingleSize = 3
+
+// Normalize 折叠空白并去掉首尾空白，使换行符、缩进不同的同一文本得到相同指纹
+func Normalize(text string) string {
+	return strings.Join(strings.FieldsFunc(text, unicode.IsSpace), " ")
+}
+
+// ContentHash 返回规范化后内容的 SHA-256（十六进制）
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(Normalize(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// SimHash 返回内容的 64 位 SimHash。只使用字母、数字和汉字，忽略标点和空白
+func SimHash(text string) uint64 {
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, unicode.ToLower(r))
+		}
+	}
+	if len(runes) == 0 {
+		return 0
+	}
+
+	var weights [64]int
+	add := func(shingle []rune) {
+		h := fnv.New64a()
+		h.Write([]byte(string(shingle)))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+	if len(runes) < shingleSize {
+		add(runes)
+	} else {
+		for i := 0; i+shingleSize <= len(runes); i++ {
+			add(runes[i : i+shingleSize])
+		}
+	}
+
+	var fp uint64
+	for i, w := range weights {
+		if w > 0 {
+			fp |= 1 << uint(i)
+		}
+	}
+	return fp
+}
+
+// Similarity 返回两个 SimHash 的相似度：1 - 汉明距离/64
+func Similarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// BandCount 是 SimHash 分段的数量，每段 8 位。
+// 汉明距离不超过 BandCount-1 的两个指纹至少有一段完全相同，按分段建索引即可缩小近似比较的候选范围
+const BandCount = 8
+
+// MinBandedSimilarity 是分段索引能保证不漏检的最低相似度，阈值低于该值时部分近似重复可能找不到
+const MinBandedSimilarity = 1 - float64(BandCount-1)/64
+
+// Bands 将 SimHash 从低位到高位切分为 BandCount 段
+func Bands(fp uint64) [BandCount]uint8 {
+	var bands [BandCount]uint8
+	for i := range bands {
+		bands[i] = uint8(fp >> (8 * uint(i)))
+	}
+	return bands
+}
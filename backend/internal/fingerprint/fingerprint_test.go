@@ -0,0 +1,123 @@
+package fingerprint
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+const guideline = "2 型糖尿病患者的血糖控制目标应个体化。一般成人糖化血红蛋白控制目标为小于 7%，" +
+	"空腹血糖 4.4 至 7.0 mmol/L，非空腹血糖小于 10.0 mmol/L。老年患者、低血糖高风险患者可适当放宽目标。" +
+	"二甲双胍是首选的一线用药，若无禁忌证应一直保留在治疗方案中。"
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"   ":                "",
+		"a  b":               "a b",
+		"\n 第一行\r\n\t第二行 \n": "第一行 第二行",
+		"全角　空格":              "全角 空格",
+	}
+	for in, want := range tests {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a := ContentHash("血糖控制目标\n应个体化")
+	if len(a) != 64 {
+		t.Fatalf("hash length = %d, want 64", len(a))
+	}
+	if b := ContentHash("  血糖控制目标   应个体化\r\n"); b != a {
+		t.Errorf("whitespace changed the hash: %s != %s", b, a)
+	}
+	if c := ContentHash("血糖控制目标应个体化"); c == a {
+		t.Error("removing a word boundary should change the hash")
+	}
+}
+
+func TestSimHash(t *testing.T) {
+	if got := SimHash(""); got != 0 {
+		t.Errorf("SimHash(empty) = %x, want 0", got)
+	}
+	if got := SimHash("，。！ ?"); got != 0 {
+		t.Errorf("SimHash(punctuation) = %x, want 0", got)
+	}
+	if SimHash("ab") == 0 {
+		t.Error("text shorter than a shingle should still hash")
+	}
+
+	base := SimHash(guideline)
+	// 标点、空白和大小写不影响指纹
+	if got := SimHash(strings.NewReplacer("，", ",", "。", ". ", "mmol/L", "MMOL L").Replace(guideline)); got != base {
+		t.Errorf("punctuation/case changed SimHash: %x != %x", got, base)
+	}
+
+	edited := strings.Replace(guideline, "小于 7%", "小于 6.5%", 1)
+	if sim := Similarity(base, SimHash(edited)); sim < 0.85 {
+		t.Errorf("small edit similarity = %.3f, want >= 0.85", sim)
+	}
+	other := SimHash("高血压患者的降压目标一般为 140/90 mmHg 以下，能耐受者可进一步降至 130/80 mmHg 以下。")
+	if sim := Similarity(base, other); sim > 0.8 {
+		t.Errorf("unrelated text similarity = %.3f, want <= 0.8", sim)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want float64
+	}{
+		{0, 0, 1},
+		{0xffff, 0xffff, 1},
+		{0, 1, 1 - 1.0/64},
+		{0, 0xff, 1 - 8.0/64},
+		{0, ^uint64(0), 0},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("Similarity(%x, %x) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBands(t *testing.T) {
+	got := Bands(0x0807060504030201)
+	want := [BandCount]uint8{1, 2, 3, 4, 5, 6, 7, 8}
+	if got != want {
+		t.Errorf("Bands = %v, want %v", got, want)
+	}
+}
+
+// 汉明距离不超过 BandCount-1 时必有一段相同，因此分段查找不会漏掉达到 MinBandedSimilarity 的近似重复
+func TestBandsShareSegmentWithinDistance(t *testing.T) {
+	base := SimHash(guideline)
+	var x uint64 = 0x9e3779b97f4a7c15
+	for n := 0; n < 2000; n++ {
+		// 用简单的 xorshift 生成翻转位的掩码
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+		mask := x
+		for bits.OnesCount64(mask) > BandCount-1 {
+			mask &= mask - 1
+		}
+		other := base ^ mask
+		if Similarity(base, other) < MinBandedSimilarity {
+			t.Fatalf("mask %x: similarity below MinBandedSimilarity", mask)
+		}
+		a, b := Bands(base), Bands(other)
+		shared := false
+		for i := range a {
+			if a[i] == b[i] {
+				shared = true
+				break
+			}
+		}
+		if !shared {
+			t.Fatalf("mask %x (%d bits) leaves no shared band", mask, bits.OnesCount64(mask))
+		}
+	}
+}
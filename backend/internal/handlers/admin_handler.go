@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminHandler 处理仅管理员可用的运维请求
+type AdminHandler struct {
+	reconcileService *services.ReconcileService
+	documentService  *services.DocumentService
+}
+
+func NewAdminHandler(reconcileService *services.ReconcileService, documentService *services.DocumentService) *AdminHandler {
+	return &AdminHandler{
+		reconcileService: reconcileService,
+		documentService:  documentService,
+	}
+}
+
+// Reconcile 执行一次 MySQL/Chroma 对账。默认 dry_run=true，只返回报告
+func (h *AdminHandler) Reconcile(c *gin.Context) {
+	dryRun := true
+	if param := c.Query("dry_run"); param != "" {
+		parsed, err := strconv.ParseBool(param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid dry_run value"})
+			return
+		}
+		dryRun = parsed
+	}
+
+	report, err := h.reconcileService.Run(c.Request.Context(), dryRun)
+	if err != nil {
+		if errors.Is(err, services.ErrReconcileRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.L.Error("reconcile failed",
+			zap.Error(err),
+			zap.Bool("dry_run", dryRun),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ReviewDue 返回已过复审日期的文档报告，支持 limit 和 offset 分页
+func (h *AdminHandler) ReviewDue(c *gin.Context) {
+	opts, err := parseDocumentListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.documentService.ReviewDue(time.Now(), opts.Limit, opts.Offset)
+	if err != nil {
+		logger.L.Error("failed to build review report", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
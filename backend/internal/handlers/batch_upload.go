@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"medical-qa-assistant/internal/extractor"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 压缩包内单个条目允许的最大压缩比，超过视为 zip 炸弹
+const maxArchiveRatio = 100
+
+// BatchUpload 处理批量上传：表单字段 files 可包含多个文件或 ZIP 压缩包，
+// 每个文件（压缩包按其中的文件）创建一篇文档，元数据表单字段对全部文件生效。
+// 单个文件失败只记录在批次条目中，不影响其他文件。
+// 全部文件的原始内容在创建批次前都保留在内存中，压缩包解压后的总量与请求体共用 MaxBatchBytes 上限；
+// 文件总数（压缩包按其中的文件计数）超过 MaxBatchFiles 时在提取完成前即拒绝整个请求。
+// 文档在本请求内同步创建，响应返回时所有文件都已入库，索引仍在后台进行
+func (h *DocumentHandler) BatchUpload(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for batch upload")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxBatchBytes)
+	form, err := c.MultipartForm()
+	if err != nil {
+		if isRequestTooLarge(err) {
+			newUploadError(http.StatusRequestEntityTooLarge, uploadCodeRequestTooLarge,
+				fmt.Sprintf("request body exceeds %d bytes", h.limits.MaxBatchBytes)).respond(c)
+			return
+		}
+		newUploadError(http.StatusBadRequest, uploadCodeInvalidRequest, "invalid multipart form").respond(c)
+		return
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		newUploadError(http.StatusBadRequest, uploadCodeFileRequired, "files are required").respond(c)
+		return
+	}
+	tooManyFiles := newUploadError(http.StatusRequestEntityTooLarge, uploadCodeTooManyFiles,
+		fmt.Sprintf("batch contains more than %d files", h.limits.MaxBatchFiles))
+	if len(files) > h.limits.MaxBatchFiles {
+		tooManyFiles.respond(c)
+		return
+	}
+
+	base, uerr := uploadRequestFromForm(c, "")
+	if uerr != nil {
+		uerr.respond(c)
+		return
+	}
+
+	var entries []services.BatchEntry
+	// 已解压的压缩包字节数，后续压缩包只能使用剩余的额度
+	var expanded int64
+	for _, fileHeader := range files {
+		// 压缩包展开后已用完文件数额度，剩余文件不再读取和提取
+		if len(entries) >= h.limits.MaxBatchFiles {
+			tooManyFiles.respond(c)
+			return
+		}
+		data, uerr := h.readUpload(fileHeader)
+		if uerr != nil {
+			entries = append(entries, rejectedEntry(fileHeader.Filename, uerr))
+			continue
+		}
+		if !extractor.IsZip(data) {
+			entries = append(entries, extractedEntry(fileHeader.Filename, data))
+			continue
+		}
+
+		archiveEntries, size, uerr := h.expandArchive(fileHeader.Filename, data,
+			h.limits.MaxBatchFiles-len(entries), h.limits.MaxBatchBytes-expanded)
+		if uerr != nil {
+			entries = append(entries, rejectedEntry(fileHeader.Filename, uerr))
+			continue
+		}
+		expanded += size
+		entries = append(entries, archiveEntries...)
+	}
+
+	progress, err := h.batchService.CreateBatch(userID.(uint), c.GetString("role"), base, entries)
+	if err != nil {
+		logger.L.Error("failed to create upload batch",
+			zap.Error(err),
+			zap.Uint("user_id", userID.(uint)),
+			zap.Int("files", len(entries)),
+		)
+		createError(err).respond(c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, progress)
+}
+
+// expandArchive 展开压缩包，每个条目单独提取文本，返回条目及解压后的总字节数。
+// 压缩包整体超限（条目数、解压总量超过 maxBytes）或疑似 zip 炸弹时整个压缩包被拒绝
+func (h *DocumentHandler) expandArchive(filename string, data []byte, maxEntries int, maxBytes int64) ([]services.BatchEntry, int64, *uploadError) {
+	limits := extractor.ArchiveLimits{
+		MaxEntries:    max(maxEntries, 0),
+		MaxEntryBytes: h.limits.MaxFileBytes,
+		MaxTotalBytes: max(maxBytes, 0),
+		MaxRatio:      maxArchiveRatio,
+	}
+	var entries []services.BatchEntry
+	var size int64
+	err := extractor.WalkZip(data, limits, func(entry extractor.ArchiveEntry) {
+		if entry.Err != nil {
+			entries = append(entries, rejectedEntry(entry.Name, archiveError(entry.Err)))
+			return
+		}
+		size += int64(len(entry.Data))
+		entries = append(entries, extractedEntry(entry.Name, entry.Data))
+	})
+	if err != nil {
+		logger.L.Warn("rejected uploaded archive",
+			zap.Error(err),
+			zap.String("filename", filename),
+		)
+		return nil, 0, archiveError(err)
+	}
+	return entries, size, nil
+}
+
+func extractedEntry(filename string, data []byte) services.BatchEntry {
+	extracted, uerr := extractUpload(filename, data)
+	if uerr != nil {
+		return rejectedEntry(filename, uerr)
+	}
+	return services.BatchEntry{
+		Filename: filename,
+		Content:  extracted.Text,
+		Encoding: extracted.Encoding,
+		File:     &services.UploadedFile{Name: path.Base(filename), MIMEType: extracted.MIMEType, Data: data},
+	}
+}
+
+func rejectedEntry(filename string, uerr *uploadError) services.BatchEntry {
+	return services.BatchEntry{
+		Filename:     filename,
+		ErrorCode:    uerr.code,
+		ErrorMessage: uerr.message,
+	}
+}
+
+// archiveError 将压缩包错误映射为上传错误
+func archiveError(err error) *uploadError {
+	switch {
+	case errors.Is(err, extractor.ErrArchiveTooLarge):
+		return newUploadError(http.StatusRequestEntityTooLarge, uploadCodeArchiveTooLarge, err.Error())
+	case errors.Is(err, extractor.ErrEntryTooLarge):
+		return newUploadError(http.StatusRequestEntityTooLarge, uploadCodeFileTooLarge, err.Error())
+	case errors.Is(err, extractor.ErrSuspiciousArchive):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeSuspiciousArchive, err.Error())
+	case errors.Is(err, extractor.ErrUnsafePath):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeUnsafePath, err.Error())
+	default:
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeInvalidArchive, err.Error())
+	}
+}
+
+// BatchProgress 返回批量上传的处理进度和每个文件的结果
+func (h *DocumentHandler) BatchProgress(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for batch progress")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	idParam := c.Param("id")
+	batchID, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch id"})
+		return
+	}
+
+	progress, err := h.batchService.Progress(userID.(uint), uint(batchID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+			return
+		}
+		logger.L.Error("failed to load upload batch",
+			zap.Error(err),
+			zap.Uint("user_id", userID.(uint)),
+			zap.Uint64("batch_id", batchID),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
+}
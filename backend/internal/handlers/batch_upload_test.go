@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"medical-qa-assistant/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type testFile struct {
+	field string
+	name  string
+	data  []byte
+}
+
+// multipartBody 构造 multipart 请求体，返回请求体和 Content-Type
+func multipartBody(t *testing.T, fields map[string]string, files ...testFile) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key, value := range fields {
+		if err := w.WriteField(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range files {
+		part, err := w.CreateFormFile(f.field, f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(f.data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &body, w.FormDataContentType()
+}
+
+// serve 以已登录的普通用户身份调用 handler，返回响应
+func serve(t *testing.T, handler gin.HandlerFunc, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	logger.L = zap.NewNop()
+	r := gin.New()
+	r.POST("/", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Set("role", "user")
+	}, handler)
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+// errorCode 返回响应中的错误码
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return body.Code
+}
+
+func zipOf(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("胰岛素剂量调整"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// 文件数超过上限时在创建批次前拒绝整个请求，无需调用服务层
+func TestBatchUploadTooManyFiles(t *testing.T) {
+	h := NewDocumentHandler(nil, nil, UploadLimits{MaxFileBytes: 1 << 20, MaxRequestBytes: 1 << 20, MaxBatchBytes: 4 << 20, MaxBatchFiles: 2})
+	text := []byte("胰岛素剂量调整")
+	tests := []struct {
+		name  string
+		files []testFile
+	}{
+		{"loose files", []testFile{{"files", "a.txt", text}, {"files", "b.txt", text}, {"files", "c.txt", text}}},
+		// 压缩包展开后已占满额度，之后的文件不再读取
+		{"archive fills the batch", []testFile{{"files", "docs.zip", zipOf(t, "a.txt", "b.txt")}, {"files", "c.txt", text}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := multipartBody(t, nil, tt.files...)
+			rec := serve(t, h.BatchUpload, body, contentType)
+			if rec.Code != http.StatusRequestEntityTooLarge || errorCode(t, rec) != uploadCodeTooManyFiles {
+				t.Errorf("status %d body %s, want 413 %s", rec.Code, rec.Body, uploadCodeTooManyFiles)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Chunks 返回文档在 Chroma 中的分块（文本、元数据、字符偏移和向量维度），按 chunk_index 排序，
+// 支持 offset 和 limit 分页，用于排查某段内容为何能或不能被检索到
+func (h *DocumentHandler) Chunks(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+	opts, err := parseDocumentListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	chunks, err := h.documentService.Chunks(c.Request.Context(), userID, docID, opts.Offset, opts.Limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		case errors.Is(err, services.ErrSearchUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			logger.L.Error("failed to list document chunks",
+				zap.Error(err),
+				zap.Uint("user_id", userID),
+				zap.Uint("document_id", docID),
+			)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to read chunks from vector store"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, chunks)
+}
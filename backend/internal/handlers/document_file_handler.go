@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DownloadFile 以附件形式返回文档的原始上传文件，只有上传者可以下载。
+// 下载过程中重新计算 SHA-256，与上传时记录的校验和不一致时记录错误日志
+func (h *DocumentHandler) DownloadFile(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.documentService.OpenFile(c.Request.Context(), userID, docID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		case errors.Is(err, services.ErrFileNotStored):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			logger.L.Error("failed to open original file",
+				zap.Error(err),
+				zap.Uint("user_id", userID),
+				zap.Uint("document_id", docID),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open original file"})
+		}
+		return
+	}
+	defer file.Body.Close()
+
+	etag := `"` + file.SHA256 + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	contentType := file.MIMEType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": file.Name})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", disposition)
+	c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+	c.Header("ETag", etag)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	hash := sha256.New()
+	written, err := io.Copy(c.Writer, io.TeeReader(file.Body, hash))
+	if err != nil {
+		logger.L.Warn("original file download interrupted",
+			zap.Error(err),
+			zap.Uint("document_id", docID),
+			zap.Int64("written", written),
+		)
+		return
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != file.SHA256 || written != file.Size {
+		logger.L.Error("original file checksum mismatch",
+			zap.Uint("document_id", docID),
+			zap.String("expected_sha256", file.SHA256),
+			zap.String("actual_sha256", sum),
+			zap.Int64("expected_size", file.Size),
+			zap.Int64("actual_size", written),
+		)
+	}
+}
@@ -1,25 +1,38 @@
 package handlers
 
 import (
-	"io"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
 	"medical-qa-assistant/internal/services"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
+// SSE 心跳间隔，防止代理因连接空闲而断开
+const documentEventsHeartbeat = 25 * time.Second
+
 // DocumentHandler 处理与文档相关的 HTTP 请求
 type DocumentHandler struct {
 	documentService *services.DocumentService
+	batchService    *services.UploadBatchService
+	limits          UploadLimits
 }
 
-func NewDocumentHandler(documentService *services.DocumentService) *DocumentHandler {
+func NewDocumentHandler(documentService *services.DocumentService, batchService *services.UploadBatchService, limits UploadLimits) *DocumentHandler {
 	return &DocumentHandler{
 		documentService: documentService,
+		batchService:    batchService,
+		limits:          limits,
 	}
 }
 
@@ -40,13 +53,13 @@ func (h *DocumentHandler) Create(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.documentService.Create(userID.(uint), &req)
+	doc, err := h.documentService.Create(userID.(uint), c.GetString("role"), &req)
 	if err != nil {
 		logger.L.Error("failed to create document",
 			zap.Error(err),
 			zap.Uint("user_id", userID.(uint)),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(documentErrorStatus(err), documentErrorBody(err))
 		return
 	}
 
@@ -61,7 +74,18 @@ func (h *DocumentHandler) List(c *gin.Context) {
 		return
 	}
 
-	docs, err := h.documentService.List(userID.(uint))
+	filter, err := parseDocumentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseDocumentListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.documentService.List(userID.(uint), filter, opts)
 	if err != nil {
 		logger.L.Error("failed to list documents",
 			zap.Error(err),
@@ -71,7 +95,45 @@ func (h *DocumentHandler) List(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, docs)
+	c.JSON(http.StatusOK, page)
+}
+
+// Search 按内容和标题检索可访问的文档（GET /documents/search?q=...），
+// 支持与列表相同的过滤参数，结果带有高亮的内容片段
+func (h *DocumentHandler) Search(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for document search")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	filter, err := parseDocumentFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts, err := parseDocumentListOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.documentService.Search(userID.(uint), c.Query("q"), filter, opts.Limit, opts.Offset)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.L.Error("failed to search documents",
+			zap.Error(err),
+			zap.Uint("user_id", userID.(uint)),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search documents"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
 func (h *DocumentHandler) Get(c *gin.Context) {
@@ -124,7 +186,7 @@ func (h *DocumentHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.documentService.Delete(userID.(uint), uint(docID)); err != nil {
+	if err := h.documentService.Delete(userID.(uint), c.GetString("role"), uint(docID)); err != nil {
 		logger.L.Error("failed to delete document",
 			zap.Error(err),
 			zap.Uint("user_id", userID.(uint)),
@@ -137,7 +199,8 @@ func (h *DocumentHandler) Delete(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-// Upload 处理多部分文档上传
+// Upload 处理多部分文档上传。请求体和单个文件的大小在读取过程中受限，
+// 文件类型按内容嗅探，错误响应带有机器可读的 code 字段
 func (h *DocumentHandler) Upload(c *gin.Context) {
 	userID, ok := c.Get("user_id")
 	if !ok {
@@ -146,12 +209,28 @@ func (h *DocumentHandler) Upload(c *gin.Context) {
 		return
 	}
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxRequestBytes)
 	fileHeader, err := c.FormFile("file")
 	if err != nil {
+		if isRequestTooLarge(err) {
+			h.requestTooLarge().respond(c)
+			return
+		}
 		logger.L.Warn("file missing in upload request",
 			zap.Error(err),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		newUploadError(http.StatusBadRequest, uploadCodeFileRequired, "file is required").respond(c)
+		return
+	}
+
+	data, uerr := h.readUpload(fileHeader)
+	if uerr != nil {
+		uerr.respond(c)
+		return
+	}
+	extracted, uerr := extractUpload(fileHeader.Filename, data)
+	if uerr != nil {
+		uerr.respond(c)
 		return
 	}
 
@@ -159,43 +238,324 @@ func (h *DocumentHandler) Upload(c *gin.Context) {
 	if strings.TrimSpace(title) == "" {
 		title = fileHeader.Filename
 	}
+	req, uerr := uploadRequestFromForm(c, title)
+	if uerr != nil {
+		uerr.respond(c)
+		return
+	}
+	req.Content = extracted.Text
+	req.Encoding = extracted.Encoding
+	req.File = &services.UploadedFile{Name: fileHeader.Filename, MIMEType: extracted.MIMEType, Data: data}
 
-	file, err := fileHeader.Open()
+	doc, err := h.documentService.Create(userID.(uint), c.GetString("role"), req)
 	if err != nil {
-		logger.L.Error("failed to open uploaded file",
+		logger.L.Error("failed to create document from upload",
 			zap.Error(err),
-			zap.String("filename", fileHeader.Filename),
+			zap.Uint("user_id", userID.(uint)),
+			zap.String("title", title),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to open file"})
+		createError(err).respond(c)
+		return
+	}
+
+	c.JSON(http.StatusCreated, doc)
+}
+
+// Update 修改文档的标题、内容和元数据（PUT /documents/:id），文档 ID 保持不变。
+// 内容变化时文档重新进入索引流程，只改标题或元数据时不重新嵌入
+func (h *DocumentHandler) Update(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for document update")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
 		return
 	}
-	defer file.Close()
 
-	contentBytes, err := io.ReadAll(file)
+	idParam := c.Param("id")
+	docID, err := strconv.ParseUint(idParam, 10, 64)
 	if err != nil {
-		logger.L.Error("failed to read uploaded file",
-			zap.Error(err),
-			zap.String("filename", fileHeader.Filename),
+		logger.L.Warn("invalid document id in update",
+			zap.String("id", idParam),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
 		return
 	}
 
-	req := services.CreateDocumentRequest{
-		Title:   title,
-		Content: string(contentBytes),
+	var req services.UpdateDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.L.Warn("invalid document update request",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	doc, err := h.documentService.Create(userID.(uint), &req)
+	doc, err := h.documentService.Update(userID.(uint), c.GetString("role"), uint(docID), &req)
 	if err != nil {
-		logger.L.Error("failed to create document from upload",
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+			return
+		}
+		logger.L.Error("failed to update document",
 			zap.Error(err),
 			zap.Uint("user_id", userID.(uint)),
-			zap.String("title", title),
+			zap.Uint("document_id", uint(docID)),
+		)
+		c.JSON(documentErrorStatus(err), documentErrorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// UpdateMetadata 修改文档的标签、类别、出版年份、来源、复审日期和取代关系
+func (h *DocumentHandler) UpdateMetadata(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for document metadata update")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	idParam := c.Param("id")
+	docID, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		logger.L.Warn("invalid document id in metadata update",
+			zap.String("id", idParam),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		return
+	}
+
+	var req services.UpdateDocumentMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.L.Warn("invalid document metadata update request",
+			zap.Error(err),
 		)
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, doc)
+	doc, err := h.documentService.UpdateMetadata(userID.(uint), c.GetString("role"), uint(docID), &req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+			return
+		}
+		logger.L.Error("failed to update document metadata",
+			zap.Error(err),
+			zap.Uint("user_id", userID.(uint)),
+			zap.Uint("document_id", uint(docID)),
+		)
+		c.JSON(documentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// Duplicates 返回当前用户文档中完全重复和近似重复的报告
+func (h *DocumentHandler) Duplicates(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for duplicates report")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	report, err := h.documentService.DuplicatesReport(userID.(uint))
+	if err != nil {
+		logger.L.Error("failed to build duplicates report",
+			zap.Error(err),
+			zap.Uint("user_id", userID.(uint)),
+		)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// parseDocumentFilter 从查询参数解析列表过滤条件：tags（逗号分隔）、category、year_from、year_to
+func parseDocumentFilter(c *gin.Context) (repositories.DocumentFilter, error) {
+	filter := repositories.DocumentFilter{
+		Tags:     splitList(c.Query("tags")),
+		Category: c.Query("category"),
+	}
+	if v := c.Query("year_from"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("invalid year_from")
+		}
+		filter.YearFrom = year
+	}
+	if v := c.Query("year_to"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, errors.New("invalid year_to")
+		}
+		filter.YearTo = year
+	}
+	for _, status := range splitList(c.Query("status")) {
+		switch status {
+		case models.DocumentStatusPending, models.DocumentStatusProcessing, models.DocumentStatusReady, models.DocumentStatusFailed:
+			filter.Statuses = append(filter.Statuses, status)
+		default:
+			return filter, fmt.Errorf("invalid status %q", status)
+		}
+	}
+	if v := c.Query("created_from"); v != "" {
+		t, _, err := parseListTime(v)
+		if err != nil {
+			return filter, errors.New("invalid created_from, expected YYYY-MM-DD or RFC 3339")
+		}
+		filter.CreatedFrom = &t
+	}
+	if v := c.Query("created_to"); v != "" {
+		t, dateOnly, err := parseListTime(v)
+		if err != nil {
+			return filter, errors.New("invalid created_to, expected YYYY-MM-DD or RFC 3339")
+		}
+		// 只给日期时包含当天
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.CreatedTo = &t
+	}
+	filter.Title = strings.TrimSpace(c.Query("title"))
+	return filter, nil
+}
+
+// parseListTime 解析 YYYY-MM-DD（按本地时区）或 RFC 3339 时间，dateOnly 表示只给了日期
+func parseListTime(v string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, v)
+	return t, false, err
+}
+
+// parseDocumentListOptions 读取分页和排序参数：limit、offset、cursor、sort、order
+func parseDocumentListOptions(c *gin.Context) (services.DocumentListOptions, error) {
+	opts := services.DocumentListOptions{
+		Sort:   c.Query("sort"),
+		Order:  c.Query("order"),
+		Cursor: c.Query("cursor"),
+	}
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return opts, errors.New("invalid limit")
+		}
+		opts.Limit = limit
+	}
+	if v := c.Query("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return opts, errors.New("invalid offset")
+		}
+		opts.Offset = offset
+	}
+	return opts, nil
+}
+
+// splitList 拆分逗号分隔的表单值（标签、作者等），兼容中文逗号
+func splitList(raw string) []string {
+	raw = strings.ReplaceAll(raw, "，", ",")
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// documentErrorStatus 将服务层错误映射为 HTTP 状态码
+func documentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrDuplicateDocument):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrTitleTooLong):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadRequest
+}
+
+// documentErrorBody 返回错误响应体，重复文档错误附带已存在文档的 ID
+func documentErrorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var dup *services.DuplicateDocumentError
+	if errors.As(err, &dup) {
+		body["duplicate_of"] = dup.ExistingID
+	}
+	return body
+}
+
+// Events 通过 SSE 推送当前用户文档的索引状态变化
+func (h *DocumentHandler) Events(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for document events")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	// 设置 SSE 响应头
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁用 nginx 缓冲
+	c.Header("Access-Control-Allow-Origin", "*")
+
+	events, unsubscribe := h.documentService.Subscribe(userID.(uint))
+	defer unsubscribe()
+
+	logger.L.Info("document events subscribed",
+		zap.Uint("user_id", userID.(uint)),
+	)
+
+	// 先发送一条注释让客户端确认连接已建立
+	c.Writer.WriteString(": connected\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(documentEventsHeartbeat)
+	defer heartbeat.Stop()
+
+	// 使用请求上下文处理客户端断开连接
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			logger.L.Info("document events unsubscribed by client",
+				zap.Uint("user_id", userID.(uint)),
+			)
+			return
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				logger.L.Error("failed to marshal document event",
+					zap.Error(err),
+					zap.Uint("document_id", event.DocumentID),
+				)
+				continue
+			}
+
+			// 写入 SSE 格式：data: {...}\n\n
+			if _, err := c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", string(data))); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
 }
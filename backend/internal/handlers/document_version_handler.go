@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ListVersions 返回文档的版本列表（不含内容），按版本号倒序
+func (h *DocumentHandler) ListVersions(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.documentService.ListVersions(userID, docID)
+	if err != nil {
+		respondVersionError(c, err, userID, docID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// GetVersion 返回文档指定版本的标题和内容
+func (h *DocumentHandler) GetVersion(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
+	v, err := h.documentService.GetVersion(userID, docID, version)
+	if err != nil {
+		respondVersionError(c, err, userID, docID)
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+// DiffVersions 比较两个版本的内容：?from=1&to=3，to 省略时与当前版本比较
+func (h *DocumentHandler) DiffVersions(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from version"})
+		return
+	}
+	to := 0
+	if param := c.Query("to"); param != "" {
+		to, err = strconv.Atoi(param)
+		if err != nil || to <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to version"})
+			return
+		}
+	} else {
+		doc, err := h.documentService.Get(userID, docID)
+		if err != nil {
+			respondVersionError(c, err, userID, docID)
+			return
+		}
+		to = doc.Version
+	}
+
+	diff, err := h.documentService.DiffVersions(userID, docID, from, to)
+	if err != nil {
+		respondVersionError(c, err, userID, docID)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreVersion 将文档恢复为指定版本的内容。恢复会生成一个新版本并重新索引，历史版本保持不变
+func (h *DocumentHandler) RestoreVersion(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid version"})
+		return
+	}
+
+	doc, err := h.documentService.RestoreVersion(userID, c.GetString("role"), docID, version)
+	if err != nil {
+		respondVersionError(c, err, userID, docID)
+		return
+	}
+
+	logger.L.Info("document version restored",
+		zap.Uint("user_id", userID),
+		zap.Uint("document_id", docID),
+		zap.Int("restored_from", version),
+		zap.Int("version", doc.Version),
+	)
+	c.JSON(http.StatusOK, doc)
+}
+
+// ListRedactions 返回文档的身份信息去除报告（类别、次数和位置，不含原值）
+func (h *DocumentHandler) ListRedactions(c *gin.Context) {
+	userID, docID, ok := documentParams(c)
+	if !ok {
+		return
+	}
+
+	redactions, err := h.documentService.ListRedactions(userID, docID)
+	if err != nil {
+		respondVersionError(c, err, userID, docID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"redactions": redactions})
+}
+
+// documentParams 读取当前用户和路径中的文档 ID，失败时已写入响应
+func documentParams(c *gin.Context) (uint, uint, bool) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for document request")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return 0, 0, false
+	}
+	idParam := c.Param("id")
+	docID, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid document id"})
+		return 0, 0, false
+	}
+	return userID.(uint), uint(docID), true
+}
+
+func respondVersionError(c *gin.Context, err error, userID, docID uint) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+	case errors.Is(err, services.ErrVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		logger.L.Warn("document version request failed",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Uint("document_id", docID),
+		)
+		c.JSON(documentErrorStatus(err), documentErrorBody(err))
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// KnowledgeBaseHandler 处理知识库相关的 HTTP 请求
+type KnowledgeBaseHandler struct {
+	kbService *services.KnowledgeBaseService
+}
+
+func NewKnowledgeBaseHandler(kbService *services.KnowledgeBaseService) *KnowledgeBaseHandler {
+	return &KnowledgeBaseHandler{kbService: kbService}
+}
+
+func (h *KnowledgeBaseHandler) Create(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for knowledge base create")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	var req services.KnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.L.Warn("invalid create knowledge base request",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kb, err := h.kbService.Create(userID.(uint), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, kb)
+}
+
+func (h *KnowledgeBaseHandler) List(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for knowledge base list")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	kbs, err := h.kbService.List(userID.(uint))
+	if err != nil {
+		logger.L.Error("failed to list knowledge bases",
+			zap.Error(err),
+			zap.Uint("user_id", userID.(uint)),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, kbs)
+}
+
+func (h *KnowledgeBaseHandler) Get(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for knowledge base get")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	kbID, ok := parseKnowledgeBaseID(c)
+	if !ok {
+		return
+	}
+
+	kb, err := h.kbService.Get(userID.(uint), kbID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "knowledge base not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, kb)
+}
+
+func (h *KnowledgeBaseHandler) Update(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for knowledge base update")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	kbID, ok := parseKnowledgeBaseID(c)
+	if !ok {
+		return
+	}
+
+	var req services.KnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.L.Warn("invalid update knowledge base request",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	kb, err := h.kbService.Update(userID.(uint), kbID, &req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "knowledge base not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, kb)
+}
+
+func (h *KnowledgeBaseHandler) Delete(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for knowledge base delete")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	kbID, ok := parseKnowledgeBaseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.kbService.Delete(userID.(uint), kbID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "knowledge base not found"})
+		case errors.Is(err, services.ErrKnowledgeBaseNotEmpty):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			logger.L.Error("failed to delete knowledge base",
+				zap.Error(err),
+				zap.Uint("user_id", userID.(uint)),
+				zap.Uint("knowledge_base_id", kbID),
+			)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseKnowledgeBaseID(c *gin.Context) (uint, bool) {
+	idParam := c.Param("id")
+	kbID, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		logger.L.Warn("invalid knowledge base id",
+			zap.String("id", idParam),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid knowledge base id"})
+		return 0, false
+	}
+	return uint(kbID), true
+}
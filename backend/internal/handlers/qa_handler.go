@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"medical-qa-assistant/internal/logger"
 	"medical-qa-assistant/internal/services"
@@ -38,7 +39,12 @@ func (h *QAHandler) Ask(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.qaService.Ask(context.Background(), userID.(uint), req.Question)
+	resp, err := h.qaService.Ask(context.Background(), userID.(uint), &req)
+	var phiErr *services.QuestionPHIError
+	if errors.As(err, &phiErr) {
+		c.JSON(http.StatusUnprocessableEntity, questionPHIBody(phiErr))
+		return
+	}
 	if err != nil {
 		logger.L.Error("QA Ask failed",
 			zap.Error(err),
@@ -79,8 +85,27 @@ func (h *QAHandler) AskStream(c *gin.Context) {
 	// 使用请求上下文处理客户端断开连接
 	ctx := c.Request.Context()
 
-	// 流式传输响应
-	err := h.qaService.AskStream(ctx, userID.(uint), req.Question, func(chunk string) error {
+	// 先发送引用来源，再流式传输响应
+	writeSources := func(sources []services.Source, conversationID string, redaction *services.QuestionRedaction) error {
+		event := map[string]interface{}{"sources": sources}
+		if conversationID != "" {
+			event["conversation_id"] = conversationID
+		}
+		if redaction != nil {
+			event["redaction"] = redaction
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal sources: %w", err)
+		}
+		if _, err := c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", string(data))); err != nil {
+			return fmt.Errorf("failed to write sources: %w", err)
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	err := h.qaService.AskStream(ctx, userID.(uint), &req, writeSources, func(chunk string) error {
 		// 检查上下文是否已取消（客户端断开连接）
 		select {
 		case <-ctx.Done():
@@ -115,7 +140,12 @@ func (h *QAHandler) AskStream(c *gin.Context) {
 		}
 
 		// 将错误作为 SSE 事件发送
-		errorData, _ := json.Marshal(map[string]string{"error": err.Error()})
+		var errorBody interface{} = map[string]string{"error": err.Error()}
+		var phiErr *services.QuestionPHIError
+		if errors.As(err, &phiErr) {
+			errorBody = questionPHIBody(phiErr)
+		}
+		errorData, _ := json.Marshal(errorBody)
 		c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", string(errorData)))
 		c.Writer.Flush()
 		return
@@ -126,3 +156,8 @@ func (h *QAHandler) AskStream(c *gin.Context) {
 	c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", string(doneData)))
 	c.Writer.Flush()
 }
+
+// questionPHIBody 是提问因包含身份信息被拒绝时的响应体
+func questionPHIBody(err *services.QuestionPHIError) gin.H {
+	return gin.H{"error": err.Error(), "code": "question_contains_phi", "kinds": err.Kinds}
+}
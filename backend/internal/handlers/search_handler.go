@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// SearchHandler 处理语义检索请求
+type SearchHandler struct {
+	searchService *services.SearchService
+}
+
+func NewSearchHandler(searchService *services.SearchService) *SearchHandler {
+	return &SearchHandler{searchService: searchService}
+}
+
+// Search 返回与查询语义相关的文档片段，不调用大模型生成回答
+func (h *SearchHandler) Search(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		logger.L.Error("user id missing in context for semantic search")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found in context"})
+		return
+	}
+
+	var req services.SearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.L.Warn("invalid semantic search request",
+			zap.Error(err),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.searchService.Search(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidSearch), errors.Is(err, services.ErrEmptyQuery),
+			errors.Is(err, services.ErrKnowledgeBaseNotFound):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSearchUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrSearchBackend):
+			logger.L.Error("semantic search failed",
+				zap.Error(err),
+				zap.Uint("user_id", userID.(uint)),
+			)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to query vector store"})
+		default:
+			logger.L.Error("semantic search failed",
+				zap.Error(err),
+				zap.Uint("user_id", userID.(uint)),
+			)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search documents"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"medical-qa-assistant/internal/extractor"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/services"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"go.uber.org/zap"
+)
+
+// 上传失败时返回的机器可读错误码
+const (
+	uploadCodeFileRequired       = "file_required"
+	uploadCodeInvalidRequest     = "invalid_request"
+	uploadCodeForbidden          = "forbidden"
+	uploadCodeDuplicate          = "duplicate_document"
+	uploadCodeFileTooLarge       = "file_too_large"
+	uploadCodeRequestTooLarge    = "request_too_large"
+	uploadCodeUnsupportedType    = "unsupported_media_type"
+	uploadCodeEmptyContent       = "empty_content"
+	uploadCodeBinaryContent      = "binary_content"
+	uploadCodeNoExtractableText  = "no_extractable_text"
+	uploadCodeUnknownEncoding    = "unknown_encoding"
+	uploadCodeEncryptedDocument  = "encrypted_document"
+	uploadCodeUnreadableDocument = "unreadable_document"
+	uploadCodeTooManyFiles       = "too_many_files"
+	uploadCodeInvalidArchive     = "invalid_archive"
+	uploadCodeArchiveTooLarge    = "archive_too_large"
+	uploadCodeSuspiciousArchive  = "suspicious_archive"
+	uploadCodeUnsafePath         = "unsafe_path"
+	uploadCodeTitleTooLong       = "title_too_long"
+)
+
+// UploadLimits 是上传大小限制（字节）
+type UploadLimits struct {
+	MaxFileBytes    int64 // 单个文件（压缩包内的条目按解压后大小计算）
+	MaxRequestBytes int64 // 单文件上传的请求体
+	MaxBatchBytes   int64 // 批量上传的请求体，以及每个压缩包解压后的总大小
+	MaxBatchFiles   int   // 批量上传的文件总数（压缩包按其中的文件计数）
+}
+
+// uploadError 是上传失败的响应
+type uploadError struct {
+	status      int
+	code        string
+	message     string
+	mimeType    string
+	duplicateOf uint
+}
+
+func newUploadError(status int, code, message string) *uploadError {
+	return &uploadError{status: status, code: code, message: message}
+}
+
+func (e *uploadError) respond(c *gin.Context) {
+	body := gin.H{"error": e.message, "code": e.code}
+	if e.mimeType != "" {
+		body["mime_type"] = e.mimeType
+	}
+	if e.duplicateOf != 0 {
+		body["duplicate_of"] = e.duplicateOf
+	}
+	c.JSON(e.status, body)
+}
+
+// createError 包装创建文档时的服务层错误
+func createError(err error) *uploadError {
+	status := documentErrorStatus(err)
+	code := uploadCodeInvalidRequest
+	switch status {
+	case http.StatusForbidden:
+		code = uploadCodeForbidden
+	case http.StatusConflict:
+		code = uploadCodeDuplicate
+	case http.StatusUnprocessableEntity:
+		code = uploadCodeTitleTooLong
+	}
+	uerr := newUploadError(status, code, err.Error())
+	var dup *services.DuplicateDocumentError
+	if errors.As(err, &dup) {
+		uerr.duplicateOf = dup.ExistingID
+	}
+	return uerr
+}
+
+// isRequestTooLarge 判断解析表单失败是否因为请求体超过 MaxBytesReader 的限制
+func isRequestTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+func (h *DocumentHandler) requestTooLarge() *uploadError {
+	return newUploadError(http.StatusRequestEntityTooLarge, uploadCodeRequestTooLarge,
+		fmt.Sprintf("request body exceeds %d bytes", h.limits.MaxRequestBytes))
+}
+
+// readUpload 读取上传的文件，不超过单文件上限
+func (h *DocumentHandler) readUpload(fileHeader *multipart.FileHeader) ([]byte, *uploadError) {
+	tooLarge := newUploadError(http.StatusRequestEntityTooLarge, uploadCodeFileTooLarge,
+		fmt.Sprintf("file %s exceeds %d bytes", fileHeader.Filename, h.limits.MaxFileBytes))
+	if fileHeader.Size > h.limits.MaxFileBytes {
+		return nil, tooLarge
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		logger.L.Error("failed to open uploaded file",
+			zap.Error(err),
+			zap.String("filename", fileHeader.Filename),
+		)
+		return nil, newUploadError(http.StatusBadRequest, uploadCodeInvalidRequest, "failed to open file")
+	}
+	defer file.Close()
+
+	// 多读一个字节以判断是否超限，声明的 Size 不可信
+	data, err := io.ReadAll(io.LimitReader(file, h.limits.MaxFileBytes+1))
+	if err != nil {
+		if isRequestTooLarge(err) {
+			return nil, h.requestTooLarge()
+		}
+		logger.L.Error("failed to read uploaded file",
+			zap.Error(err),
+			zap.String("filename", fileHeader.Filename),
+		)
+		return nil, newUploadError(http.StatusBadRequest, uploadCodeInvalidRequest, "failed to read file")
+	}
+	if int64(len(data)) > h.limits.MaxFileBytes {
+		return nil, tooLarge
+	}
+	return data, nil
+}
+
+// extractUpload 按嗅探到的文件类型提取文本，而不是把二进制内容直接当作文本
+func extractUpload(filename string, data []byte) (*extractor.Result, *uploadError) {
+	extracted, err := extractor.Extract(data)
+	if err != nil {
+		logger.L.Warn("failed to extract text from uploaded file",
+			zap.Error(err),
+			zap.String("filename", filename),
+			zap.String("mime_type", extracted.MIMEType),
+		)
+		uerr := extractionError(err, extracted.MIMEType)
+		uerr.mimeType = extracted.MIMEType
+		return nil, uerr
+	}
+	return extracted, nil
+}
+
+// extractionError 将提取失败映射为 415（类型不支持）、413（解压后超限）或 422（类型支持但内容不可用）
+func extractionError(err error, mimeType string) *uploadError {
+	switch {
+	case errors.Is(err, extractor.ErrUnsupportedType):
+		return newUploadError(http.StatusUnsupportedMediaType, uploadCodeUnsupportedType,
+			fmt.Sprintf("unsupported file type %s, expected plain text, PDF, DOCX or HTML", mimeType))
+	case errors.Is(err, extractor.ErrEmptyContent):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeEmptyContent, err.Error())
+	case errors.Is(err, extractor.ErrBinaryContent):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeBinaryContent, err.Error())
+	case errors.Is(err, extractor.ErrNoText):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeNoExtractableText, err.Error())
+	case errors.Is(err, extractor.ErrUnknownEncoding):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeUnknownEncoding, err.Error())
+	case errors.Is(err, extractor.ErrEncryptedPDF):
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeEncryptedDocument, err.Error())
+	case errors.Is(err, extractor.ErrPDFTooLarge):
+		return newUploadError(http.StatusRequestEntityTooLarge, uploadCodeFileTooLarge, err.Error())
+	default:
+		return newUploadError(http.StatusUnprocessableEntity, uploadCodeUnreadableDocument, err.Error())
+	}
+}
+
+// uploadRequestFromForm 从表单字段构建创建文档请求（不含内容），并校验元数据
+func uploadRequestFromForm(c *gin.Context, title string) (*services.CreateDocumentRequest, *uploadError) {
+	invalid := func(message string) *uploadError {
+		return newUploadError(http.StatusBadRequest, uploadCodeInvalidRequest, message)
+	}
+
+	visibility := c.PostForm("visibility")
+	if visibility != "" && visibility != models.DocumentVisibilityPrivate && visibility != models.DocumentVisibilityShared {
+		return nil, invalid("invalid visibility")
+	}
+
+	req := &services.CreateDocumentRequest{
+		Title:      title,
+		Visibility: visibility,
+	}
+	req.Tags = splitList(c.PostForm("tags"))
+	req.Category = c.PostForm("category")
+	req.Source = c.PostForm("source")
+	if yearParam := c.PostForm("publication_year"); yearParam != "" {
+		year, err := strconv.Atoi(yearParam)
+		if err != nil {
+			return nil, invalid("invalid publication year")
+		}
+		req.PublicationYear = year
+	}
+	req.Provenance = services.DocumentProvenanceInput{
+		Publisher:   c.PostForm("publisher"),
+		Authors:     splitList(c.PostForm("authors")),
+		PublishedOn: c.PostForm("published_on"),
+		Edition:     c.PostForm("edition"),
+		OriginalURL: c.PostForm("original_url"),
+		License:     c.PostForm("license"),
+	}
+	if err := binding.Validator.ValidateStruct(&req.DocumentMetadataInput); err != nil {
+		return nil, invalid(err.Error())
+	}
+	if err := binding.Validator.ValidateStruct(&req.Provenance); err != nil {
+		return nil, invalid(err.Error())
+	}
+	req.ReviewBy = c.PostForm("review_by")
+	if supersededParam := c.PostForm("superseded_by_id"); supersededParam != "" {
+		supersededID, err := strconv.ParseUint(supersededParam, 10, 64)
+		if err != nil {
+			return nil, invalid("invalid superseded_by_id")
+		}
+		id := uint(supersededID)
+		req.SupersededByID = &id
+	}
+	if kbParam := c.PostForm("knowledge_base_id"); kbParam != "" {
+		kbID, err := strconv.ParseUint(kbParam, 10, 64)
+		if err != nil {
+			return nil, invalid("invalid knowledge base id")
+		}
+		id := uint(kbID)
+		req.KnowledgeBaseID = &id
+	}
+	return req, nil
+}
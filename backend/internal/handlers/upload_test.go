@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"medical-qa-assistant/internal/services"
+)
+
+// 上传测试使用的大小限制
+var testUploadLimits = UploadLimits{MaxFileBytes: 1024, MaxRequestBytes: 4096, MaxBatchBytes: 8192, MaxBatchFiles: 10}
+
+// newUploadTestHandler 返回不连接数据库的 DocumentHandler；用例中的请求都在写库之前失败
+func newUploadTestHandler() *DocumentHandler {
+	documentService := services.NewDocumentService(nil, nil, nil, nil, nil, nil, nil, nil, services.DuplicateOptions{}, nil, nil)
+	return NewDocumentHandler(documentService, nil, testUploadLimits)
+}
+
+const (
+	encryptedPDF = "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Filter /Standard /V 2 >>\nendobj\ntrailer\n<< /Root 1 0 R /Encrypt 2 0 R >>\n%%EOF\n"
+	noPagesPDF    = "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n"
+	malformedPDF  = "%PDF-1.4\n999999999999999 0 obj<<>>endobj"
+	pngHeader     = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00"
+	validUTF8Text = "胰岛素剂量应根据血糖监测结果调整。"
+)
+
+func TestUploadErrors(t *testing.T) {
+	h := newUploadTestHandler()
+	tests := []struct {
+		name   string
+		fields map[string]string
+		files  []testFile
+		status int
+		code   string
+	}{
+		{"no file", map[string]string{"title": "指南"}, nil, http.StatusBadRequest, uploadCodeFileRequired},
+		{"file too large", nil, []testFile{{"file", "a.txt", bytes.Repeat([]byte("a"), 1025)}},
+			http.StatusRequestEntityTooLarge, uploadCodeFileTooLarge},
+		// 请求体超过 MaxRequestBytes 时在解析表单的过程中就被截断
+		{"request too large", nil, []testFile{{"file", "a.txt", bytes.Repeat([]byte("a"), 8192)}},
+			http.StatusRequestEntityTooLarge, uploadCodeRequestTooLarge},
+		{"unsupported type", nil, []testFile{{"file", "scan.png", []byte(pngHeader)}},
+			http.StatusUnsupportedMediaType, uploadCodeUnsupportedType},
+		{"empty content", nil, []testFile{{"file", "a.txt", []byte(" \n\t\n ")}},
+			http.StatusUnprocessableEntity, uploadCodeEmptyContent},
+		// 嗅探只看开头 512 字节，后部混入的控制字符在提取后才被发现
+		{"binary content", nil, []testFile{{"file", "a.txt", append(bytes.Repeat([]byte("a"), 600), bytes.Repeat([]byte{0x01}, 200)...)}},
+			http.StatusUnprocessableEntity, uploadCodeBinaryContent},
+		{"unknown encoding", nil, []testFile{{"file", "a.txt", bytes.Repeat([]byte{0x81, 0x30, 0xff, 0xfe, 0x80, 0x20}, 20)}},
+			http.StatusUnprocessableEntity, uploadCodeUnknownEncoding},
+		{"no extractable text", nil, []testFile{{"file", "scan.pdf", []byte(noPagesPDF)}},
+			http.StatusUnprocessableEntity, uploadCodeNoExtractableText},
+		{"encrypted document", nil, []testFile{{"file", "secret.pdf", []byte(encryptedPDF)}},
+			http.StatusUnprocessableEntity, uploadCodeEncryptedDocument},
+		{"unreadable document", nil, []testFile{{"file", "broken.pdf", []byte(malformedPDF)}},
+			http.StatusUnprocessableEntity, uploadCodeUnreadableDocument},
+		{"invalid visibility", map[string]string{"visibility": "public"}, []testFile{{"file", "a.txt", []byte(validUTF8Text)}},
+			http.StatusBadRequest, uploadCodeInvalidRequest},
+		// 标题按字符计长，由服务层在写库之前拒绝
+		{"title too long", map[string]string{"title": strings.Repeat("指", 256)}, []testFile{{"file", "a.txt", []byte(validUTF8Text)}},
+			http.StatusUnprocessableEntity, uploadCodeTitleTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := multipartBody(t, tt.fields, tt.files...)
+			rec := serve(t, h.Upload, body, contentType)
+			if rec.Code != tt.status || errorCode(t, rec) != tt.code {
+				t.Errorf("status %d body %s, want %d %s", rec.Code, rec.Body, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+// 415 响应带上嗅探到的类型，方便客户端提示
+func TestUploadUnsupportedTypeReportsMIME(t *testing.T) {
+	body, contentType := multipartBody(t, nil, testFile{"file", "scan.png", []byte(pngHeader)})
+	rec := serve(t, newUploadTestHandler().Upload, body, contentType)
+	if !strings.Contains(rec.Body.String(), `"mime_type":"image/png"`) {
+		t.Errorf("body %s, want mime_type image/png", rec.Body)
+	}
+}
+
+// readUpload 按实际读到的字节数判断是否超限，不信任文件头中的 Size
+func TestReadUploadIgnoresDeclaredSize(t *testing.T) {
+	h := newUploadTestHandler()
+	body, contentType := multipartBody(t, nil, testFile{"file", "a.txt", bytes.Repeat([]byte("a"), 1025)})
+	req, err := http.NewRequest(http.MethodPost, "/", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if err := req.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+	fileHeader := req.MultipartForm.File["file"][0]
+	fileHeader.Size = 10 // 声明的大小小于实际内容
+
+	data, uerr := h.readUpload(fileHeader)
+	if uerr == nil || uerr.status != http.StatusRequestEntityTooLarge || uerr.code != uploadCodeFileTooLarge {
+		t.Fatalf("readUpload = %d bytes, %+v; want 413 %s", len(data), uerr, uploadCodeFileTooLarge)
+	}
+
+	fileHeader = &multipart.FileHeader{Filename: "a.txt", Size: 1 << 20}
+	if _, uerr := h.readUpload(fileHeader); uerr == nil || uerr.code != uploadCodeFileTooLarge {
+		t.Errorf("declared oversized file = %+v, want %s without opening it", uerr, uploadCodeFileTooLarge)
+	}
+}
@@ -2,8 +2,17 @@ package models
 
 // Chunk 表示从 Chroma 检索到的文档块
 type Chunk struct {
-	DocumentID uint   `json:"document_id"`
-	UserID     uint   `json:"user_id"`
-	Index      int    `json:"index"`
-	Content    string `json:"content"`
+	DocumentID uint    `json:"document_id"`
+	UserID     uint    `json:"user_id"`
+	Index      int     `json:"index"`
+	Content    string  `json:"content"`
+	Title      string  `json:"title"`
+	Visibility string  `json:"visibility"`
+	Distance   float64 `json:"distance"`
+	Version    int     `json:"version,omitempty"`  // 块所属的文档版本，早于版本功能索引的块为 0
+	Page       int     `json:"page,omitempty"`     // 块起始页码，文档未分页时为 0
+	PageEnd    int     `json:"page_end,omitempty"` // 块结束页码
+	Section    string  `json:"section,omitempty"`  // 块所在章节路径，如“诊断 > 实验室检查”
+	CharStart  int     `json:"char_start"`         // 块在文档内容中的起始字符偏移
+	CharEnd    int     `json:"char_end,omitempty"` // 块的结束字符偏移（不含），早于偏移记录功能索引的块为 0
 }
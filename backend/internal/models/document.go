@@ -4,13 +4,77 @@ import (
 	"time"
 )
 
+// 文档索引状态
+const (
+	DocumentStatusPending    = "pending"
+	DocumentStatusProcessing = "processing"
+	DocumentStatusReady      = "ready"
+	DocumentStatusFailed     = "failed"
+)
+
+// 文档可见范围：private 仅上传者可见，shared 为全组织共享知识库（由管理员维护）
+const (
+	DocumentVisibilityPrivate = "private"
+	DocumentVisibilityShared  = "shared"
+)
+
+// 文档类别
+const (
+	DocumentCategoryGuideline = "guideline"  // 临床指南
+	DocumentCategoryTextbook  = "textbook"   // 教材
+	DocumentCategoryDrugLabel = "drug_label" // 药品说明书
+	DocumentCategoryPaper     = "paper"      // 论文
+	DocumentCategoryNote      = "note"       // 笔记
+)
+
+// DocumentProvenance 记录文档出处，引用时用于判断权威性和时效性
+type DocumentProvenance struct {
+	Publisher   string     `json:"publisher" gorm:"type:varchar(255)"` // 发布机构，如“中华医学会”
+	Authors     []string   `json:"authors" gorm:"type:json;serializer:json"`
+	PublishedOn *time.Time `json:"published_on" gorm:"type:date"`
+	Edition     string     `json:"edition" gorm:"type:varchar(100)"`
+	OriginalURL string     `json:"original_url" gorm:"type:varchar(1024)"`
+	License     string     `json:"license" gorm:"type:varchar(100)"`
+}
+
 // Document 存储用户上传的医学文档内容和元数据
 type Document struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"user_id" gorm:"index;not null"`
-	Title     string    `json:"title" gorm:"type:varchar(255);not null"`
-	Content   string    `json:"content" gorm:"type:longtext;not null"`
-	Status    string    `json:"status" gorm:"type:varchar(50);default:ready"` // ready, processing, failed
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID              uint               `json:"id" gorm:"primaryKey"`
+	UserID          uint               `json:"user_id" gorm:"index;not null"`
+	Title           string             `json:"title" gorm:"type:varchar(255);not null"`
+	Content         string             `json:"content" gorm:"type:longtext;not null"`
+	Visibility      string             `json:"visibility" gorm:"type:varchar(20);index;not null;default:private"`
+	KnowledgeBaseID *uint              `json:"knowledge_base_id" gorm:"index"` // 为空表示未归入任何知识库
+	Tags            []string           `json:"tags" gorm:"type:json;serializer:json"`
+	Category        string             `json:"category" gorm:"type:varchar(50);index"`
+	PublicationYear int                `json:"publication_year,omitempty" gorm:"index"`
+	Source          string             `json:"source" gorm:"type:varchar(255)"`
+	Provenance      DocumentProvenance `json:"provenance" gorm:"embedded"`
+	ReviewBy        *time.Time         `json:"review_by" gorm:"type:date;index"`                  // 复审日期，过期后出现在管理员复审报告中
+	SupersededByID  *uint              `json:"superseded_by_id" gorm:"index"`                     // 取代本文档的新版本，检索时降权或排除
+	Encoding        string             `json:"encoding,omitempty" gorm:"type:varchar(20)"`        // 上传文本文件的原始编码，如 GB18030
+	Version         int                `json:"version" gorm:"not null;default:1"`                 // 当前内容的版本号，每次内容变更加 1
+	ContentHash     string             `json:"content_hash,omitempty" gorm:"type:char(64);index"` // 规范化内容的 SHA-256，用于识别完全重复
+	SimHash         uint64             `json:"-" gorm:"not null;default:0"`                       // 内容的 SimHash 指纹，用于识别近似重复
+	SimBand0        uint8              `json:"-" gorm:"not null;default:0;index"`                 // SimBand0-7 是 SimHash 从低位起的 8 段，近似重复按段查找候选
+	SimBand1        uint8              `json:"-" gorm:"not null;default:0;index"`
+	SimBand2        uint8              `json:"-" gorm:"not null;default:0;index"`
+	SimBand3        uint8              `json:"-" gorm:"not null;default:0;index"`
+	SimBand4        uint8              `json:"-" gorm:"not null;default:0;index"`
+	SimBand5        uint8              `json:"-" gorm:"not null;default:0;index"`
+	SimBand6        uint8              `json:"-" gorm:"not null;default:0;index"`
+	SimBand7        uint8              `json:"-" gorm:"not null;default:0;index"`
+	DuplicateOfID   *uint              `json:"duplicate_of_id,omitempty" gorm:"index"`            // 创建时发现的（近似）重复文档
+	DuplicateScore  float64            `json:"duplicate_score,omitempty"`                         // 与 DuplicateOfID 的相似度，1 为完全相同
+	PHIRedactions   int                `json:"phi_redactions" gorm:"not null;default:0"`          // 入库时替换的身份信息处数（标题和内容）
+	FileKey         string             `json:"-" gorm:"type:varchar(255)"`                        // 原始文件在存储中的键，为空表示未保存原始文件
+	FileName        string             `json:"file_name,omitempty" gorm:"type:varchar(255)"`      // 上传时的文件名
+	FileMIMEType    string             `json:"file_mime_type,omitempty" gorm:"type:varchar(100)"` // 按内容嗅探的文件类型
+	FileSize        int64              `json:"file_size,omitempty"`                               // 原始文件字节数
+	FileSHA256      string             `json:"file_sha256,omitempty" gorm:"type:char(64)"`        // 原始文件的 SHA-256，下载时校验
+	Status          string             `json:"status" gorm:"type:varchar(50);default:ready"`      // pending, processing, ready, failed
+	ErrorMessage    string             `json:"error_message,omitempty" gorm:"type:text"`          // 最近一次索引失败的原因
+	ChunkCount      int                `json:"chunk_count" gorm:"not null;default:0"`             // 已写入 Chroma 的块数
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// DocumentRedaction 记录文档入库时去除患者身份信息（PHI）的结果，只保存类别、位置和替换文本，不保存原值。
+// 创建文档以及每次修改标题或内容时各记录一条
+type DocumentRedaction struct {
+	ID                uint               `json:"id" gorm:"primaryKey"`
+	DocumentID        uint               `json:"document_id" gorm:"index;not null"`
+	Version           int                `json:"version" gorm:"not null"` // 对应的文档版本
+	Mode              string             `json:"mode" gorm:"type:varchar(20);not null"`
+	Total             int                `json:"total" gorm:"not null;default:0"`
+	Counts            map[string]int     `json:"counts" gorm:"type:json;serializer:json"`   // 各类别的替换次数
+	Findings          []RedactionFinding `json:"findings" gorm:"type:json;serializer:json"` // 各处替换在处理后文本中的位置
+	OriginalDiscarded bool               `json:"original_discarded"`                        // 原始文件含身份信息，未保存
+	CreatedAt         time.Time          `json:"created_at"`
+}
+
+// RedactionFinding 是一处替换，Start/End 为替换文本在处理后的标题或内容中的字符偏移
+type RedactionFinding struct {
+	Field       string `json:"field"` // title 或 content
+	Kind        string `json:"kind"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Replacement string `json:"replacement"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// DocumentVersion 是文档某一版本内容的快照，每次内容变更（包括恢复旧版本）都会新增一条
+type DocumentVersion struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	DocumentID   uint      `json:"document_id" gorm:"uniqueIndex:idx_document_version;not null"`
+	Version      int       `json:"version" gorm:"uniqueIndex:idx_document_version;not null"`
+	Title        string    `json:"title" gorm:"type:varchar(255);not null"`
+	Content      string    `json:"content,omitempty" gorm:"type:longtext;not null"`
+	UserID       uint      `json:"user_id" gorm:"not null"` // 产生该版本的用户
+	RestoredFrom *int      `json:"restored_from,omitempty"` // 由哪个旧版本恢复而来
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// 索引任务状态
+const (
+	IndexJobStatusPending    = "pending"
+	IndexJobStatusProcessing = "processing"
+	IndexJobStatusDone       = "done"
+	IndexJobStatusFailed     = "failed"
+)
+
+// IndexJob 是持久化的文档索引任务，服务重启后未完成的任务会被重新领取
+type IndexJob struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	DocumentID uint      `json:"document_id" gorm:"index;not null"`
+	UserID     uint      `json:"user_id" gorm:"index;not null"`
+	Status     string    `json:"status" gorm:"type:varchar(50);index;not null"`
+	Attempts   int       `json:"attempts" gorm:"not null;default:0"`
+	LastError  string    `json:"last_error,omitempty" gorm:"type:text"`
+	NextRunAt  time.Time `json:"next_run_at" gorm:"index"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
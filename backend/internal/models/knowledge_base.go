@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+)
+
+// KnowledgeBase 是用户自建的文档分组（如“儿科”“心内科”），提问时可以限定只从某些知识库检索
+type KnowledgeBase struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"uniqueIndex:idx_knowledge_base_owner_name;not null"`
+	Name        string    `json:"name" gorm:"type:varchar(100);uniqueIndex:idx_knowledge_base_owner_name;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// 文档写入产生的 outbox 事件类型
+const (
+	OutboxEventDocumentCreated = "document.created"
+	OutboxEventDocumentUpdated = "document.updated"
+	OutboxEventDocumentDeleted = "document.deleted"
+	// 只修改了元数据，向量无需重建
+	OutboxEventDocumentMetadataUpdated = "document.metadata_updated"
+)
+
+// outbox 事件状态
+const (
+	OutboxStatusPending    = "pending"
+	OutboxStatusProcessing = "processing"
+	OutboxStatusDone       = "done"
+	// 超过最大尝试次数后放弃，不再阻塞同一文档之后的事件
+	OutboxStatusFailed = "failed"
+)
+
+// OutboxEvent 与文档变更在同一事务中写入，由分发器异步应用到 Chroma，保证两边最终一致
+type OutboxEvent struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	EventType   string     `json:"event_type" gorm:"type:varchar(50);not null"`
+	DocumentID  uint       `json:"document_id" gorm:"index;not null"`
+	UserID      uint       `json:"user_id" gorm:"not null"`
+	Status      string     `json:"status" gorm:"type:varchar(50);index;not null"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	LastError   string     `json:"last_error,omitempty" gorm:"type:text"`
+	NextRunAt   time.Time  `json:"next_run_at" gorm:"index"`
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
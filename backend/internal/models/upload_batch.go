@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// 批量上传条目状态：created 已创建文档并进入索引流程，rejected 未能创建文档
+const (
+	UploadBatchItemCreated  = "created"
+	UploadBatchItemRejected = "rejected"
+)
+
+// UploadBatch 是一次批量上传（多个文件或 ZIP 压缩包），每个文件对应一篇文档
+type UploadBatch struct {
+	ID        uint              `json:"id" gorm:"primaryKey"`
+	UserID    uint              `json:"user_id" gorm:"index;not null"`
+	Total     int               `json:"total" gorm:"not null;default:0"`
+	Created   int               `json:"created" gorm:"not null;default:0"`
+	Rejected  int               `json:"rejected" gorm:"not null;default:0"`
+	Items     []UploadBatchItem `json:"items,omitempty" gorm:"foreignKey:BatchID"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// UploadBatchItem 记录批次中单个文件的处理结果
+type UploadBatchItem struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	BatchID      uint      `json:"batch_id" gorm:"index;not null"`
+	Filename     string    `json:"filename" gorm:"type:varchar(512)"` // 压缩包内的相对路径
+	DocumentID   *uint     `json:"document_id" gorm:"index"`
+	Status       string    `json:"status" gorm:"type:varchar(20);not null"`
+	ErrorCode    string    `json:"error_code,omitempty" gorm:"type:varchar(50)"`
+	ErrorMessage string    `json:"error_message,omitempty" gorm:"type:text"`
+	CreatedAt    time.Time `json:"created_at"`
+}
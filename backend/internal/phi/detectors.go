@@ -0,0 +1,152 @@
+package phi
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	idCardPattern   = regexp.MustCompile(`\d{17}[\dXx]`)
+	mobilePattern   = regexp.MustCompile(`(?:\+?86[- ]?)?1[3-9]\d(?:[- ]?\d{4}){2}`)
+	landlinePattern = regexp.MustCompile(`0\d{2,3}-\d{7,8}`)
+)
+
+// 18 位身份证号前 17 位的加权系数和校验码
+var (
+	idCardWeights    = [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	idCardCheckCodes = "10X98765432"
+)
+
+// detectIDCards 识别 18 位居民身份证号，要求出生日期合法且校验位正确
+func detectIDCards(text string) []match {
+	var matches []match
+	for _, loc := range idCardPattern.FindAllStringIndex(text, -1) {
+		if isAlnumAt(text, loc[0]-1) || isAlnumAt(text, loc[1]) {
+			continue
+		}
+		if validIDCard(text[loc[0]:loc[1]]) {
+			matches = append(matches, match{start: loc[0], end: loc[1], kind: KindIDCard})
+		}
+	}
+	return matches
+}
+
+func validIDCard(id string) bool {
+	birth, err := time.Parse("20060102", id[6:14])
+	if err != nil || birth.Year() < 1900 || birth.After(time.Now()) {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(id[i]-'0') * idCardWeights[i]
+	}
+	return strings.ToUpper(id[17:]) == string(idCardCheckCodes[sum%11])
+}
+
+// detectPhones 识别手机号（可带 +86 和分隔符）和带区号的固定电话
+func detectPhones(text string) []match {
+	var matches []match
+	for _, pattern := range []*regexp.Regexp{mobilePattern, landlinePattern} {
+		for _, loc := range pattern.FindAllStringIndex(text, -1) {
+			if isAlnumAt(text, loc[0]-1) || isAlnumAt(text, loc[1]) {
+				continue
+			}
+			matches = append(matches, match{start: loc[0], end: loc[1], kind: KindPhone})
+		}
+	}
+	return matches
+}
+
+var (
+	// 姓名标签后的中文姓名
+	nameLabelPattern = regexp.MustCompile(`(?:姓名|联系人|家属|监护人|陪同人)\s*[:：]\s*(\p{Han}{2,4})`)
+	// 病历中常见的“张三，男，45岁”写法：汉字串后紧跟性别和年龄，姓名取汉字串末尾
+	nameDemographicPattern = regexp.MustCompile(`(\p{Han}{2,})\s*[，,、（(]\s*[男女]\s*[，,、]?\s*\d{1,3}\s*岁`)
+)
+
+// 常见单姓和复姓
+var (
+	singleSurnames   = "王李张刘陈杨黄赵吴周徐孙马朱胡郭何高林罗郑梁谢宋唐许韩冯邓曹彭曾肖田董袁潘于蒋蔡余杜叶程苏魏吕丁任沈姚卢姜崔钟谭陆汪范金石廖贾夏韦付方白邹孟熊秦邱江尹薛闫段雷侯龙史陶黎贺顾毛郝龚邵万钱严覃武戴莫孔向汤常温康施文牛樊葛邢安齐易乔伍庞颜倪庄聂章鲁岳翟殷詹申欧耿关兰焦俞左柳甘祝包宁尚符舒阮柯纪梅童凌毕单季裴霍涂成苗谷盛曲翁冉骆蓝路游辛靳管柴蒙鲍华喻祁蒲房滕屈饶解牟艾尤阳时穆农司卓古吉缪简车项连芦麦褚娄窦戚岑景党宫费卜冷晏席卫米柏宗瞿桂全佟应臧闵苟邬边卞姬师和仇栾隋商刁沙荣巫寇桑郎甄丛仲虞敖巩明佘池查麻苑迟邝"
+	compoundSurnames = []string{"欧阳", "司马", "诸葛", "上官", "东方", "皇甫", "尉迟", "公孙", "慕容", "长孙", "宇文", "司徒", "夏侯", "令狐", "端木", "独孤", "南宫", "西门"}
+)
+
+// 不会出现在名中的字，多为紧随姓名的“性别”“年龄”“电话”“住址”等标签的首字
+const nameStopRunes = "性男女年岁出电住身病门籍职婚"
+
+// surnameLen 返回 name 开头的姓氏长度（字符数），不以常见姓氏开头时返回 0
+func surnameLen(name string) int {
+	for _, s := range compoundSurnames {
+		if strings.HasPrefix(name, s) {
+			return 2
+		}
+	}
+	if r, _ := utf8.DecodeRuneInString(name); strings.ContainsRune(singleSurnames, r) {
+		return 1
+	}
+	return 0
+}
+
+// asName 判断汉字串是否像姓名：以常见姓氏开头，名为一到两个字，且不是“某”“某某”之类已匿名的写法
+func asName(s string) bool {
+	runes := []rune(s)
+	n := surnameLen(s)
+	if n == 0 {
+		return false
+	}
+	given := runes[n:]
+	if len(given) < 1 || len(given) > 2 || given[0] == '某' {
+		return false
+	}
+	return !strings.ContainsAny(string(given), nameStopRunes)
+}
+
+// trailingName 从汉字串末尾取出姓名，如“患者王小明”中的“王小明”，优先取较长的
+func trailingName(s string) (string, bool) {
+	runes := []rune(s)
+	for n := min(4, len(runes)); n >= 2; n-- {
+		candidate := string(runes[len(runes)-n:])
+		if asName(candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// detectNames 通过姓名标签和“姓名，性别，年龄”写法识别姓名，再替换文中该姓名以及 known 中姓名的所有出现
+func detectNames(text string, known []string) []match {
+	names := make(map[string]bool)
+	for _, name := range known {
+		names[name] = true
+	}
+	for _, loc := range nameLabelPattern.FindAllStringSubmatchIndex(text, -1) {
+		run := []rune(text[loc[2]:loc[3]])
+		// 标签后的汉字可能连着其他内容，从最长的可能姓名开始尝试
+		for n := min(4, len(run)); n >= 2; n-- {
+			if candidate := string(run[:n]); asName(candidate) {
+				names[candidate] = true
+				break
+			}
+		}
+	}
+	for _, loc := range nameDemographicPattern.FindAllStringSubmatchIndex(text, -1) {
+		if name, ok := trailingName(text[loc[2]:loc[3]]); ok {
+			names[name] = true
+		}
+	}
+
+	var matches []match
+	for name := range names {
+		for offset := 0; ; {
+			i := strings.Index(text[offset:], name)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			matches = append(matches, match{start: start, end: start + len(name), kind: KindName})
+			offset = start + len(name)
+		}
+	}
+	return matches
+}
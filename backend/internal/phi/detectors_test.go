@@ -0,0 +1,138 @@
+package phi
+
+import (
+	"reflect"
+	"testing"
+)
+
+// found 返回 text 中匹配到的原文片段
+func found(text string, matches []match) []string {
+	var out []string
+	for _, m := range resolveOverlaps(matches) {
+		out = append(out, text[m.start:m.end])
+	}
+	return out
+}
+
+func TestValidIDCard(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"110105199003071239", true},
+		{"44030419851231002X", true},
+		{"44030419851231002x", true},
+		{"320106197806154413", true},
+		{"110105199003071230", false}, // 校验位错误
+		{"110105199002301239", false}, // 2 月 30 日
+		{"110105189003071239", false}, // 1900 年之前
+		{"110105299003071239", false}, // 出生日期在未来
+	}
+	for _, tt := range tests {
+		if got := validIDCard(tt.id); got != tt.want {
+			t.Errorf("validIDCard(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestDetectIDCards(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"身份证号：110105199003071239。", []string{"110105199003071239"}},
+		{"证件44030419851231002X已核验", []string{"44030419851231002X"}},
+		{"编号A110105199003071239", nil},   // 前面连着字母
+		{"1101051990030712391", nil},     // 19 位数字
+		{"身份证号：110105199003071230", nil}, // 校验位错误
+	}
+	for _, tt := range tests {
+		if got := found(tt.text, detectIDCards(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectIDCards(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectPhones(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"电话13812345678", []string{"13812345678"}},
+		{"联系电话：138-1234-5678。", []string{"138-1234-5678"}},
+		{"手机 +86 138 1234 5678", []string{"+86 138 1234 5678"}},
+		{"座机010-12345678转分机", []string{"010-12345678"}},
+		{"编号12812345678", nil},  // 第二位不是 3-9
+		{"订单138123456789", nil}, // 12 位数字
+		{"血小板 138×10^9/L", nil},
+	}
+	for _, tt := range tests {
+		if got := found(tt.text, detectPhones(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectPhones(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectNames(t *testing.T) {
+	tests := []struct {
+		text  string
+		known []string
+		want  []string
+	}{
+		{"姓名：王小明，性别：男", nil, []string{"王小明"}},
+		{"联系人:欧阳娜娜", nil, []string{"欧阳娜娜"}},
+		{"患者张三，男，45岁，主诉头痛。张三既往体健", nil, []string{"张三", "张三"}},
+		{"张三性别男", []string{"张三"}, []string{"张三"}},
+		{"复诊时李四血压平稳", []string{"李四"}, []string{"李四"}},
+		{"患者张某，男，45岁", nil, nil},  // 已匿名的写法
+		{"糖尿病患者，女，60岁", nil, nil}, // 没有常见姓氏开头的名字
+		{"姓名：张性别男", nil, nil},     // 名中含标签字
+		{"高血压患者应限制钠盐摄入", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := found(tt.text, detectNames(tt.text, tt.known)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectNames(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectMedicalRecords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"住院号：ZY20230101，入院诊断", []string{"ZY20230101"}},
+		{"病历号 12345678", []string{"12345678"}},
+		{"门诊号码:MZ-001234", []string{"MZ-001234"}},
+		{"住院期间血糖控制良好", nil},
+	}
+	for _, tt := range tests {
+		if got := found(tt.text, detectLabeled(tt.text, medicalRecordPattern, KindMedicalRecord)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("medical record in %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectAddresses(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"家庭住址：北京市朝阳区建国路88号，", []string{"北京市朝阳区建国路88号"}},
+		{"住址 上海市浦东新区张江镇", []string{"上海市浦东新区张江镇"}},
+		{"患者家住广州市天河区体育西路。", []string{"广州市天河区体育西路"}},
+		{"现住：杭州市西湖区文三路", []string{"杭州市西湖区文三路"}},
+		{"现住为成都市武侯区某小区", []string{"成都市武侯区某小区"}},
+		// 临床行文中的“现住院”“家住”不是地址
+		{"现住院患者中约30%出现低血糖反应", nil},
+		{"对于现住院治疗的患者", nil},
+		{"现住院的糖尿病患者如何调整胰岛素", nil},
+		{"现住院第三天，体温恢复正常", nil},
+		{"家住附近的患者可门诊随访", nil},
+	}
+	for _, tt := range tests {
+		if got := found(tt.text, detectAddresses(tt.text)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("detectAddresses(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package phi 识别并去除文本中的患者身份信息（PHI）：姓名、身份证号、电话、病历号和住址。
+// 识别基于正则和字典启发式，身份证号额外校验出生日期和校验位以减少误报
+package phi
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// 身份信息类别
+const (
+	KindName          = "name"
+	KindIDCard        = "id_card"
+	KindPhone         = "phone"
+	KindMedicalRecord = "medical_record"
+	KindAddress       = "address"
+)
+
+// AllKinds 是全部支持的类别
+var AllKinds = []string{KindName, KindIDCard, KindPhone, KindMedicalRecord, KindAddress}
+
+// 各类别在替换文本中的中文标签
+var kindLabels = map[string]string{
+	KindName:          "姓名",
+	KindIDCard:        "身份证号",
+	KindPhone:         "电话",
+	KindMedicalRecord: "病历号",
+	KindAddress:       "地址",
+}
+
+// 处理方式
+const (
+	ModeOff          = "off"          // 不处理
+	ModeMask         = "mask"         // 替换为类别标签，如 [姓名]
+	ModePseudonymize = "pseudonymize" // 替换为带编号的化名，同一值在同一会话内编号相同，如 [姓名1]
+)
+
+// Finding 是一处被替换的身份信息。Start/End 为替换文本在处理后文本中的字符（rune）偏移，不包含原值
+type Finding struct {
+	Kind        string `json:"kind"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Replacement string `json:"replacement"`
+}
+
+// Report 是一次处理的结果统计
+type Report struct {
+	Mode     string         `json:"mode"`
+	Counts   map[string]int `json:"counts"`
+	Findings []Finding      `json:"findings"`
+}
+
+// Total 返回替换的总处数
+func (r *Report) Total() int {
+	return len(r.Findings)
+}
+
+// Redactor 按配置的方式和类别处理文本，可并发使用
+type Redactor struct {
+	mode  string
+	kinds map[string]bool
+}
+
+// NewRedactor 创建处理器。kinds 为空时识别全部类别
+func NewRedactor(mode string, kinds []string) (*Redactor, error) {
+	switch mode {
+	case ModeOff, ModeMask, ModePseudonymize:
+	default:
+		return nil, fmt.Errorf("invalid PHI redaction mode %q", mode)
+	}
+	if len(kinds) == 0 {
+		kinds = AllKinds
+	}
+	enabled := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		if _, ok := kindLabels[kind]; !ok {
+			return nil, fmt.Errorf("invalid PHI kind %q", kind)
+		}
+		enabled[kind] = true
+	}
+	return &Redactor{mode: mode, kinds: enabled}, nil
+}
+
+// Mode 返回处理方式
+func (r *Redactor) Mode() string {
+	if r == nil {
+		return ModeOff
+	}
+	return r.mode
+}
+
+// Enabled 表示是否需要处理
+func (r *Redactor) Enabled() bool {
+	return r.Mode() != ModeOff
+}
+
+// Redact 处理一段独立的文本
+func (r *Redactor) Redact(text string) (string, *Report) {
+	return r.NewSession().Redact(text)
+}
+
+// Session 在多段文本之间共享化名编号：同一会话中同一个值总是替换为同一个化名
+type Session struct {
+	redactor *Redactor
+	aliases  map[string]string // kind + "\x00" + 原值 -> 化名
+	next     map[string]int    // 各类别的下一个编号
+}
+
+// NewSession 创建新的会话
+func (r *Redactor) NewSession() *Session {
+	return &Session{redactor: r, aliases: make(map[string]string), next: make(map[string]int)}
+}
+
+// Redact 识别文本中的身份信息并替换，返回处理后的文本和报告
+func (s *Session) Redact(text string) (string, *Report) {
+	report := &Report{Mode: s.redactor.Mode(), Counts: map[string]int{}, Findings: []Finding{}}
+	if !s.redactor.Enabled() || text == "" {
+		return text, report
+	}
+
+	matches := resolveOverlaps(s.redactor.detect(text, s.knownNames()))
+	var sb strings.Builder
+	pos, runePos := 0, 0
+	for _, m := range matches {
+		sb.WriteString(text[pos:m.start])
+		runePos += utf8.RuneCountInString(text[pos:m.start])
+		replacement := s.replacement(m.kind, text[m.start:m.end])
+		sb.WriteString(replacement)
+		n := utf8.RuneCountInString(replacement)
+		report.Findings = append(report.Findings, Finding{Kind: m.kind, Start: runePos, End: runePos + n, Replacement: replacement})
+		report.Counts[m.kind]++
+		runePos += n
+		pos = m.end
+	}
+	sb.WriteString(text[pos:])
+	return sb.String(), report
+}
+
+// knownNames 返回会话中此前识别出的姓名。后续文本中单独出现的姓名没有上下文线索，需据此识别
+func (s *Session) knownNames() []string {
+	var names []string
+	for key := range s.aliases {
+		if kind, value, _ := strings.Cut(key, "\x00"); kind == KindName {
+			names = append(names, value)
+		}
+	}
+	return names
+}
+
+func (s *Session) replacement(kind, value string) string {
+	label := kindLabels[kind]
+	key := kind + "\x00" + normalizeValue(kind, value)
+	if alias, ok := s.aliases[key]; ok {
+		return alias
+	}
+	// 遮蔽方式不编号，但仍记录已识别的值，供会话中后续文本识别姓名
+	alias := "[" + label + "]"
+	if s.redactor.mode == ModePseudonymize {
+		s.next[kind]++
+		alias = fmt.Sprintf("[%s%d]", label, s.next[kind])
+	}
+	s.aliases[key] = alias
+	return alias
+}
+
+// normalizeValue 统一同一值的不同写法，如电话中的空格和连字符
+func normalizeValue(kind, value string) string {
+	switch kind {
+	case KindPhone, KindIDCard, KindMedicalRecord:
+		value = strings.Map(func(r rune) rune {
+			if r == ' ' || r == '-' {
+				return -1
+			}
+			return r
+		}, value)
+		value = strings.TrimPrefix(value, "+86")
+		return strings.ToUpper(value)
+	}
+	return value
+}
+
+// match 是原文中 [start, end) 字节范围的一处身份信息
+type match struct {
+	start, end int
+	kind       string
+}
+
+// resolveOverlaps 按位置排序并去掉重叠的匹配，起点相同时保留较长的
+func resolveOverlaps(matches []match) []match {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].start != matches[j].start {
+			return matches[i].start < matches[j].start
+		}
+		return matches[i].end > matches[j].end
+	})
+	var out []match
+	end := 0
+	for _, m := range matches {
+		if m.start < end {
+			continue
+		}
+		out = append(out, m)
+		end = m.end
+	}
+	return out
+}
+
+func (r *Redactor) detect(text string, knownNames []string) []match {
+	var matches []match
+	if r.kinds[KindIDCard] {
+		matches = append(matches, detectIDCards(text)...)
+	}
+	if r.kinds[KindPhone] {
+		matches = append(matches, detectPhones(text)...)
+	}
+	if r.kinds[KindMedicalRecord] {
+		matches = append(matches, detectLabeled(text, medicalRecordPattern, KindMedicalRecord)...)
+	}
+	if r.kinds[KindAddress] {
+		matches = append(matches, detectAddresses(text)...)
+	}
+	if r.kinds[KindName] {
+		matches = append(matches, detectNames(text, knownNames)...)
+	}
+	return matches
+}
+
+var (
+	// 标签后的病历号、住院号等，只替换号码本身
+	medicalRecordPattern = regexp.MustCompile(`(?:病历号|病案号|住院号|门诊号|就诊卡号|就诊号|登记号)(?:码)?\s*[:：]?\s*([A-Za-z0-9][A-Za-z0-9-]{3,23})`)
+	// 标签后的住址，到标点或换行为止
+	addressPattern = regexp.MustCompile(`(?:家庭住址|现住址|户籍地址|联系地址|居住地址|住址)\s*[:：为]?\s*([^\s，,。；;：:、]{4,60})`)
+	// “家住”“现住”也是常见的行文写法，但“现住院患者”等临床用语同样以它开头，需额外校验
+	shortAddressPattern = regexp.MustCompile(`(?:家住|现住)\s*([:：为])?\s*([^\s，,。；;：:、]{4,60})`)
+	// 地址中常见的行政区划和门牌用字
+	addressMarkerPattern = regexp.MustCompile(`[省市区县镇乡村路街巷道号楼栋室]`)
+)
+
+// detectAddresses 识别住址标签后的地址。“家住”“现住”后没有冒号等分隔符时，
+// 要求内容不以“院”开头（排除“现住院”）且含有地址用字，避免把临床行文当作地址
+func detectAddresses(text string) []match {
+	matches := detectLabeled(text, addressPattern, KindAddress)
+	for _, loc := range shortAddressPattern.FindAllStringSubmatchIndex(text, -1) {
+		value := text[loc[4]:loc[5]]
+		if loc[2] < 0 && (strings.HasPrefix(value, "院") || !addressMarkerPattern.MatchString(value)) {
+			continue
+		}
+		matches = append(matches, match{start: loc[4], end: loc[5], kind: KindAddress})
+	}
+	return matches
+}
+
+// detectLabeled 返回 pattern 第一个分组的位置
+func detectLabeled(text string, pattern *regexp.Regexp, kind string) []match {
+	var matches []match
+	for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+		matches = append(matches, match{start: loc[2], end: loc[3], kind: kind})
+	}
+	return matches
+}
+
+// isAlnumAt 判断 text 中 i 处是否为 ASCII 字母或数字，越界视为否
+func isAlnumAt(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	c := text[i]
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
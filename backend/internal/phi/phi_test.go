@@ -0,0 +1,107 @@
+package phi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewRedactor(t *testing.T) {
+	if _, err := NewRedactor("hide", nil); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+	if _, err := NewRedactor(ModeMask, []string{"email"}); err == nil {
+		t.Error("expected error for invalid kind")
+	}
+	var nilRedactor *Redactor
+	if nilRedactor.Enabled() {
+		t.Error("nil redactor should be disabled")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		kinds  []string
+		text   string
+		want   string
+		counts map[string]int
+	}{
+		{
+			name:   "mask",
+			mode:   ModeMask,
+			text:   "患者张三，男，45岁，电话13812345678，身份证号110105199003071239",
+			want:   "患者[姓名]，男，45岁，电话[电话]，身份证号[身份证号]",
+			counts: map[string]int{KindName: 1, KindPhone: 1, KindIDCard: 1},
+		},
+		{
+			name:   "pseudonymize numbers distinct values",
+			mode:   ModePseudonymize,
+			text:   "电话13812345678，备用电话13900001111，再次确认138-1234-5678",
+			want:   "电话[电话1]，备用电话[电话2]，再次确认[电话1]",
+			counts: map[string]int{KindPhone: 3},
+		},
+		{
+			name:   "only enabled kinds",
+			mode:   ModeMask,
+			kinds:  []string{KindPhone},
+			text:   "姓名：王小明，电话13812345678",
+			want:   "姓名：王小明，电话[电话]",
+			counts: map[string]int{KindPhone: 1},
+		},
+		{
+			name:   "off",
+			mode:   ModeOff,
+			text:   "电话13812345678",
+			want:   "电话13812345678",
+			counts: map[string]int{},
+		},
+		{
+			name:   "clinical prose unchanged",
+			mode:   ModeMask,
+			text:   "现住院患者中约30%出现低血糖反应，对于现住院治疗的患者应监测血糖",
+			want:   "现住院患者中约30%出现低血糖反应，对于现住院治疗的患者应监测血糖",
+			counts: map[string]int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRedactor(tt.mode, tt.kinds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, report := r.Redact(tt.text)
+			if got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(report.Counts, tt.counts) {
+				t.Errorf("counts = %v, want %v", report.Counts, tt.counts)
+			}
+		})
+	}
+}
+
+func TestRedactFindingOffsets(t *testing.T) {
+	r, _ := NewRedactor(ModeMask, nil)
+	got, report := r.Redact("电话13812345678")
+	runes := []rune(got)
+	for _, f := range report.Findings {
+		if string(runes[f.Start:f.End]) != f.Replacement {
+			t.Errorf("finding %+v does not point at its replacement in %q", f, got)
+		}
+	}
+}
+
+func TestSessionKeepsAliases(t *testing.T) {
+	r, _ := NewRedactor(ModePseudonymize, nil)
+	s := r.NewSession()
+	first, _ := s.Redact("患者张三，男，45岁，电话13812345678")
+	second, _ := s.Redact("张三复诊，新电话13900001111，旧电话138 1234 5678")
+	if first != "患者[姓名1]，男，45岁，电话[电话1]" {
+		t.Errorf("first = %q", first)
+	}
+	// 后续文本中单独出现的姓名依据会话中已识别的姓名替换
+	if second != "[姓名1]复诊，新电话[电话2]，旧电话[电话1]" {
+		t.Errorf("second = %q", second)
+	}
+}
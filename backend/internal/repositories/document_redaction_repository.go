@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DocumentRedactionRepository 存储文档的身份信息去除报告
+type DocumentRedactionRepository struct {
+	db *gorm.DB
+}
+
+func NewDocumentRedactionRepository(db *gorm.DB) *DocumentRedactionRepository {
+	return &DocumentRedactionRepository{db: db}
+}
+
+// WithTx 返回绑定到指定事务的仓储
+func (r *DocumentRedactionRepository) WithTx(tx *gorm.DB) *DocumentRedactionRepository {
+	return &DocumentRedactionRepository{db: tx}
+}
+
+func (r *DocumentRedactionRepository) Create(redaction *models.DocumentRedaction) error {
+	return r.db.Create(redaction).Error
+}
+
+// ListByDocument 按时间倒序返回文档的所有报告
+func (r *DocumentRedactionRepository) ListByDocument(documentID uint) ([]models.DocumentRedaction, error) {
+	var redactions []models.DocumentRedaction
+	err := r.db.Where("document_id = ?", documentID).
+		Order("id desc").
+		Find(&redactions).Error
+	if err != nil {
+		return nil, err
+	}
+	return redactions, nil
+}
+
+func (r *DocumentRedactionRepository) DeleteByDocument(documentID uint) error {
+	return r.db.Where("document_id = ?", documentID).Delete(&models.DocumentRedaction{}).Error
+}
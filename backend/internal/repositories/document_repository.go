@@ -1,20 +1,98 @@
 package repositories
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"medical-qa-assistant/internal/models"
 
 	"gorm.io/gorm"
 )
 
+// DocumentFilter 是文档列表的过滤条件，零值字段不参与过滤
+type DocumentFilter struct {
+	Tags        []string // 包含任一标签即匹配
+	Category    string
+	YearFrom    int
+	YearTo      int
+	Statuses    []string   // 索引状态，匹配任一即可
+	CreatedFrom *time.Time // 创建时间下限（含）
+	CreatedTo   *time.Time // 创建时间上限（不含）
+	Title       string     // 标题包含该子串
+}
+
+// 文档列表可用的排序字段
+const (
+	DocumentSortCreatedAt = "created_at"
+	DocumentSortUpdatedAt = "updated_at"
+	DocumentSortTitle     = "title"
+)
+
+// DocumentPageQuery 是列表的分页和排序参数。Cursor 非空时按游标（keyset）分页并忽略 Offset
+type DocumentPageQuery struct {
+	Sort   string
+	Desc   bool
+	Limit  int
+	Offset int
+	Cursor *DocumentCursor
+}
+
+// DocumentCursor 定位上一页的最后一条记录：排序字段的值及文档 ID
+type DocumentCursor struct {
+	Sort  string    `json:"s"`
+	Time  time.Time `json:"t,omitempty"`
+	Title string    `json:"v,omitempty"`
+	ID    uint      `json:"id"`
+}
+
+// DocumentSummary 是列表使用的文档摘要，不包含全文，只带内容大小和开头的预览
+type DocumentSummary struct {
+	ID              uint      `json:"id"`
+	UserID          uint      `json:"user_id"`
+	Title           string    `json:"title"`
+	Visibility      string    `json:"visibility"`
+	KnowledgeBaseID *uint     `json:"knowledge_base_id"`
+	Tags            []string  `json:"tags" gorm:"serializer:json"`
+	Category        string    `json:"category"`
+	PublicationYear int       `json:"publication_year,omitempty"`
+	Source          string    `json:"source"`
+	Version         int       `json:"version"`
+	Status          string    `json:"status"`
+	ErrorMessage    string    `json:"error_message,omitempty"`
+	ChunkCount      int       `json:"chunk_count"`
+	ContentBytes    int64     `json:"content_bytes"` // 内容的 UTF-8 字节数
+	ContentChars    int64     `json:"content_chars"` // 内容的字符数
+	Preview         string    `json:"preview"`
+	FileName        string    `json:"file_name,omitempty"`
+	FileSize        int64     `json:"file_size,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// 摘要中预览的字符数
+const documentPreviewChars = 200
+
 // DocumentRepository 提供文档的 CRUD 操作
 type DocumentRepository struct {
-	db *gorm.DB
+	db       *gorm.DB
+	fullText bool // 全文索引可用，见 EnsureFullTextIndex
 }
 
 func NewDocumentRepository(db *gorm.DB) *DocumentRepository {
 	return &DocumentRepository{db: db}
 }
 
+// Transaction 在一个数据库事务中执行 fn，fn 中应通过 WithTx 获取绑定该事务的仓储
+func (r *DocumentRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+// WithTx 返回绑定到指定事务的仓储
+func (r *DocumentRepository) WithTx(tx *gorm.DB) *DocumentRepository {
+	return &DocumentRepository{db: tx, fullText: r.fullText}
+}
+
 func (r *DocumentRepository) Create(doc *models.Document) error {
 	return r.db.Create(doc).Error
 }
@@ -31,6 +109,153 @@ func (r *DocumentRepository) ListByUser(userID uint) ([]models.Document, error)
 	return docs, nil
 }
 
+// accessibleQuery 构造用户自己的文档以及全部共享文档中符合过滤条件的查询
+func (r *DocumentRepository) accessibleQuery(userID uint, filter DocumentFilter) *gorm.DB {
+	query := r.db.Model(&models.Document{}).
+		Where("(user_id = ? OR visibility = ?)", userID, models.DocumentVisibilityShared)
+
+	if len(filter.Tags) > 0 {
+		anyTag := r.db.Where("JSON_CONTAINS(tags, JSON_QUOTE(?))", filter.Tags[0])
+		for _, tag := range filter.Tags[1:] {
+			anyTag = anyTag.Or("JSON_CONTAINS(tags, JSON_QUOTE(?))", tag)
+		}
+		query = query.Where(anyTag)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", filter.Category)
+	}
+	if filter.YearFrom > 0 {
+		query = query.Where("publication_year >= ?", filter.YearFrom)
+	}
+	if filter.YearTo > 0 {
+		query = query.Where("publication_year <= ?", filter.YearTo)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	if filter.Title != "" {
+		query = query.Where("title LIKE ?", "%"+escapeLike(filter.Title)+"%")
+	}
+	return query
+}
+
+// CountAccessible 返回符合过滤条件的文档总数
+func (r *DocumentRepository) CountAccessible(userID uint, filter DocumentFilter) (int64, error) {
+	var total int64
+	err := r.accessibleQuery(userID, filter).Count(&total).Error
+	return total, err
+}
+
+// ListSummaries 返回一页符合过滤条件的文档摘要。排序总是以 ID 作为第二关键字，保证游标分页稳定
+func (r *DocumentRepository) ListSummaries(userID uint, filter DocumentFilter, page DocumentPageQuery) ([]DocumentSummary, error) {
+	query := r.accessibleQuery(userID, filter).
+		Select("id, user_id, title, visibility, knowledge_base_id, tags, category, publication_year, source, version, "+
+			"status, error_message, chunk_count, file_name, file_size, created_at, updated_at, "+
+			"LENGTH(content) AS content_bytes, CHAR_LENGTH(content) AS content_chars, LEFT(content, ?) AS preview",
+			documentPreviewChars)
+
+	cmp, dir := ">", "asc"
+	if page.Desc {
+		cmp, dir = "<", "desc"
+	}
+	if c := page.Cursor; c != nil {
+		var value interface{} = c.Time
+		if page.Sort == DocumentSortTitle {
+			value = c.Title
+		}
+		query = query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", page.Sort, cmp, page.Sort, cmp),
+			value, value, c.ID)
+	} else if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+
+	var summaries []DocumentSummary
+	err := query.Order(page.Sort + " " + dir).Order("id " + dir).
+		Limit(page.Limit).
+		Find(&summaries).Error
+	return summaries, err
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetAccessible 获取用户自己的或共享的文档
+func (r *DocumentRepository) GetAccessible(id, userID uint) (*models.Document, error) {
+	var doc models.Document
+	if err := r.db.Where("id = ? AND (user_id = ? OR visibility = ?)", id, userID, models.DocumentVisibilityShared).
+		First(&doc).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ListByIDs 按 ID 批量获取文档，不做归属校验
+func (r *DocumentRepository) ListByIDs(ids []uint) ([]models.Document, error) {
+	var docs []models.Document
+	if err := r.db.Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// ListStatusesByIDs 按 ID 批量获取文档的索引状态，只查询 id、status 和 error_message，不做归属校验
+func (r *DocumentRepository) ListStatusesByIDs(ids []uint) ([]models.Document, error) {
+	var docs []models.Document
+	if err := r.db.Select("id, status, error_message").Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// ListTitlesByIDs 返回 ids 中仍存在的文档的标题，只查询 id 和 title 两列
+func (r *DocumentRepository) ListTitlesByIDs(ids []uint) (map[uint]string, error) {
+	var rows []struct {
+		ID    uint
+		Title string
+	}
+	if err := r.db.Model(&models.Document{}).Select("id, title").Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	titles := make(map[uint]string, len(rows))
+	for _, row := range rows {
+		titles[row.ID] = row.Title
+	}
+	return titles, nil
+}
+
+// ListAccessibleByIDs 返回 ids 中用户自己的或共享的文档
+func (r *DocumentRepository) ListAccessibleByIDs(ids []uint, userID uint) ([]models.Document, error) {
+	var docs []models.Document
+	if err := r.db.Where("id IN ? AND (user_id = ? OR visibility = ?)", ids, userID, models.DocumentVisibilityShared).
+		Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// GetManageable 获取用户可以修改和删除的文档：自己的文档，admin 为 true 时还包括任何共享文档
+func (r *DocumentRepository) GetManageable(id, userID uint, admin bool) (*models.Document, error) {
+	query := r.db.Where("id = ?", id)
+	if admin {
+		query = query.Where("user_id = ? OR visibility = ?", userID, models.DocumentVisibilityShared)
+	} else {
+		query = query.Where("user_id = ?", userID)
+	}
+	var doc models.Document
+	if err := query.First(&doc).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
 func (r *DocumentRepository) GetByIDAndUser(id, userID uint) (*models.Document, error) {
 	var doc models.Document
 	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&doc).Error; err != nil {
@@ -40,5 +265,182 @@ func (r *DocumentRepository) GetByIDAndUser(id, userID uint) (*models.Document,
 }
 
 func (r *DocumentRepository) DeleteByIDAndUser(id, userID uint) error {
-	return r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Document{}).Error
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Document{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *DocumentRepository) GetByID(id uint) (*models.Document, error) {
+	var doc models.Document
+	if err := r.db.First(&doc, id).Error; err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// UpdateMetadata 只更新文档的元数据字段
+func (r *DocumentRepository) UpdateMetadata(doc *models.Document) error {
+	return r.db.Model(doc).
+		Select("title", "knowledge_base_id", "tags", "category", "publication_year", "source", "review_by", "superseded_by_id").
+		Updates(doc).Error
+}
+
+// UpdateContent 保存新的内容、标题和元数据，并写入重置后的索引状态
+func (r *DocumentRepository) UpdateContent(doc *models.Document) error {
+	return r.db.Model(doc).
+		Select("title", "content", "version", "duplicate_of_id", "duplicate_score", "phi_redactions", "status", "error_message",
+			"knowledge_base_id", "tags", "category", "publication_year", "source", "review_by", "superseded_by_id", fingerprintColumns).
+		Updates(doc).Error
+}
+
+// ListReviewDue 分页返回复审日期早于 before 的文档及其总数，按复审日期升序、ID 升序。
+// 只读取复审报告使用的列，不加载内容
+func (r *DocumentRepository) ListReviewDue(before time.Time, limit, offset int) ([]models.Document, int64, error) {
+	due := func() *gorm.DB {
+		return r.db.Model(&models.Document{}).Where("review_by IS NOT NULL AND review_by < ?", before)
+	}
+	var total int64
+	if err := due().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var docs []models.Document
+	if err := due().Select("id", "user_id", "title", "visibility", "review_by", "superseded_by_id").
+		Order("review_by asc, id asc").
+		Limit(limit).Offset(offset).
+		Find(&docs).Error; err != nil {
+		return nil, 0, err
+	}
+	return docs, total, nil
+}
+
+// UpdateStatus 只更新文档的索引状态和错误信息，避免覆盖并发修改的其他字段
+func (r *DocumentRepository) UpdateStatus(id uint, status, errorMessage string) error {
+	return r.db.Model(&models.Document{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":        status,
+			"error_message": errorMessage,
+		}).Error
+}
+
+// MarkReady 将文档标记为索引完成并记录写入 Chroma 的块数
+func (r *DocumentRepository) MarkReady(id uint, chunkCount int) error {
+	return r.db.Model(&models.Document{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":        models.DocumentStatusReady,
+			"error_message": "",
+			"chunk_count":   chunkCount,
+		}).Error
+}
+
+// FindIndexStatesInBatches 按 ID 顺序分批遍历所有用户的文档，只取对账所需的
+// id、user_id、title、status 和 chunk_count，不加载内容
+func (r *DocumentRepository) FindIndexStatesInBatches(batchSize int, fn func([]models.Document) error) error {
+	var docs []models.Document
+	return r.db.Select("id", "user_id", "title", "status", "chunk_count").
+		Order("id asc").
+		FindInBatches(&docs, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(docs)
+		}).Error
+}
+
+// DocumentFingerprint 是重复检测所需的文档字段，不包含内容
+type DocumentFingerprint struct {
+	ID          uint
+	UserID      uint
+	Title       string
+	Visibility  string
+	ContentHash string
+	SimHash     uint64
+	CreatedAt   time.Time
+}
+
+// fingerprintColumns 是内容指纹及其 SimHash 分段列
+var fingerprintColumns = []string{"content_hash", "sim_hash",
+	"sim_band0", "sim_band1", "sim_band2", "sim_band3", "sim_band4", "sim_band5", "sim_band6", "sim_band7"}
+
+// simBandMatch 匹配任意一段 SimHash 与给定分段相同的文档
+const simBandMatch = "sim_band0 = ? OR sim_band1 = ? OR sim_band2 = ? OR sim_band3 = ? OR " +
+	"sim_band4 = ? OR sim_band5 = ? OR sim_band6 = ? OR sim_band7 = ?"
+
+// fingerprintScope 限定重复检测的范围：共享文档在整个共享知识库内比较，私有文档只与同一用户的私有文档比较
+func (r *DocumentRepository) fingerprintScope(userID uint, visibility string, excludeID uint) *gorm.DB {
+	query := r.db.Model(&models.Document{})
+	if visibility == models.DocumentVisibilityShared {
+		query = query.Where("visibility = ?", models.DocumentVisibilityShared)
+	} else {
+		query = query.Where("user_id = ? AND visibility <> ?", userID, models.DocumentVisibilityShared)
+	}
+	if excludeID != 0 {
+		query = query.Where("id <> ?", excludeID)
+	}
+	return query
+}
+
+// FindByContentHash 返回范围内内容哈希相同的最早一篇文档，不存在时返回 gorm.ErrRecordNotFound
+func (r *DocumentRepository) FindByContentHash(userID uint, visibility, hash string, excludeID uint) (*models.Document, error) {
+	var doc models.Document
+	err := r.fingerprintScope(userID, visibility, excludeID).
+		Omit("content").
+		Where("content_hash = ?", hash).
+		Order("id asc").
+		First(&doc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// ListNearDuplicateCandidates 返回范围内至少有一段 SimHash 与 bands 相同的文档，
+// 走 sim_band 列的索引，不扫描范围内的全部文档
+func (r *DocumentRepository) ListNearDuplicateCandidates(userID uint, visibility string, bands [8]uint8, excludeID uint) ([]DocumentFingerprint, error) {
+	args := make([]interface{}, len(bands))
+	for i, band := range bands {
+		args[i] = band
+	}
+	var fps []DocumentFingerprint
+	err := r.fingerprintScope(userID, visibility, excludeID).
+		Select("id", "user_id", "title", "visibility", "content_hash", "sim_hash", "created_at").
+		Where(simBandMatch, args...).
+		Where("content_hash <> '' AND sim_hash <> 0").
+		Order("id asc").
+		Find(&fps).Error
+	return fps, err
+}
+
+// ListFingerprintsByUser 返回用户自己的全部文档指纹，按创建顺序排列
+func (r *DocumentRepository) ListFingerprintsByUser(userID uint) ([]DocumentFingerprint, error) {
+	var fps []DocumentFingerprint
+	err := r.db.Model(&models.Document{}).
+		Select("id", "user_id", "title", "visibility", "content_hash", "sim_hash", "created_at").
+		Where("user_id = ? AND content_hash <> ''", userID).
+		Order("id asc").
+		Find(&fps).Error
+	return fps, err
+}
+
+// UpdateFingerprint 保存文档的内容指纹
+func (r *DocumentRepository) UpdateFingerprint(doc *models.Document) error {
+	return r.db.Model(doc).
+		Select(fingerprintColumns).
+		Updates(doc).Error
+}
+
+// FindWithoutFingerprint 分批遍历尚未计算指纹的文档（早于重复检测功能创建的文档），
+// 以及有 SimHash 但尚未写入分段列的文档（非零的 SimHash 至少有一段非零）
+func (r *DocumentRepository) FindWithoutFingerprint(batchSize int, fn func([]models.Document) error) error {
+	var docs []models.Document
+	return r.db.Where("content_hash = '' OR content_hash IS NULL OR (sim_hash <> 0 AND "+
+		"sim_band0 = 0 AND sim_band1 = 0 AND sim_band2 = 0 AND sim_band3 = 0 AND "+
+		"sim_band4 = 0 AND sim_band5 = 0 AND sim_band6 = 0 AND sim_band7 = 0)").
+		Order("id asc").
+		FindInBatches(&docs, batchSize, func(tx *gorm.DB, batch int) error {
+			return fn(docs)
+		}).Error
 }
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+)
+
+// createDocuments 写入测试文档，未设置的必填字段使用默认值
+func createDocuments(t *testing.T, repo *DocumentRepository, docs ...*models.Document) {
+	t.Helper()
+	for _, doc := range docs {
+		if doc.Status == "" {
+			doc.Status = models.DocumentStatusReady
+		}
+		if doc.Version == 0 {
+			doc.Version = 1
+		}
+		if err := repo.Create(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 批次进度只读取状态列，不加载正文
+func TestListStatusesByIDs(t *testing.T) {
+	repo := NewDocumentRepository(openTestDB(t))
+	ready := &models.Document{UserID: 1, Title: "a", Content: "正文"}
+	failed := &models.Document{UserID: 2, Title: "b", Content: "正文", Status: models.DocumentStatusFailed, ErrorMessage: "chroma unavailable"}
+	createDocuments(t, repo, ready, failed)
+
+	docs, err := repo.ListStatusesByIDs([]uint{ready.ID, failed.ID, failed.ID + 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("got %d documents, want 2", len(docs))
+	}
+	for _, doc := range docs {
+		if doc.Content != "" || doc.Title != "" {
+			t.Errorf("document %d loaded unselected columns", doc.ID)
+		}
+		if doc.ID == failed.ID && (doc.Status != models.DocumentStatusFailed || doc.ErrorMessage != "chroma unavailable") {
+			t.Errorf("failed document = %+v", doc)
+		}
+	}
+}
+
+// 对账分批遍历全部用户的文档，只读取 chunk_count 等状态列，不加载正文
+func TestFindIndexStatesInBatches(t *testing.T) {
+	repo := NewDocumentRepository(openTestDB(t))
+	var docs []*models.Document
+	for i := 0; i < 5; i++ {
+		docs = append(docs, &models.Document{UserID: uint(i%2 + 1), Title: "t", Content: "正文", ChunkCount: i})
+	}
+	createDocuments(t, repo, docs...)
+
+	var batches []int
+	var seen []models.Document
+	err := repo.FindIndexStatesInBatches(2, func(batch []models.Document) error {
+		batches = append(batches, len(batch))
+		seen = append(seen, batch...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != len(docs) || len(batches) != 3 {
+		t.Fatalf("got %d documents in batches %v, want %d in 3 batches", len(seen), batches, len(docs))
+	}
+	for i, doc := range seen {
+		if doc.ID != docs[i].ID || doc.UserID != docs[i].UserID || doc.ChunkCount != i || doc.Status != models.DocumentStatusReady {
+			t.Errorf("document %d = %+v", i, doc)
+		}
+		if doc.Content != "" {
+			t.Errorf("document %d loaded content", doc.ID)
+		}
+	}
+}
+
+// 复审报告分页，同一复审日期按 ID 排序，只读取报告使用的列
+func TestListReviewDue(t *testing.T) {
+	repo := NewDocumentRepository(openTestDB(t))
+	day := func(s string) *time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	first := &models.Document{UserID: 1, Title: "a", Content: "正文", ReviewBy: day("2024-01-01")}
+	second := &models.Document{UserID: 2, Title: "b", Content: "正文", ReviewBy: day("2024-01-01")}
+	later := &models.Document{UserID: 1, Title: "c", Content: "正文", ReviewBy: day("2024-02-01")}
+	notDue := &models.Document{UserID: 1, Title: "d", Content: "正文", ReviewBy: day("2999-01-01")}
+	noDate := &models.Document{UserID: 1, Title: "e", Content: "正文"}
+	createDocuments(t, repo, later, second, first, notDue, noDate)
+
+	before := *day("2025-01-01")
+	var got []uint
+	for offset := 0; ; offset += 2 {
+		docs, total, err := repo.ListReviewDue(before, 2, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 3 {
+			t.Errorf("total = %d, want 3", total)
+		}
+		for _, doc := range docs {
+			if doc.Content != "" || doc.Title == "" || doc.ReviewBy == nil {
+				t.Errorf("document %d = %+v, want report columns only", doc.ID, doc)
+			}
+			got = append(got, doc.ID)
+		}
+		if len(docs) < 2 {
+			break
+		}
+	}
+	want := []uint{min(first.ID, second.ID), max(first.ID, second.ID), later.ID}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+}
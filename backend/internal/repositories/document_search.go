@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 全文索引名称。使用 ngram 解析器，中文按 ngram_token_size（默认 2）个字切分
+const documentFullTextIndex = "idx_documents_fulltext"
+
+// ngram 解析器默认的最小词元长度，短于该长度的检索词无法命中全文索引
+const fullTextMinTokenRunes = 2
+
+// 摘录在首个命中位置前后各取的字符数
+const (
+	searchExcerptBefore = 80
+	searchExcerptLength = 240
+)
+
+// 文档检索方式
+const (
+	DocumentSearchFullText = "fulltext"
+	DocumentSearchLike     = "like"
+)
+
+// DocumentSearchHit 是一条检索结果。Excerpt 为内容中首个检索词附近的片段
+type DocumentSearchHit struct {
+	ID           uint      `json:"id"`
+	UserID       uint      `json:"user_id"`
+	Title        string    `json:"title"`
+	Visibility   string    `json:"visibility"`
+	Category     string    `json:"category"`
+	Tags         []string  `json:"tags" gorm:"serializer:json"`
+	Status       string    `json:"status"`
+	Version      int       `json:"version"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Score        float64   `json:"score"`
+	Excerpt      string    `json:"-"`
+	ExcerptStart int       `json:"-"` // 摘录在内容中的起始字符位置，从 1 开始
+	ContentChars int       `json:"-"`
+}
+
+// EnsureFullTextIndex 在 documents(title, content) 上创建 ngram 全文索引。
+// 数据库不支持 ngram 解析器（如 MariaDB、旧版 MySQL）时返回错误，检索退回 LIKE 实现
+func (r *DocumentRepository) EnsureFullTextIndex() error {
+	if !r.db.Migrator().HasIndex(&models.Document{}, documentFullTextIndex) {
+		err := r.db.Exec("CREATE FULLTEXT INDEX " + documentFullTextIndex + " ON documents (title, content) WITH PARSER ngram").Error
+		if err != nil {
+			return err
+		}
+	}
+	r.fullText = true
+	return nil
+}
+
+// SearchMode 返回检索 terms 时使用的方式：存在短于最小词元长度的词时全文索引无法命中，改用 LIKE
+func (r *DocumentRepository) SearchMode(terms []string) string {
+	if !r.fullText {
+		return DocumentSearchLike
+	}
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) < fullTextMinTokenRunes {
+			return DocumentSearchLike
+		}
+	}
+	return DocumentSearchFullText
+}
+
+// Search 在用户可访问的文档中检索同时包含全部 terms 的文档，按相关度排序，返回一页结果和命中总数
+func (r *DocumentRepository) Search(userID uint, filter DocumentFilter, terms []string, limit, offset int) ([]DocumentSearchHit, int64, error) {
+	var (
+		match     *gorm.DB
+		score     string
+		scoreArgs []interface{}
+	)
+	if r.SearchMode(terms) == DocumentSearchFullText {
+		against := booleanQuery(terms)
+		match = r.db.Where("MATCH(title, content) AGAINST (? IN BOOLEAN MODE)", against)
+		score, scoreArgs = "MATCH(title, content) AGAINST (? IN BOOLEAN MODE)", []interface{}{against}
+	} else {
+		// 每个词须出现在标题或内容中；标题命中的权重更高
+		match = r.db
+		var parts []string
+		for _, term := range terms {
+			pattern := "%" + escapeLike(term) + "%"
+			match = match.Where("(title LIKE ? OR content LIKE ?)", pattern, pattern)
+			parts = append(parts, "(title LIKE ?) * 2 + (content LIKE ?)")
+			scoreArgs = append(scoreArgs, pattern, pattern)
+		}
+		score = strings.Join(parts, " + ")
+	}
+
+	var total int64
+	if err := r.accessibleQuery(userID, filter).Where(match).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	args := append(scoreArgs, terms[0], searchExcerptBefore, searchExcerptLength, terms[0], searchExcerptBefore)
+	var hits []DocumentSearchHit
+	err := r.accessibleQuery(userID, filter).Where(match).
+		Select("id, user_id, title, visibility, category, tags, status, version, updated_at, "+
+			"("+score+") AS score, "+
+			"SUBSTRING(content, GREATEST(LOCATE(?, content) - ?, 1), ?) AS excerpt, "+
+			"GREATEST(LOCATE(?, content) - ?, 1) AS excerpt_start, "+
+			"CHAR_LENGTH(content) AS content_chars", args...).
+		Order("score desc").Order("updated_at desc").Order("id desc").
+		Limit(limit).Offset(offset).
+		Find(&hits).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return hits, total, nil
+}
+
+// booleanQuery 将检索词转换为 BOOLEAN MODE 查询：每个词作为必须出现的短语，
+// 短语内的运算符不生效，因此只需去掉双引号
+func booleanQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = `+"` + strings.ReplaceAll(term, `"`, " ") + `"`
+	}
+	return strings.Join(parts, " ")
+}
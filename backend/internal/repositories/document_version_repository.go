@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DocumentVersionRepository 存储文档的历史版本
+type DocumentVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewDocumentVersionRepository(db *gorm.DB) *DocumentVersionRepository {
+	return &DocumentVersionRepository{db: db}
+}
+
+// WithTx 返回绑定到指定事务的仓储
+func (r *DocumentVersionRepository) WithTx(tx *gorm.DB) *DocumentVersionRepository {
+	return &DocumentVersionRepository{db: tx}
+}
+
+func (r *DocumentVersionRepository) Create(version *models.DocumentVersion) error {
+	return r.db.Create(version).Error
+}
+
+// ListByDocument 按版本号倒序返回文档的所有版本，不包含内容
+func (r *DocumentVersionRepository) ListByDocument(documentID uint) ([]models.DocumentVersion, error) {
+	var versions []models.DocumentVersion
+	err := r.db.Omit("content").
+		Where("document_id = ?", documentID).
+		Order("version desc").
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// Get 返回文档的指定版本
+func (r *DocumentVersionRepository) Get(documentID uint, version int) (*models.DocumentVersion, error) {
+	var v models.DocumentVersion
+	if err := r.db.Where("document_id = ? AND version = ?", documentID, version).First(&v).Error; err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Exists 返回文档是否已有指定版本的快照
+func (r *DocumentVersionRepository) Exists(documentID uint, version int) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.DocumentVersion{}).
+		Where("document_id = ? AND version = ?", documentID, version).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteByDocument 删除文档的全部版本
+func (r *DocumentVersionRepository) DeleteByDocument(documentID uint) error {
+	return r.db.Where("document_id = ?", documentID).Delete(&models.DocumentVersion{}).Error
+}
@@ -0,0 +1,88 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// IndexJobRepository 提供索引任务队列的持久化操作
+type IndexJobRepository struct {
+	db *gorm.DB
+}
+
+func NewIndexJobRepository(db *gorm.DB) *IndexJobRepository {
+	return &IndexJobRepository{db: db}
+}
+
+func (r *IndexJobRepository) Create(job *models.IndexJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *IndexJobRepository) Update(job *models.IndexJob) error {
+	return r.db.Save(job).Error
+}
+
+// HasPending 返回文档是否已有等待执行的任务，用于避免重复入队
+func (r *IndexJobRepository) HasPending(documentID uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.IndexJob{}).
+		Where("document_id = ? AND status = ?", documentID, models.IndexJobStatusPending).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ClaimNext 领取一个到期的待执行任务并将其标记为 processing。
+// 通过带状态条件的 UPDATE 实现乐观抢占，多个 worker 并发领取时只有一个会成功。
+// 没有可领取的任务时返回 (nil, nil)
+func (r *IndexJobRepository) ClaimNext(now time.Time) (*models.IndexJob, error) {
+	for {
+		var job models.IndexJob
+		err := r.db.Where("status = ? AND next_run_at <= ?", models.IndexJobStatusPending, now).
+			Order("next_run_at asc, id asc").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := r.db.Model(&models.IndexJob{}).
+			Where("id = ? AND status = ?", job.ID, models.IndexJobStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.IndexJobStatusProcessing,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 被其他 worker 抢先领取，继续尝试下一个
+			continue
+		}
+
+		job.Status = models.IndexJobStatusProcessing
+		job.Attempts++
+		return &job, nil
+	}
+}
+
+// RequeueProcessing 将上次运行中断时仍处于 processing 的任务放回队列，
+// 应在 worker 启动前调用
+func (r *IndexJobRepository) RequeueProcessing() (int64, error) {
+	result := r.db.Model(&models.IndexJob{}).
+		Where("status = ?", models.IndexJobStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":      models.IndexJobStatusPending,
+			"next_run_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
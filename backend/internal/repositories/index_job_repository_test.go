@@ -0,0 +1,77 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+)
+
+func TestIndexJobClaimNext(t *testing.T) {
+	repo := NewIndexJobRepository(openTestDB(t))
+	now := time.Now()
+
+	jobs := []*models.IndexJob{
+		{DocumentID: 1, UserID: 1, Status: models.IndexJobStatusPending, NextRunAt: now.Add(-time.Minute)},
+		{DocumentID: 2, UserID: 1, Status: models.IndexJobStatusPending, NextRunAt: now.Add(-time.Hour)},
+		// 尚未到期（退避中）
+		{DocumentID: 3, UserID: 1, Status: models.IndexJobStatusPending, NextRunAt: now.Add(time.Hour)},
+		{DocumentID: 4, UserID: 1, Status: models.IndexJobStatusDone, NextRunAt: now.Add(-time.Hour)},
+	}
+	for _, job := range jobs {
+		if err := repo.Create(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 按 next_run_at 先后领取，领取时 attempts 加一
+	var claimed []uint
+	for {
+		job, err := repo.ClaimNext(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job == nil {
+			break
+		}
+		if job.Status != models.IndexJobStatusProcessing || job.Attempts != 1 {
+			t.Errorf("claimed job %d: status %q attempts %d", job.ID, job.Status, job.Attempts)
+		}
+		claimed = append(claimed, job.DocumentID)
+	}
+	if len(claimed) != 2 || claimed[0] != 2 || claimed[1] != 1 {
+		t.Errorf("claimed documents %v, want [2 1]", claimed)
+	}
+
+	pending, err := repo.HasPending(3)
+	if err != nil || !pending {
+		t.Errorf("HasPending(3) = %v, %v; want true", pending, err)
+	}
+	if job, err := repo.ClaimNext(now.Add(2 * time.Hour)); err != nil || job == nil || job.DocumentID != 3 {
+		t.Errorf("ClaimNext after backoff = %+v, %v", job, err)
+	}
+}
+
+// 重启时 processing 的任务回到队列，并且可以立即再次领取
+func TestIndexJobRequeueProcessing(t *testing.T) {
+	repo := NewIndexJobRepository(openTestDB(t))
+	now := time.Now()
+
+	for _, status := range []string{models.IndexJobStatusProcessing, models.IndexJobStatusProcessing, models.IndexJobStatusFailed} {
+		if err := repo.Create(&models.IndexJob{DocumentID: 1, UserID: 1, Status: status, Attempts: 2, NextRunAt: now.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requeued, err := repo.RequeueProcessing()
+	if err != nil || requeued != 2 {
+		t.Fatalf("RequeueProcessing = %d, %v; want 2", requeued, err)
+	}
+	job, err := repo.ClaimNext(time.Now().Add(time.Second))
+	if err != nil || job == nil {
+		t.Fatalf("ClaimNext after requeue = %v, %v", job, err)
+	}
+	// 重启前的尝试次数保留，继续累加
+	if job.Attempts != 3 {
+		t.Errorf("attempts = %d, want 3", job.Attempts)
+	}
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KnowledgeBaseRepository 提供知识库的 CRUD 操作
+type KnowledgeBaseRepository struct {
+	db *gorm.DB
+}
+
+func NewKnowledgeBaseRepository(db *gorm.DB) *KnowledgeBaseRepository {
+	return &KnowledgeBaseRepository{db: db}
+}
+
+// Transaction 在同一数据库事务中执行 fn
+func (r *KnowledgeBaseRepository) Transaction(fn func(tx *gorm.DB) error) error {
+	return r.db.Transaction(fn)
+}
+
+// WithTx 返回绑定到指定事务的仓储
+func (r *KnowledgeBaseRepository) WithTx(tx *gorm.DB) *KnowledgeBaseRepository {
+	return &KnowledgeBaseRepository{db: tx}
+}
+
+func (r *KnowledgeBaseRepository) Create(kb *models.KnowledgeBase) error {
+	return r.db.Create(kb).Error
+}
+
+func (r *KnowledgeBaseRepository) Update(kb *models.KnowledgeBase) error {
+	return r.db.Save(kb).Error
+}
+
+func (r *KnowledgeBaseRepository) ListByUser(userID uint) ([]models.KnowledgeBase, error) {
+	var kbs []models.KnowledgeBase
+	if err := r.db.Where("user_id = ?", userID).Order("name asc").Find(&kbs).Error; err != nil {
+		return nil, err
+	}
+	return kbs, nil
+}
+
+func (r *KnowledgeBaseRepository) GetByIDAndUser(id, userID uint) (*models.KnowledgeBase, error) {
+	var kb models.KnowledgeBase
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&kb).Error; err != nil {
+		return nil, err
+	}
+	return &kb, nil
+}
+
+// LockByIDAndUser 在事务中锁定用户的知识库行，exclusive 为 true 时加排他锁（FOR UPDATE），
+// 否则加共享锁（FOR SHARE）。知识库不存在时返回 gorm.ErrRecordNotFound
+func (r *KnowledgeBaseRepository) LockByIDAndUser(id, userID uint, exclusive bool) error {
+	strength := "SHARE"
+	if exclusive {
+		strength = "UPDATE"
+	}
+	var kb models.KnowledgeBase
+	return r.db.Clauses(clause.Locking{Strength: strength}).
+		Select("id").
+		Where("id = ? AND user_id = ?", id, userID).
+		First(&kb).Error
+}
+
+// CountOwned 返回 ids 中属于该用户的知识库数量，用于校验提问时选择的知识库
+func (r *KnowledgeBaseRepository) CountOwned(ids []uint, userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.KnowledgeBase{}).
+		Where("id IN ? AND user_id = ?", ids, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CountDocuments 返回知识库中的文档数量
+func (r *KnowledgeBaseRepository) CountDocuments(id uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Document{}).Where("knowledge_base_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+func (r *KnowledgeBaseRepository) DeleteByIDAndUser(id, userID uint) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.KnowledgeBase{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
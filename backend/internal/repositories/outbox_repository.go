@@ -0,0 +1,83 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// OutboxRepository 提供 outbox 事件的持久化操作
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// WithTx 返回绑定到指定事务的仓储
+func (r *OutboxRepository) WithTx(tx *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: tx}
+}
+
+func (r *OutboxRepository) Create(event *models.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+func (r *OutboxRepository) Update(event *models.OutboxEvent) error {
+	return r.db.Save(event).Error
+}
+
+// ClaimNext 按写入顺序领取一个到期的待分发事件并标记为 processing，
+// 同一文档还有更早的未完成事件（如正在退避重试）时跳过该文档，保证单个文档的事件按顺序应用；
+// 已放弃（failed）的事件不再阻塞之后的事件。没有可领取的事件时返回 (nil, nil)
+func (r *OutboxRepository) ClaimNext(now time.Time) (*models.OutboxEvent, error) {
+	for {
+		var event models.OutboxEvent
+		err := r.db.Where("status = ? AND next_run_at <= ?", models.OutboxStatusPending, now).
+			Where("NOT EXISTS (?)", r.db.Table("outbox_events AS earlier").
+				Select("1").
+				Where("earlier.document_id = outbox_events.document_id AND earlier.id < outbox_events.id AND earlier.status NOT IN ?",
+					[]string{models.OutboxStatusDone, models.OutboxStatusFailed})).
+			Order("id asc").
+			First(&event).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := r.db.Model(&models.OutboxEvent{}).
+			Where("id = ? AND status = ?", event.ID, models.OutboxStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.OutboxStatusProcessing,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"updated_at": now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		event.Status = models.OutboxStatusProcessing
+		event.Attempts++
+		return &event, nil
+	}
+}
+
+// RequeueProcessing 将上次运行中断时仍处于 processing 的事件放回队列
+func (r *OutboxRepository) RequeueProcessing() (int64, error) {
+	result := r.db.Model(&models.OutboxEvent{}).
+		Where("status = ?", models.OutboxStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":      models.OutboxStatusPending,
+			"next_run_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+)
+
+// 同一文档较早的事件未完成（退避中或正在处理）时，之后的事件不被领取；done 和 failed 的事件不再阻塞
+func TestOutboxClaimNextKeepsDocumentOrder(t *testing.T) {
+	repo := NewOutboxRepository(openTestDB(t))
+	now := time.Now()
+	add := func(docID uint, status string, nextRunAt time.Time) *models.OutboxEvent {
+		event := &models.OutboxEvent{
+			EventType:  models.OutboxEventDocumentUpdated,
+			DocumentID: docID,
+			UserID:     1,
+			Status:     status,
+			NextRunAt:  nextRunAt,
+		}
+		if err := repo.Create(event); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	backoff := add(1, models.OutboxStatusPending, now.Add(time.Hour))
+	blocked := add(1, models.OutboxStatusPending, now.Add(-time.Minute))
+	add(2, models.OutboxStatusDone, now.Add(-time.Hour))
+	add(2, models.OutboxStatusFailed, now.Add(-time.Hour))
+	free := add(2, models.OutboxStatusPending, now.Add(-time.Minute))
+	add(3, models.OutboxStatusProcessing, now.Add(-time.Hour))
+	add(3, models.OutboxStatusPending, now.Add(-time.Minute))
+
+	event, err := repo.ClaimNext(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event == nil || event.ID != free.ID || event.Status != models.OutboxStatusProcessing || event.Attempts != 1 {
+		t.Fatalf("ClaimNext = %+v, want event %d", event, free.ID)
+	}
+	if event, err := repo.ClaimNext(now); err != nil || event != nil {
+		t.Fatalf("ClaimNext = %+v, %v; want nothing claimable", event, err)
+	}
+
+	// 较早的事件放弃后，同一文档之后的事件按顺序继续
+	backoff.Status = models.OutboxStatusFailed
+	if err := repo.Update(backoff); err != nil {
+		t.Fatal(err)
+	}
+	event, err = repo.ClaimNext(now)
+	if err != nil || event == nil || event.ID != blocked.ID {
+		t.Fatalf("ClaimNext after giving up = %+v, %v; want event %d", event, err, blocked.ID)
+	}
+}
+
+func TestOutboxRequeueProcessing(t *testing.T) {
+	repo := NewOutboxRepository(openTestDB(t))
+	event := &models.OutboxEvent{
+		EventType:  models.OutboxEventDocumentDeleted,
+		DocumentID: 1,
+		UserID:     1,
+		Status:     models.OutboxStatusProcessing,
+		Attempts:   1,
+		NextRunAt:  time.Now().Add(time.Hour),
+	}
+	if err := repo.Create(event); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := repo.RequeueProcessing(); err != nil || n != 1 {
+		t.Fatalf("RequeueProcessing = %d, %v", n, err)
+	}
+	claimed, err := repo.ClaimNext(time.Now().Add(time.Second))
+	if err != nil || claimed == nil || claimed.ID != event.ID || claimed.Attempts != 2 {
+		t.Errorf("ClaimNext after requeue = %+v, %v", claimed, err)
+	}
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"medical-qa-assistant/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// UploadBatchRepository 存储批量上传批次及其条目
+type UploadBatchRepository struct {
+	db *gorm.DB
+}
+
+func NewUploadBatchRepository(db *gorm.DB) *UploadBatchRepository {
+	return &UploadBatchRepository{db: db}
+}
+
+// Create 写入批次及已附带的条目
+func (r *UploadBatchRepository) Create(batch *models.UploadBatch) error {
+	return r.db.Create(batch).Error
+}
+
+// AddItem 写入批次的一个条目，并在同一事务中累加批次的创建或拒绝计数
+func (r *UploadBatchRepository) AddItem(batch *models.UploadBatch, item *models.UploadBatchItem) error {
+	item.BatchID = batch.ID
+	column := "rejected"
+	if item.Status == models.UploadBatchItemCreated {
+		column = "created"
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(item).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.UploadBatch{}).Where("id = ?", batch.ID).
+			UpdateColumn(column, gorm.Expr(column+" + 1")).Error
+	})
+	if err != nil {
+		return err
+	}
+	batch.Items = append(batch.Items, *item)
+	if column == "created" {
+		batch.Created++
+	} else {
+		batch.Rejected++
+	}
+	return nil
+}
+
+// GetByIDAndUser 返回用户自己的批次，条目按上传顺序排列
+func (r *UploadBatchRepository) GetByIDAndUser(id, userID uint) (*models.UploadBatch, error) {
+	var batch models.UploadBatch
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
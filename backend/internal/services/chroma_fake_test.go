@@ -0,0 +1,301 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"medical-qa-assistant/internal/logger"
+
+	"go.uber.org/zap"
+)
+
+// fakeChroma 在内存中模拟 Chroma v2 REST 接口的集合操作，以及 OpenAI 兼容的嵌入接口。
+// upsert 与真实 Chroma 一样合并元数据，where 支持 $and、$or、$in、$nin、$eq、$ne、$gte、$lte
+type fakeChroma struct {
+	mu      sync.Mutex
+	records map[string]*fakeRecord
+	// unavailable 为 true 时 Chroma 接口都返回 503，嵌入接口不受影响
+	unavailable bool
+	// beforeUpsert 在处理 upsert 之前（未持锁）调用一次，用于模拟与写入并发的其他操作
+	beforeUpsert func()
+	// onGet 在处理每个 get 请求之前（持锁）调用，可直接修改 records，用于模拟分页期间的并发删除
+	onGet func(body map[string]interface{})
+}
+
+type fakeRecord struct {
+	document  string
+	embedding []float64
+	metadata  map[string]interface{}
+}
+
+// newFakeRAG 返回连接到 fakeChroma 的 RAGService
+func newFakeRAG(t *testing.T) (*RAGService, *fakeChroma) {
+	t.Helper()
+	logger.L = zap.NewNop()
+	fake := &fakeChroma{records: make(map[string]*fakeRecord)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	rag := NewRAGService("test-key", server.URL+"/v1", "test-embedding", server.URL, "test")
+	return rag, fake
+}
+
+func (f *fakeChroma) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/upsert") {
+		f.mu.Lock()
+		hook := f.beforeUpsert
+		f.beforeUpsert = nil
+		f.mu.Unlock()
+		if hook != nil {
+			hook()
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]interface{}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	path := r.URL.Path
+	if f.unavailable && path != "/v1/embeddings" {
+		http.Error(w, "chroma unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	switch {
+	case path == "/v1/embeddings":
+		f.embeddings(w, body)
+	case r.Method == http.MethodGet && strings.HasSuffix(path, "/collections/test"):
+		writeJSON(w, map[string]interface{}{"id": "test-id", "name": "test"})
+	case strings.HasSuffix(path, "/upsert"):
+		f.upsert(body)
+		writeJSON(w, map[string]interface{}{})
+	case strings.HasSuffix(path, "/update"):
+		f.update(body)
+		writeJSON(w, map[string]interface{}{})
+	case strings.HasSuffix(path, "/delete"):
+		for _, id := range stringList(body["ids"]) {
+			delete(f.records, id)
+		}
+		writeJSON(w, map[string]interface{}{})
+	case strings.HasSuffix(path, "/get"):
+		f.get(w, body)
+	case strings.HasSuffix(path, "/query"):
+		f.query(w, body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// embeddings 为每段文本返回确定的向量
+func (f *fakeChroma) embeddings(w http.ResponseWriter, body map[string]interface{}) {
+	var data []map[string]interface{}
+	for i, input := range stringList(body["input"]) {
+		data = append(data, map[string]interface{}{
+			"object":    "embedding",
+			"index":     i,
+			"embedding": []float64{float64(len(input)), 1, 0},
+		})
+	}
+	writeJSON(w, map[string]interface{}{"object": "list", "data": data, "model": body["model"]})
+}
+
+func (f *fakeChroma) upsert(body map[string]interface{}) {
+	ids := stringList(body["ids"])
+	documents := stringList(body["documents"])
+	embeddings, _ := body["embeddings"].([]interface{})
+	metadatas, _ := body["metadatas"].([]interface{})
+	for i, id := range ids {
+		record, ok := f.records[id]
+		if !ok {
+			record = &fakeRecord{metadata: map[string]interface{}{}}
+			f.records[id] = record
+		}
+		record.document = documents[i]
+		record.embedding = nil
+		for _, v := range embeddings[i].([]interface{}) {
+			record.embedding = append(record.embedding, v.(float64))
+		}
+		for key, value := range metadatas[i].(map[string]interface{}) {
+			record.metadata[key] = value
+		}
+	}
+}
+
+func (f *fakeChroma) update(body map[string]interface{}) {
+	metadatas, _ := body["metadatas"].([]interface{})
+	for i, id := range stringList(body["ids"]) {
+		if record, ok := f.records[id]; ok {
+			for key, value := range metadatas[i].(map[string]interface{}) {
+				record.metadata[key] = value
+			}
+		}
+	}
+}
+
+func (f *fakeChroma) matching(body map[string]interface{}) []string {
+	where, _ := body["where"].(map[string]interface{})
+	wanted := stringList(body["ids"])
+	var ids []string
+	for id, record := range f.records {
+		if len(wanted) > 0 && !containsString(wanted, id) {
+			continue
+		}
+		if where == nil || matchWhere(where, record.metadata) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (f *fakeChroma) get(w http.ResponseWriter, body map[string]interface{}) {
+	if f.onGet != nil {
+		f.onGet(body)
+	}
+	ids := f.matching(body)
+	if offset, ok := body["offset"].(float64); ok {
+		ids = ids[min(int(offset), len(ids)):]
+	}
+	if limit, ok := body["limit"].(float64); ok && int(limit) < len(ids) {
+		ids = ids[:int(limit)]
+	}
+	resp := map[string]interface{}{"ids": ids}
+	documents, metadatas, embeddings := []string{}, []map[string]interface{}{}, [][]float64{}
+	for _, id := range ids {
+		documents = append(documents, f.records[id].document)
+		metadatas = append(metadatas, f.records[id].metadata)
+		embeddings = append(embeddings, f.records[id].embedding)
+	}
+	for _, include := range stringList(body["include"]) {
+		switch include {
+		case "documents":
+			resp["documents"] = documents
+		case "metadatas":
+			resp["metadatas"] = metadatas
+		case "embeddings":
+			resp["embeddings"] = embeddings
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func (f *fakeChroma) query(w http.ResponseWriter, body map[string]interface{}) {
+	ids := f.matching(body)
+	if n, ok := body["n_results"].(float64); ok && int(n) < len(ids) {
+		ids = ids[:int(n)]
+	}
+	documents, metadatas, distances := []string{}, []map[string]interface{}{}, []float64{}
+	for i, id := range ids {
+		documents = append(documents, f.records[id].document)
+		metadatas = append(metadatas, f.records[id].metadata)
+		distances = append(distances, float64(i)/10)
+	}
+	writeJSON(w, map[string]interface{}{
+		"ids":       [][]string{ids},
+		"documents": [][]string{documents},
+		"metadatas": [][]map[string]interface{}{metadatas},
+		"distances": [][]float64{distances},
+	})
+}
+
+// matchWhere 按 Chroma 的语义判断元数据是否满足过滤条件，缺少字段的记录不匹配
+func matchWhere(where map[string]interface{}, metadata map[string]interface{}) bool {
+	for key, cond := range where {
+		switch key {
+		case "$and":
+			for _, sub := range cond.([]interface{}) {
+				if !matchWhere(sub.(map[string]interface{}), metadata) {
+					return false
+				}
+			}
+		case "$or":
+			any := false
+			for _, sub := range cond.([]interface{}) {
+				if matchWhere(sub.(map[string]interface{}), metadata) {
+					any = true
+					break
+				}
+			}
+			if !any {
+				return false
+			}
+		default:
+			value, ok := metadata[key]
+			if !ok || !matchOperator(value, cond) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchOperator(value, cond interface{}) bool {
+	ops, ok := cond.(map[string]interface{})
+	if !ok {
+		return value == cond
+	}
+	for op, arg := range ops {
+		switch op {
+		case "$eq":
+			if value != arg {
+				return false
+			}
+		case "$ne":
+			if value == arg {
+				return false
+			}
+		case "$in", "$nin":
+			found := false
+			for _, v := range arg.([]interface{}) {
+				if v == value {
+					found = true
+				}
+			}
+			if found != (op == "$in") {
+				return false
+			}
+		case "$gte", "$lte":
+			n, ok := value.(float64)
+			if !ok || (op == "$gte" && n < arg.(float64)) || (op == "$lte" && n > arg.(float64)) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func stringList(v interface{}) []string {
+	items, _ := v.([]interface{})
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
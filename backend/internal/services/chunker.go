@@ -0,0 +1,151 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"medical-qa-assistant/internal/extractor"
+)
+
+// 章节路径中各级标题的分隔符
+const sectionSeparator = " > "
+
+// Markdown 风格的标题行，DOCX/HTML 提取器会把文档标题输出为这种形式
+var headingLine = regexp.MustCompile(`^(#{1,6})[ \t]+(\S.*)$`)
+
+// textChunk 是分块结果，page/pageEnd 为块起止页码（从 1 开始），文本未分页时为 0；
+// section 为块起始处所在的章节路径，如“诊断 > 实验室检查”；[start, end) 为块在原文中的 rune 偏移
+type textChunk struct {
+	text       string
+	page       int
+	pageEnd    int
+	section    string
+	start, end int
+}
+
+// textSegment 是以标题行开头（或位于首个标题之前）的一段文本，[start, end) 为 rune 偏移
+type textSegment struct {
+	start, end int
+	section    string
+}
+
+// chunkText 将文本分割成不超过 maxLen 字符的块：
+// 先在标题行处切分章节，较短的相邻章节合并到同一块，较长的章节再按长度切分。
+// 没有标题的文本与简单按字符切分的结果一致。
+// 文本中的分页符（extractor.PageBreak）换成换行，并据其偏移记录每块所在页码
+func chunkText(text string, maxLen int) []textChunk {
+	if maxLen <= 0 {
+		return nil
+	}
+
+	paged := strings.Contains(text, extractor.PageBreak)
+	runes := []rune(text)
+	// pageStarts[i] 为第 i+1 页首字符的偏移
+	pageStarts := []int{0}
+	for i, r := range runes {
+		if string(r) == extractor.PageBreak {
+			runes[i] = '\n'
+			pageStarts = append(pageStarts, i+1)
+		}
+	}
+
+	// 去掉首尾空白，与按字符切分前的 TrimSpace 一致
+	start, end := 0, len(runes)
+	for start < end && unicode.IsSpace(runes[start]) {
+		start++
+	}
+	for end > start && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	if start == end {
+		return nil
+	}
+
+	var chunks []textChunk
+	emit := func(from, to int, section string) {
+		chunk := textChunk{text: string(runes[from:to]), section: section, start: from, end: to}
+		if paged {
+			chunk.page = pageAt(pageStarts, from)
+			chunk.pageEnd = pageAt(pageStarts, to-1)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	var pending *textSegment
+	for _, seg := range splitSections(runes, start, end) {
+		if pending != nil && seg.end-pending.start <= maxLen {
+			pending.end = seg.end
+			continue
+		}
+		if pending != nil {
+			emit(pending.start, pending.end, pending.section)
+			pending = nil
+		}
+		pos := seg.start
+		for ; seg.end-pos > maxLen; pos += maxLen {
+			emit(pos, pos+maxLen, seg.section)
+		}
+		// 章节的最后一段留待与后面的短章节合并
+		pending = &textSegment{start: pos, end: seg.end, section: seg.section}
+	}
+	if pending != nil {
+		emit(pending.start, pending.end, pending.section)
+	}
+	return chunks
+}
+
+// splitSections 在标题行处切分 runes[start:end]，并记录每段的章节路径
+func splitSections(runes []rune, start, end int) []textSegment {
+	var segments []textSegment
+	var headings []string // headings[i] 为第 i+1 级标题
+	segStart, section := start, ""
+
+	for lineStart := start; lineStart < end; {
+		lineEnd := lineStart
+		for lineEnd < end && runes[lineEnd] != '\n' {
+			lineEnd++
+		}
+		m := headingLine.FindStringSubmatch(strings.TrimRightFunc(string(runes[lineStart:lineEnd]), unicode.IsSpace))
+		if m != nil {
+			if lineStart > segStart {
+				segments = append(segments, trimSegment(runes, segStart, lineStart, section))
+			}
+			level := len(m[1])
+			if len(headings) >= level {
+				headings = headings[:level-1]
+			}
+			for len(headings) < level-1 {
+				headings = append(headings, "")
+			}
+			headings = append(headings, strings.TrimSpace(m[2]))
+			segStart, section = lineStart, joinSection(headings)
+		}
+		lineStart = lineEnd + 1
+	}
+	return append(segments, trimSegment(runes, segStart, end, section))
+}
+
+// trimSegment 去掉段尾的空白（通常是下一个标题前的换行）
+func trimSegment(runes []rune, start, end int, section string) textSegment {
+	for end > start && unicode.IsSpace(runes[end-1]) {
+		end--
+	}
+	return textSegment{start: start, end: end, section: section}
+}
+
+func joinSection(headings []string) string {
+	parts := make([]string, 0, len(headings))
+	for _, h := range headings {
+		if h != "" {
+			parts = append(parts, h)
+		}
+	}
+	return strings.Join(parts, sectionSeparator)
+}
+
+// pageAt 返回偏移 offset 所在的页码
+func pageAt(pageStarts []int, offset int) int {
+	return sort.Search(len(pageStarts), func(i int) bool { return pageStarts[i] > offset })
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkTextEmpty(t *testing.T) {
+	for _, text := range []string{"", "  \n\t", "\f\f"} {
+		if chunks := chunkText(text, 10); len(chunks) != 0 {
+			t.Errorf("chunkText(%q) = %v, want none", text, chunks)
+		}
+	}
+	if chunks := chunkText("内容", 0); chunks != nil {
+		t.Errorf("maxLen 0 = %v, want nil", chunks)
+	}
+}
+
+// 没有标题时按字符数切分，偏移按 rune 计算
+func TestChunkTextPlain(t *testing.T) {
+	text := "  " + strings.Repeat("糖", 10) + strings.Repeat("尿", 10) + "病病病\n"
+	chunks := chunkText(text, 10)
+	want := []textChunk{
+		{text: strings.Repeat("糖", 10), start: 2, end: 12},
+		{text: strings.Repeat("尿", 10), start: 12, end: 22},
+		{text: "病病病", start: 22, end: 25},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks: %+v", len(chunks), chunks)
+	}
+	for i, w := range want {
+		if chunks[i] != w {
+			t.Errorf("chunk %d = %+v, want %+v", i, chunks[i], w)
+		}
+	}
+}
+
+func TestChunkTextSections(t *testing.T) {
+	text := "前言\n# 诊断\n空腹血糖≥7.0\n## 实验室检查\nHbA1c≥6.5%\n# 治疗\n### 药物\n二甲双胍"
+
+	// 块足够大时相邻章节合并，章节取块起始处的路径
+	chunks := chunkText(text, 1000)
+	if len(chunks) != 1 || chunks[0].section != "" || chunks[0].text != text {
+		t.Fatalf("large maxLen: %+v", chunks)
+	}
+
+	chunks = chunkText(text, 20)
+	var sections []string
+	for _, c := range chunks {
+		sections = append(sections, c.section)
+		if got := string([]rune(text)[c.start:c.end]); got != c.text {
+			t.Errorf("chunk text %q does not match offsets [%d,%d) = %q", c.text, c.start, c.end, got)
+		}
+		if n := len([]rune(c.text)); n > 20 {
+			t.Errorf("chunk %q has %d runes, want <= 20", c.text, n)
+		}
+	}
+	// 前言与较短的“诊断”合并到第一块，章节取块起始处（前言）的路径
+	want := []string{"", "诊断 > 实验室检查", "治疗"}
+	if strings.Join(sections, "|") != strings.Join(want, "|") {
+		t.Errorf("sections = %q, want %q", sections, want)
+	}
+	if !strings.Contains(chunks[0].text, "# 诊断") {
+		t.Errorf("first chunk = %q, want it to include the 诊断 section", chunks[0].text)
+	}
+	// 跳级的标题（# 之后直接 ###）不产生空的路径段
+	if last := chunks[len(chunks)-1]; !strings.Contains(last.text, "### 药物") {
+		t.Errorf("last chunk = %q", last.text)
+	}
+	if got := splitSections([]rune("### 药物\n内容"), 0, 9); got[0].section != "药物" {
+		t.Errorf("skipped levels section = %q, want 药物", got[0].section)
+	}
+}
+
+// 长章节按长度切分，每一块都带该章节路径
+func TestChunkTextLongSection(t *testing.T) {
+	text := "# 用法用量\n" + strings.Repeat("剂", 25)
+	chunks := chunkText(text, 10)
+	if len(chunks) != 4 {
+		t.Fatalf("got %d chunks: %+v", len(chunks), chunks)
+	}
+	for _, c := range chunks {
+		if c.section != "用法用量" {
+			t.Errorf("chunk %q section = %q", c.text, c.section)
+		}
+	}
+}
+
+func TestChunkTextPages(t *testing.T) {
+	text := "第一页内容\f第二页内容\f\f第四页"
+	chunks := chunkText(text, 8)
+	type pages struct{ page, pageEnd int }
+	var got []pages
+	for _, c := range chunks {
+		if strings.Contains(c.text, "\f") {
+			t.Errorf("chunk %q keeps page break", c.text)
+		}
+		got = append(got, pages{c.page, c.pageEnd})
+	}
+	// 空白页也计入页码
+	want := []pages{{1, 2}, {2, 4}}
+	if len(got) != len(want) {
+		t.Fatalf("pages = %v, want %v (chunks %+v)", got, want, chunks)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("chunk %d pages = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// 未分页的文本页码为 0
+	if c := chunkText("没有分页", 10); c[0].page != 0 || c[0].pageEnd != 0 {
+		t.Errorf("unpaged chunk pages = %d-%d", c[0].page, c[0].pageEnd)
+	}
+}
+
+func TestPageAt(t *testing.T) {
+	starts := []int{0, 5, 11, 12}
+	tests := map[int]int{0: 1, 4: 1, 5: 2, 10: 2, 11: 3, 12: 4, 100: 4}
+	for offset, want := range tests {
+		if got := pageAt(starts, offset); got != want {
+			t.Errorf("pageAt(%d) = %d, want %d", offset, got, want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// 块列表每页的默认和最大条数
+const (
+	defaultChunkPageSize = 50
+	maxChunkPageSize     = 200
+)
+
+// DocumentChunks 是文档在向量库中的分块情况。ExpectedChunks 为按当前内容和分块规则应有的块数，
+// 与 Total 不一致或块的 Version 落后于文档时，说明索引未完成或残留了旧块
+type DocumentChunks struct {
+	DocumentID     uint          `json:"document_id"`
+	Version        int           `json:"version"`
+	Status         string        `json:"status"`
+	ChunkCount     int           `json:"chunk_count"` // 最近一次索引记录的块数
+	ExpectedChunks int           `json:"expected_chunks"`
+	Total          int           `json:"total"`
+	Offset         int           `json:"offset"`
+	Limit          int           `json:"limit"`
+	Items          []StoredChunk `json:"items"`
+}
+
+// Chunks 返回用户可访问文档在 Chroma 中的块，按 chunk_index 排序分页
+func (s *DocumentService) Chunks(ctx context.Context, userID, docID uint, offset, limit int) (*DocumentChunks, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	if !s.ragService.IsEnabled() {
+		return nil, ErrSearchUnavailable
+	}
+	doc, err := s.documentRepo.GetAccessible(docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultChunkPageSize
+	}
+	limit = min(limit, maxChunkPageSize)
+	offset = max(offset, 0)
+
+	total, items, err := s.ragService.ListDocumentChunks(ctx, doc, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentChunks{
+		DocumentID:     doc.ID,
+		Version:        doc.Version,
+		Status:         doc.Status,
+		ChunkCount:     doc.ChunkCount,
+		ExpectedChunks: s.ragService.ExpectedChunkCount(doc),
+		Total:          total,
+		Offset:         offset,
+		Limit:          limit,
+		Items:          items,
+	}, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"medical-qa-assistant/internal/fingerprint"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 完全重复文档的处理策略
+const (
+	DuplicatePolicyReject = "reject" // 拒绝创建
+	DuplicatePolicyWarn   = "warn"   // 允许创建，但在文档上标记重复来源
+)
+
+// 近似重复的默认相似度阈值，参与近似比较的最短内容（过短的文本 SimHash 不稳定），
+// 以及重复报告中最多列出的近似重复对数
+const (
+	defaultNearDuplicateThreshold = 0.9
+	minNearDuplicateRunes         = 100
+	maxNearDuplicatePairs         = 500
+)
+
+// DuplicateOptions 是创建文档时的重复检测配置
+type DuplicateOptions struct {
+	Policy string // reject | warn，其他值按 reject 处理
+	// SimHash 相似度达到该值视为近似重复，0 使用默认值。
+	// 候选文档按 SimHash 分段查找，低于 fingerprint.MinBandedSimilarity 的阈值可能漏掉部分近似重复
+	NearThreshold float64
+}
+
+// ErrDuplicateDocument 表示同一范围内已有内容完全相同的文档
+var ErrDuplicateDocument = errors.New("duplicate document")
+
+// DuplicateDocumentError 携带已存在的重复文档
+type DuplicateDocumentError struct {
+	ExistingID    uint
+	ExistingTitle string
+}
+
+func (e *DuplicateDocumentError) Error() string {
+	return fmt.Sprintf("document with identical content already exists: %d (%s)", e.ExistingID, e.ExistingTitle)
+}
+
+func (e *DuplicateDocumentError) Is(target error) bool {
+	return target == ErrDuplicateDocument
+}
+
+func (s *DocumentService) nearDuplicateThreshold() float64 {
+	if s.duplicates.NearThreshold > 0 {
+		return s.duplicates.NearThreshold
+	}
+	return defaultNearDuplicateThreshold
+}
+
+// checkDuplicates 计算文档内容指纹，并在同一范围（同一用户的私有文档或共享知识库）内查找重复：
+// 完全相同时按策略拒绝或标记，相似度超过阈值时标记为近似重复
+func (s *DocumentService) checkDuplicates(doc *models.Document) error {
+	setFingerprint(doc)
+	doc.DuplicateOfID = nil
+	doc.DuplicateScore = 0
+	visibility := documentVisibility(doc)
+
+	existing, err := s.documentRepo.FindByContentHash(doc.UserID, visibility, doc.ContentHash, doc.ID)
+	if err == nil {
+		if s.duplicates.Policy != DuplicatePolicyWarn {
+			return &DuplicateDocumentError{ExistingID: existing.ID, ExistingTitle: existing.Title}
+		}
+		doc.DuplicateOfID = &existing.ID
+		doc.DuplicateScore = 1
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if utf8.RuneCountInString(fingerprint.Normalize(doc.Content)) < minNearDuplicateRunes {
+		return nil
+	}
+	candidates, err := s.documentRepo.ListNearDuplicateCandidates(doc.UserID, visibility, fingerprint.Bands(doc.SimHash), doc.ID)
+	if err != nil {
+		return err
+	}
+	threshold := s.nearDuplicateThreshold()
+	for _, candidate := range candidates {
+		similarity := fingerprint.Similarity(doc.SimHash, candidate.SimHash)
+		if similarity >= threshold && similarity > doc.DuplicateScore {
+			id := candidate.ID
+			doc.DuplicateOfID = &id
+			doc.DuplicateScore = similarity
+		}
+	}
+	return nil
+}
+
+// setFingerprint 计算文档内容的精确哈希、SimHash 及其分段
+func setFingerprint(doc *models.Document) {
+	doc.ContentHash = fingerprint.ContentHash(doc.Content)
+	doc.SimHash = fingerprint.SimHash(doc.Content)
+	bands := fingerprint.Bands(doc.SimHash)
+	doc.SimBand0, doc.SimBand1, doc.SimBand2, doc.SimBand3 = bands[0], bands[1], bands[2], bands[3]
+	doc.SimBand4, doc.SimBand5, doc.SimBand6, doc.SimBand7 = bands[4], bands[5], bands[6], bands[7]
+}
+
+// DuplicateDocument 是重复报告中的一篇文档
+type DuplicateDocument struct {
+	ID         uint      `json:"id"`
+	Title      string    `json:"title"`
+	Visibility string    `json:"visibility"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DuplicateGroup 是内容完全相同的一组文档
+type DuplicateGroup struct {
+	ContentHash string              `json:"content_hash"`
+	Documents   []DuplicateDocument `json:"documents"`
+}
+
+// NearDuplicatePair 是一对近似重复的文档
+type NearDuplicatePair struct {
+	Documents  [2]DuplicateDocument `json:"documents"`
+	Similarity float64              `json:"similarity"`
+}
+
+// DuplicatesReport 列出用户自己文档中的完全重复和近似重复
+type DuplicatesReport struct {
+	GeneratedAt      time.Time           `json:"generated_at"`
+	Threshold        float64             `json:"threshold"`
+	ExactGroups      []DuplicateGroup    `json:"exact_groups"`
+	NearDuplicates   []NearDuplicatePair `json:"near_duplicates"`
+	Truncated        bool                `json:"truncated"` // 近似重复超过 maxNearDuplicatePairs 对，只列出了一部分
+	DocumentsScanned int                 `json:"documents_scanned"`
+}
+
+// DuplicatesReport 生成用户文档的重复报告。近似重复只在 SimHash 至少有一段相同的文档之间比较，
+// 最多列出 maxNearDuplicatePairs 对；完全相同的文档只出现在 exact_groups 中
+func (s *DocumentService) DuplicatesReport(userID uint) (*DuplicatesReport, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	fps, err := s.documentRepo.ListFingerprintsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DuplicatesReport{
+		GeneratedAt:      time.Now(),
+		Threshold:        s.nearDuplicateThreshold(),
+		ExactGroups:      []DuplicateGroup{},
+		NearDuplicates:   []NearDuplicatePair{},
+		DocumentsScanned: len(fps),
+	}
+
+	groups := make(map[string]int)
+	for _, fp := range fps {
+		ref := duplicateDocument(fp)
+		if i, ok := groups[fp.ContentHash]; ok {
+			report.ExactGroups[i].Documents = append(report.ExactGroups[i].Documents, ref)
+			continue
+		}
+		groups[fp.ContentHash] = len(report.ExactGroups)
+		report.ExactGroups = append(report.ExactGroups, DuplicateGroup{
+			ContentHash: fp.ContentHash,
+			Documents:   []DuplicateDocument{ref},
+		})
+	}
+	exact := report.ExactGroups[:0]
+	for _, group := range report.ExactGroups {
+		if len(group.Documents) > 1 {
+			exact = append(exact, group)
+		}
+	}
+	report.ExactGroups = exact
+
+	report.NearDuplicates, report.Truncated = nearDuplicatePairs(fps, report.Threshold, maxNearDuplicatePairs)
+	return report, nil
+}
+
+// nearDuplicatePairs 找出相似度达到阈值的文档对，超过 limit 对时截断并返回 true。
+// 文档按 SimHash 分段分桶，只比较同桶的文档，每对只比较一次
+func nearDuplicatePairs(fps []repositories.DocumentFingerprint, threshold float64, limit int) ([]NearDuplicatePair, bool) {
+	pairs := []NearDuplicatePair{}
+	var buckets [fingerprint.BandCount]map[uint8][]int
+	for band := range buckets {
+		buckets[band] = make(map[uint8][]int)
+	}
+	for i, fp := range fps {
+		if fp.SimHash == 0 {
+			continue
+		}
+		// 与之前已入桶的文档比较，seen 去掉多个分段都相同的重复候选
+		seen := make(map[int]bool)
+		for band, value := range fingerprint.Bands(fp.SimHash) {
+			for _, j := range buckets[band][value] {
+				if seen[j] {
+					continue
+				}
+				seen[j] = true
+				if fps[j].ContentHash == fp.ContentHash {
+					continue
+				}
+				similarity := fingerprint.Similarity(fps[j].SimHash, fp.SimHash)
+				if similarity < threshold {
+					continue
+				}
+				if len(pairs) == limit {
+					return pairs, true
+				}
+				pairs = append(pairs, NearDuplicatePair{
+					Documents:  [2]DuplicateDocument{duplicateDocument(fps[j]), duplicateDocument(fp)},
+					Similarity: similarity,
+				})
+			}
+			buckets[band][value] = append(buckets[band][value], i)
+		}
+	}
+	return pairs, false
+}
+
+func duplicateDocument(fp repositories.DocumentFingerprint) DuplicateDocument {
+	visibility := fp.Visibility
+	if visibility == "" {
+		visibility = models.DocumentVisibilityPrivate
+	}
+	return DuplicateDocument{
+		ID:         fp.ID,
+		Title:      fp.Title,
+		Visibility: visibility,
+		CreatedAt:  fp.CreatedAt,
+	}
+}
+
+// BackfillFingerprints 为早于重复检测功能创建的文档补算内容指纹，只计算不标记重复
+func (s *DocumentService) BackfillFingerprints() {
+	updated := 0
+	err := s.documentRepo.FindWithoutFingerprint(100, func(docs []models.Document) error {
+		for i := range docs {
+			doc := &docs[i]
+			setFingerprint(doc)
+			if err := s.documentRepo.UpdateFingerprint(doc); err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	})
+	if err != nil {
+		logger.L.Error("failed to backfill document fingerprints",
+			zap.Error(err),
+			zap.Int("updated", updated),
+		)
+		return
+	}
+	if updated > 0 {
+		logger.L.Info("document fingerprints backfilled", zap.Int("count", updated))
+	}
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+)
+
+func TestNearDuplicatePairs(t *testing.T) {
+	const base uint64 = 0x0123456789abcdef
+	fps := []repositories.DocumentFingerprint{
+		{ID: 1, ContentHash: "a", SimHash: base},
+		{ID: 2, ContentHash: "b", SimHash: base ^ 0x0101010101010100}, // 7 位不同，只有最低段相同
+		{ID: 3, ContentHash: "a", SimHash: base},                      // 与 1 完全相同，只出现在 exact_groups
+		{ID: 4, ContentHash: "c", SimHash: ^base},                     // 完全不同
+		{ID: 5, ContentHash: "d", SimHash: 0},                         // 无指纹
+		{ID: 6, ContentHash: "e", SimHash: base ^ 1},
+	}
+	pairs, truncated := nearDuplicatePairs(fps, 0.89, 10)
+	if truncated {
+		t.Error("unexpected truncation")
+	}
+	got := map[[2]uint]bool{}
+	for _, p := range pairs {
+		got[[2]uint{p.Documents[0].ID, p.Documents[1].ID}] = true
+		if p.Documents[0].Visibility != models.DocumentVisibilityPrivate {
+			t.Errorf("empty visibility should default to private, got %q", p.Documents[0].Visibility)
+		}
+	}
+	want := [][2]uint{{1, 2}, {2, 3}, {1, 6}, {3, 6}}
+	if len(got) != len(want) || len(pairs) != len(want) {
+		t.Fatalf("pairs = %v, want %v", got, want)
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing pair %v", w)
+		}
+	}
+
+	// 阈值更高时距离为 7 的文档对被排除
+	if pairs, _ := nearDuplicatePairs(fps, 0.95, 10); len(pairs) != 2 {
+		t.Errorf("threshold 0.95: %d pairs, want 2", len(pairs))
+	}
+
+	pairs, truncated = nearDuplicatePairs(fps, 0.89, 3)
+	if !truncated || len(pairs) != 3 {
+		t.Errorf("limit 3: %d pairs, truncated %v", len(pairs), truncated)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// 每个订阅者的事件缓冲大小，缓冲满时丢弃新事件以免阻塞索引 worker
+const documentEventBuffer = 32
+
+// DocumentEvent 描述一次文档索引状态变化
+type DocumentEvent struct {
+	DocumentID uint      `json:"document_id"`
+	Title      string    `json:"title,omitempty"`
+	Status     string    `json:"status"`
+	ChunkCount int       `json:"chunk_count,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// DocumentEventHub 在进程内按用户分发文档事件，供 SSE 连接订阅
+type DocumentEventHub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan DocumentEvent]struct{}
+}
+
+func NewDocumentEventHub() *DocumentEventHub {
+	return &DocumentEventHub{
+		subscribers: make(map[uint]map[chan DocumentEvent]struct{}),
+	}
+}
+
+// Subscribe 注册一个用户的事件通道，返回的函数用于取消订阅
+func (h *DocumentEventHub) Subscribe(userID uint) (<-chan DocumentEvent, func()) {
+	ch := make(chan DocumentEvent, documentEventBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan DocumentEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[userID], ch)
+			if len(h.subscribers[userID]) == 0 {
+				delete(h.subscribers, userID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Publish 将事件推送给该用户的所有订阅者；没有订阅者时直接丢弃
+func (h *DocumentEventHub) Publish(userID uint, event DocumentEvent) {
+	if h == nil {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
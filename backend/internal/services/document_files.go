@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"medical-qa-assistant/internal/blobstore"
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// ErrFileNotStored 表示文档没有保存原始文件（通过 JSON 创建，或未启用文件存储）
+var ErrFileNotStored = errors.New("original file not stored for this document")
+
+// UploadedFile 是上传的原始文件，与提取出的文本一起保存
+type UploadedFile struct {
+	Name     string
+	MIMEType string
+	Data     []byte
+}
+
+// DocumentFile 是打开的原始文件，调用方负责关闭 Body
+type DocumentFile struct {
+	Name     string
+	MIMEType string
+	Size     int64
+	SHA256   string
+	Body     io.ReadCloser
+}
+
+// storeOriginal 将原始文件写入存储并在文档上记录位置和校验和
+func (s *DocumentService) storeOriginal(ctx context.Context, doc *models.Document, file *UploadedFile) error {
+	key, err := newBlobKey(doc.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.blobs.Put(ctx, key, file.Data, file.MIMEType); err != nil {
+		return fmt.Errorf("failed to store original file: %w", err)
+	}
+	sum := sha256.Sum256(file.Data)
+	doc.FileKey = key
+	doc.FileName = truncateRunes(file.Name, maxDocumentFileNameLen)
+	doc.FileMIMEType = file.MIMEType
+	doc.FileSize = int64(len(file.Data))
+	doc.FileSHA256 = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// deleteOriginal 尽力删除原始文件，失败只记录日志，残留对象不影响文档数据
+func (s *DocumentService) deleteOriginal(key string) {
+	if s.blobs == nil || key == "" {
+		return
+	}
+	if err := s.blobs.Delete(context.Background(), key); err != nil {
+		logger.L.Warn("failed to delete original file",
+			zap.Error(err),
+			zap.String("file_key", key),
+		)
+	}
+}
+
+// newBlobKey 生成随机的对象键，避免使用用户提供的文件名
+func newBlobKey(userID uint) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("documents/%d/%s", userID, hex.EncodeToString(b)), nil
+}
+
+// OpenFile 打开文档的原始文件，只有上传者可以下载
+func (s *DocumentService) OpenFile(ctx context.Context, userID, docID uint) (*DocumentFile, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	doc, err := s.documentRepo.GetByIDAndUser(docID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if doc.FileKey == "" || s.blobs == nil {
+		return nil, ErrFileNotStored
+	}
+	body, err := s.blobs.Get(ctx, doc.FileKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		logger.L.Error("original file missing from blob store",
+			zap.Uint("document_id", doc.ID),
+			zap.String("file_key", doc.FileKey),
+		)
+		return nil, ErrFileNotStored
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &DocumentFile{
+		Name:     doc.FileName,
+		MIMEType: doc.FileMIMEType,
+		Size:     doc.FileSize,
+		SHA256:   doc.FileSHA256,
+		Body:     body,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"medical-qa-assistant/internal/repositories"
+)
+
+// 文档列表每页的默认和最大条数
+const (
+	defaultDocumentPageSize = 20
+	maxDocumentPageSize     = 100
+)
+
+// ErrInvalidCursor 表示分页游标无法解析或与当前排序方式不匹配
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// DocumentListOptions 是列表的分页和排序参数。Cursor 为上一页返回的 next_cursor，
+// 提供时按游标分页并忽略 Offset
+type DocumentListOptions struct {
+	Sort   string // created_at（默认）、updated_at 或 title
+	Order  string // desc（默认）或 asc
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+// DocumentPage 是一页文档摘要。Total 为符合过滤条件的文档总数，不受分页影响
+type DocumentPage struct {
+	Items      []repositories.DocumentSummary `json:"items"`
+	Total      int64                          `json:"total"`
+	Limit      int                            `json:"limit"`
+	Offset     int                            `json:"offset"`
+	HasMore    bool                           `json:"has_more"`
+	NextCursor string                         `json:"next_cursor,omitempty"`
+}
+
+// List 分页返回用户的个人文档以及共享知识库中符合过滤条件的文档摘要，不包含全文
+func (s *DocumentService) List(userID uint, filter repositories.DocumentFilter, opts DocumentListOptions) (*DocumentPage, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+
+	page := repositories.DocumentPageQuery{
+		Sort:   opts.Sort,
+		Desc:   opts.Order != "asc",
+		Limit:  opts.Limit,
+		Offset: opts.Offset,
+	}
+	switch page.Sort {
+	case "":
+		page.Sort = repositories.DocumentSortCreatedAt
+	case repositories.DocumentSortCreatedAt, repositories.DocumentSortUpdatedAt, repositories.DocumentSortTitle:
+	default:
+		return nil, errors.New("sort must be one of created_at, updated_at, title")
+	}
+	if opts.Order != "" && opts.Order != "asc" && opts.Order != "desc" {
+		return nil, errors.New("order must be asc or desc")
+	}
+	if page.Limit <= 0 {
+		page.Limit = defaultDocumentPageSize
+	}
+	page.Limit = min(page.Limit, maxDocumentPageSize)
+	if page.Offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	if opts.Cursor != "" {
+		cursor, err := decodeDocumentCursor(opts.Cursor)
+		if err != nil || cursor.Sort != page.Sort {
+			return nil, ErrInvalidCursor
+		}
+		page.Cursor = cursor
+		page.Offset = 0
+	}
+
+	total, err := s.documentRepo.CountAccessible(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	// 多取一条判断是否还有下一页
+	page.Limit++
+	items, err := s.documentRepo.ListSummaries(userID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+	page.Limit--
+
+	result := &DocumentPage{
+		Items:  items,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+	if len(items) > page.Limit {
+		result.Items = items[:page.Limit]
+		result.HasMore = true
+		result.NextCursor = encodeDocumentCursor(page.Sort, &result.Items[page.Limit-1])
+	}
+	if result.Items == nil {
+		result.Items = []repositories.DocumentSummary{}
+	}
+	return result, nil
+}
+
+// encodeDocumentCursor 将最后一条记录的排序键编码为不透明的游标
+func encodeDocumentCursor(sort string, last *repositories.DocumentSummary) string {
+	cursor := repositories.DocumentCursor{Sort: sort, ID: last.ID}
+	switch sort {
+	case repositories.DocumentSortTitle:
+		cursor.Title = last.Title
+	case repositories.DocumentSortUpdatedAt:
+		cursor.Time = last.UpdatedAt
+	default:
+		cursor.Time = last.CreatedAt
+	}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeDocumentCursor(raw string) (*repositories.DocumentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor repositories.DocumentCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
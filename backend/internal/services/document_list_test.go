@@ -0,0 +1,184 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+)
+
+func TestDocumentCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 8, 30, 0, 123000000, time.UTC)
+	updated := created.Add(time.Hour)
+	last := &repositories.DocumentSummary{ID: 42, Title: "糖尿病指南", CreatedAt: created, UpdatedAt: updated}
+	tests := []struct {
+		sort string
+		want repositories.DocumentCursor
+	}{
+		{repositories.DocumentSortCreatedAt, repositories.DocumentCursor{Sort: "created_at", Time: created, ID: 42}},
+		{repositories.DocumentSortUpdatedAt, repositories.DocumentCursor{Sort: "updated_at", Time: updated, ID: 42}},
+		{repositories.DocumentSortTitle, repositories.DocumentCursor{Sort: "title", Title: "糖尿病指南", ID: 42}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			raw := encodeDocumentCursor(tt.sort, last)
+			got, err := decodeDocumentCursor(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Time.Equal(tt.want.Time) || got.Sort != tt.want.Sort || got.Title != tt.want.Title || got.ID != tt.want.ID {
+				t.Errorf("cursor = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDocumentCursorInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for name, raw := range map[string]string{
+		"not base64":  "%%%",
+		"not json":    encode("created_at,42"),
+		"missing id":  encode(`{"s":"title","v":"a"}`),
+		"padded form": base64.URLEncoding.EncodeToString([]byte(`{"s":"title","id":1}`)) + "=",
+	} {
+		if _, err := decodeDocumentCursor(raw); err == nil {
+			t.Errorf("%s: decodeDocumentCursor(%q) succeeded", name, raw)
+		}
+	}
+}
+
+// 排序字段会拼进 SQL，只接受白名单中的列；无效参数在查询数据库之前被拒绝
+func TestListRejectsInvalidOptions(t *testing.T) {
+	s := &DocumentService{}
+	titleCursor := encodeDocumentCursor(repositories.DocumentSortTitle, &repositories.DocumentSummary{ID: 1, Title: "a"})
+	tests := map[string]DocumentListOptions{
+		"unknown sort":           {Sort: "content"},
+		"sql in sort":            {Sort: "id; DROP TABLE documents"},
+		"unknown order":          {Order: "sideways"},
+		"negative offset":        {Offset: -1},
+		"malformed cursor":       {Cursor: "not-a-cursor"},
+		"cursor of another sort": {Sort: repositories.DocumentSortCreatedAt, Cursor: titleCursor},
+	}
+	for name, opts := range tests {
+		if _, err := s.List(1, repositories.DocumentFilter{}, opts); err == nil {
+			t.Errorf("%s: List succeeded", name)
+		}
+	}
+	if _, err := s.List(1, repositories.DocumentFilter{}, DocumentListOptions{Cursor: titleCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("title cursor with default sort = %v, want ErrInvalidCursor", err)
+	}
+}
+
+// 按游标逐页读取时，排序键相同的文档按 ID 区分，不重复也不遗漏
+func TestListCursorPagingTieBreak(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestDocumentService(db)
+
+	same := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	var docs []*models.Document
+	for i := 0; i < 7; i++ {
+		doc := &models.Document{UserID: 1, Title: fmt.Sprintf("指南 %d", i%2), Content: fmt.Sprintf("内容 %d", i),
+			Status: models.DocumentStatusReady, Version: 1, CreatedAt: same, UpdatedAt: same}
+		if i == 6 {
+			doc.CreatedAt = same.Add(time.Hour)
+		}
+		if err := db.Create(doc).Error; err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+	// 他人的私有文档不在列表中
+	if err := db.Create(&models.Document{UserID: 2, Title: "其他", Content: "x", Status: models.DocumentStatusReady, Version: 1}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// expected 按排序键、再按 ID 排列
+	expected := func(field, order string) []uint {
+		sorted := append([]*models.Document(nil), docs...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, b := sorted[i], sorted[j]
+			if order == "desc" {
+				a, b = b, a
+			}
+			switch field {
+			case repositories.DocumentSortTitle:
+				if a.Title != b.Title {
+					return a.Title < b.Title
+				}
+			case repositories.DocumentSortCreatedAt:
+				if !a.CreatedAt.Equal(b.CreatedAt) {
+					return a.CreatedAt.Before(b.CreatedAt)
+				}
+			}
+			return a.ID < b.ID
+		})
+		ids := make([]uint, len(sorted))
+		for i, doc := range sorted {
+			ids[i] = doc.ID
+		}
+		return ids
+	}
+
+	for _, field := range []string{repositories.DocumentSortCreatedAt, repositories.DocumentSortUpdatedAt, repositories.DocumentSortTitle} {
+		for _, order := range []string{"asc", "desc"} {
+			t.Run(field+" "+order, func(t *testing.T) {
+				var byCursor, byOffset []uint
+				opts := DocumentListOptions{Sort: field, Order: order, Limit: 2}
+				for pages := 0; ; pages++ {
+					if pages > len(docs) {
+						t.Fatal("cursor paging did not terminate")
+					}
+					page, err := s.List(1, repositories.DocumentFilter{}, opts)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if page.Total != int64(len(docs)) {
+						t.Errorf("total = %d, want %d", page.Total, len(docs))
+					}
+					for _, item := range page.Items {
+						byCursor = append(byCursor, item.ID)
+					}
+					if page.HasMore != (page.NextCursor != "") {
+						t.Errorf("has_more = %v with next_cursor %q", page.HasMore, page.NextCursor)
+					}
+					if !page.HasMore {
+						break
+					}
+					opts.Cursor = page.NextCursor
+				}
+				for offset := 0; offset < len(docs); offset += 2 {
+					page, err := s.List(1, repositories.DocumentFilter{}, DocumentListOptions{Sort: field, Order: order, Limit: 2, Offset: offset})
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, item := range page.Items {
+						byOffset = append(byOffset, item.ID)
+					}
+				}
+
+				if len(byCursor) != len(docs) {
+					t.Fatalf("cursor pages returned %v, want %d documents", byCursor, len(docs))
+				}
+				seen := map[uint]bool{}
+				for _, id := range byCursor {
+					if seen[id] {
+						t.Errorf("document %d returned twice", id)
+					}
+					seen[id] = true
+				}
+				if want := expected(field, order); !reflect.DeepEqual(byCursor, want) {
+					t.Errorf("cursor order %v, want %v", byCursor, want)
+				}
+				if !reflect.DeepEqual(byCursor, byOffset) {
+					t.Errorf("cursor order %v differs from offset order %v", byCursor, byOffset)
+				}
+			})
+		}
+	}
+}
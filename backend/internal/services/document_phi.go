@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/phi"
+
+	"go.uber.org/zap"
+)
+
+// deidentify 在存储和嵌入前去除标题和内容中的患者身份信息。两者共用一个会话，同一个人的化名一致。
+// 未启用时返回 nil 报告；content 为 nil 表示只处理标题
+func (s *DocumentService) deidentify(title string, content *string) (string, *models.DocumentRedaction) {
+	if !s.redactor.Enabled() {
+		return title, nil
+	}
+
+	session := s.redactor.NewSession()
+	redaction := &models.DocumentRedaction{
+		Mode:     s.redactor.Mode(),
+		Counts:   map[string]int{},
+		Findings: []models.RedactionFinding{},
+	}
+	record := func(field string, report *phi.Report) {
+		for kind, n := range report.Counts {
+			redaction.Counts[kind] += n
+		}
+		for _, f := range report.Findings {
+			redaction.Findings = append(redaction.Findings, models.RedactionFinding{
+				Field:       field,
+				Kind:        f.Kind,
+				Start:       f.Start,
+				End:         f.End,
+				Replacement: f.Replacement,
+			})
+		}
+	}
+
+	// 先处理内容：姓名多在内容中借助上下文识别，之后标题中的同名也能被替换
+	if content != nil {
+		redacted, report := session.Redact(*content)
+		*content = redacted
+		record("content", report)
+	}
+	title, report := session.Redact(title)
+	record("title", report)
+
+	redaction.Total = len(redaction.Findings)
+	if redaction.Total > 0 {
+		logger.L.Info("PHI redacted from document",
+			zap.String("mode", redaction.Mode),
+			zap.Int("total", redaction.Total),
+			zap.Any("counts", redaction.Counts),
+		)
+	}
+	return title, redaction
+}
+
+// ListRedactions 返回用户可访问文档的身份信息去除报告，最新的在前
+func (s *DocumentService) ListRedactions(userID, docID uint) ([]models.DocumentRedaction, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	if _, err := s.documentRepo.GetAccessible(docID, userID); err != nil {
+		return nil, err
+	}
+	return s.redactions.ListByDocument(docID)
+}
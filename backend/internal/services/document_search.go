@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+
+	"medical-qa-assistant/internal/repositories"
+)
+
+// 检索词数量和每页结果数的上限
+const (
+	maxSearchTerms        = 10
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 50
+)
+
+// ErrEmptyQuery 表示检索词为空
+var ErrEmptyQuery = errors.New("search query is required")
+
+// DocumentSearchHit 是一条检索结果，Snippet 为 HTML 转义后的内容片段，命中的词用 <mark> 标出
+type DocumentSearchHit struct {
+	repositories.DocumentSearchHit
+	Snippet string `json:"snippet"`
+}
+
+// DocumentSearchResult 是一页检索结果。Mode 为实际使用的检索方式（fulltext 或 like）
+type DocumentSearchResult struct {
+	Query  string              `json:"query"`
+	Mode   string              `json:"mode"`
+	Total  int64               `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+	Items  []DocumentSearchHit `json:"items"`
+}
+
+// Search 按内容和标题检索用户可访问的文档，多个词之间为“且”关系，结果按相关度排序
+func (s *DocumentService) Search(userID uint, query string, filter repositories.DocumentFilter, limit, offset int) (*DocumentSearchResult, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 {
+		limit = defaultSearchPageSize
+	}
+	limit = min(limit, maxSearchPageSize)
+	offset = max(offset, 0)
+
+	hits, total, err := s.documentRepo.Search(userID, filter, terms, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DocumentSearchResult{
+		Query:  query,
+		Mode:   s.documentRepo.SearchMode(terms),
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+		Items:  make([]DocumentSearchHit, 0, len(hits)),
+	}
+	for _, hit := range hits {
+		snippet := highlight(hit.Excerpt, terms)
+		if hit.ExcerptStart > 1 {
+			snippet = "…" + snippet
+		}
+		if hit.ExcerptStart+len([]rune(hit.Excerpt)) <= hit.ContentChars {
+			snippet += "…"
+		}
+		result.Items = append(result.Items, DocumentSearchHit{DocumentSearchHit: hit, Snippet: snippet})
+	}
+	return result, nil
+}
+
+// searchTerms 按空白拆分检索词并去重，忽略大小写
+func searchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(strings.ReplaceAll(query, `"`, " ")) {
+		key := strings.ToLower(term)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// TextSpan 是文本中 [Start, End) 的一段，偏移按字符（rune）计算
+type TextSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// matchSpans 返回文本中所有检索词出现的位置（不区分大小写），重叠或相邻的位置合并为一段
+func matchSpans(text string, terms []string) []TextSpan {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		t := []rune(strings.ToLower(term))
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == string(t) {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+
+	var spans []TextSpan
+	for i := 0; i < len(runes); i++ {
+		if !marked[i] {
+			continue
+		}
+		start := i
+		for i < len(runes) && marked[i] {
+			i++
+		}
+		spans = append(spans, TextSpan{Start: start, End: i})
+	}
+	return spans
+}
+
+// highlight 对文本做 HTML 转义，并用 <mark> 标出所有检索词（不区分大小写）
+func highlight(text string, terms []string) string {
+	return markSpans(text, matchSpans(text, terms))
+}
+
+// markSpans 对文本做 HTML 转义，并用 <mark> 标出 spans 中的各段
+func markSpans(text string, spans []TextSpan) string {
+	runes := []rune(text)
+	var sb strings.Builder
+	pos := 0
+	for _, span := range spans {
+		sb.WriteString(html.EscapeString(string(runes[pos:span.Start])))
+		sb.WriteString("<mark>" + html.EscapeString(string(runes[span.Start:span.End])) + "</mark>")
+		pos = span.End
+	}
+	sb.WriteString(html.EscapeString(string(runes[pos:])))
+	return sb.String()
+}
@@ -3,30 +3,165 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"medical-qa-assistant/internal/blobstore"
 	"medical-qa-assistant/internal/logger"
 	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/phi"
 	"medical-qa-assistant/internal/repositories"
 
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // DocumentService 包含文档管理的业务逻辑
 type DocumentService struct {
 	documentRepo *repositories.DocumentRepository
+	versionRepo  *repositories.DocumentVersionRepository
+	redactions   *repositories.DocumentRedactionRepository
+	outboxRepo   *repositories.OutboxRepository
+	kbRepo       *repositories.KnowledgeBaseRepository
 	ragService   *RAGService
+	dispatcher   *OutboxDispatcher
+	events       *DocumentEventHub
+	duplicates   DuplicateOptions
+	blobs        blobstore.BlobStore // 原始文件存储，为 nil 时只保存提取的文本
+	redactor     *phi.Redactor       // 入库前去除患者身份信息
 }
 
-func NewDocumentService(documentRepo *repositories.DocumentRepository, ragService *RAGService) *DocumentService {
+func NewDocumentService(
+	documentRepo *repositories.DocumentRepository,
+	versionRepo *repositories.DocumentVersionRepository,
+	redactions *repositories.DocumentRedactionRepository,
+	outboxRepo *repositories.OutboxRepository,
+	kbRepo *repositories.KnowledgeBaseRepository,
+	ragService *RAGService,
+	dispatcher *OutboxDispatcher,
+	events *DocumentEventHub,
+	duplicates DuplicateOptions,
+	blobs blobstore.BlobStore,
+	redactor *phi.Redactor,
+) *DocumentService {
 	return &DocumentService{
 		documentRepo: documentRepo,
+		versionRepo:  versionRepo,
+		redactions:   redactions,
+		outboxRepo:   outboxRepo,
+		kbRepo:       kbRepo,
 		ragService:   ragService,
+		dispatcher:   dispatcher,
+		events:       events,
+		duplicates:   duplicates,
+		blobs:        blobs,
+		redactor:     redactor,
 	}
 }
 
+// ErrForbidden 表示当前用户无权执行该操作
+var ErrForbidden = errors.New("insufficient permissions")
+
+// ErrTitleTooLong 表示文档标题超过 maxDocumentTitleLen 个字符
+var ErrTitleTooLong = fmt.Errorf("title exceeds %d characters", maxDocumentTitleLen)
+
+// 标题和原始文件名的最大长度（字符数），与 documents 表的列宽一致
+const (
+	maxDocumentTitleLen    = 255
+	maxDocumentFileNameLen = 255
+)
+
+// 单篇文档允许的标签数量和单个标签的最大长度
+const (
+	maxDocumentTags   = 20
+	maxDocumentTagLen = 32
+)
+
+// DocumentMetadataInput 是创建文档时可填写的描述性元数据，会同步写入每个 Chroma 块
+type DocumentMetadataInput struct {
+	Tags            []string `json:"tags"`
+	Category        string   `json:"category" binding:"omitempty,oneof=guideline textbook drug_label paper note"`
+	PublicationYear int      `json:"publication_year" binding:"omitempty,min=1800,max=2100"`
+	Source          string   `json:"source" binding:"max=255"`
+}
+
+// DocumentProvenanceInput 是文档出处信息，发布日期格式为 YYYY-MM-DD
+type DocumentProvenanceInput struct {
+	Publisher   string   `json:"publisher" binding:"max=255"`
+	Authors     []string `json:"authors" binding:"max=50,dive,max=100"`
+	PublishedOn string   `json:"published_on" binding:"omitempty,datetime=2006-01-02"`
+	Edition     string   `json:"edition" binding:"max=100"`
+	OriginalURL string   `json:"original_url" binding:"omitempty,url,max=1024"`
+	License     string   `json:"license" binding:"max=100"`
+}
+
+// toModel 转换为模型中的出处字段
+func (in *DocumentProvenanceInput) toModel() (models.DocumentProvenance, error) {
+	provenance := models.DocumentProvenance{
+		Publisher:   strings.TrimSpace(in.Publisher),
+		Authors:     []string{},
+		Edition:     strings.TrimSpace(in.Edition),
+		OriginalURL: strings.TrimSpace(in.OriginalURL),
+		License:     strings.TrimSpace(in.License),
+	}
+	for _, author := range in.Authors {
+		if author = strings.TrimSpace(author); author != "" {
+			provenance.Authors = append(provenance.Authors, author)
+		}
+	}
+	if in.PublishedOn != "" {
+		publishedOn, err := time.Parse("2006-01-02", in.PublishedOn)
+		if err != nil {
+			return provenance, errors.New("invalid published_on, expected YYYY-MM-DD")
+		}
+		provenance.PublishedOn = &publishedOn
+	}
+	return provenance, nil
+}
+
 type CreateDocumentRequest struct {
-	Title   string `json:"title" binding:"required,min=1,max=255"`
-	Content string `json:"content" binding:"required"`
+	Title      string `json:"title" binding:"required,min=1,max=255"`
+	Content    string `json:"content" binding:"required"`
+	Visibility string `json:"visibility" binding:"omitempty,oneof=private shared"`
+
+	KnowledgeBaseID *uint `json:"knowledge_base_id"`
+
+	DocumentMetadataInput
+	Provenance DocumentProvenanceInput `json:"provenance"`
+
+	// 复审日期（YYYY-MM-DD）及取代本文档的新文档
+	ReviewBy       string `json:"review_by" binding:"omitempty,datetime=2006-01-02"`
+	SupersededByID *uint  `json:"superseded_by_id"`
+
+	// Encoding 为上传文件检测到的原始编码，由 Upload 设置
+	Encoding string `json:"-"`
+	// File 为上传的原始文件，启用文件存储时随文档保存
+	File *UploadedFile `json:"-"`
+}
+
+// UpdateDocumentMetadataRequest 修改文档元数据，未提供的字段保持不变
+type UpdateDocumentMetadataRequest struct {
+	// KnowledgeBaseID 将文档移入调用者自己的知识库，0 表示移出知识库
+	KnowledgeBaseID *uint `json:"knowledge_base_id"`
+
+	Tags            *[]string `json:"tags"`
+	Category        *string   `json:"category" binding:"omitempty,oneof=guideline textbook drug_label paper note"`
+	PublicationYear *int      `json:"publication_year" binding:"omitempty,min=0,max=2100"`
+	Source          *string   `json:"source" binding:"omitempty,max=255"`
+
+	// 空字符串清除复审日期，0 清除取代关系
+	ReviewBy       *string `json:"review_by" binding:"omitempty,datetime=2006-01-02"`
+	SupersededByID *uint   `json:"superseded_by_id"`
+}
+
+// UpdateDocumentRequest 修改文档标题、内容和元数据，未提供的字段保持不变
+type UpdateDocumentRequest struct {
+	Title   *string `json:"title" binding:"omitempty,max=255"`
+	Content *string `json:"content"`
+
+	UpdateDocumentMetadataRequest
 }
 
 type DocumentResponse struct {
@@ -38,80 +173,401 @@ type DocumentResponse struct {
 	UpdatedAt string `json:"updated_at"`
 }
 
-func (s *DocumentService) Create(userID uint, req *CreateDocumentRequest) (*models.Document, error) {
+// Create 创建文档并异步索引。共享文档进入全组织知识库，只有管理员可以创建。
+// 同一范围内已有内容完全相同的文档时按重复策略拒绝或标记，近似重复只做标记
+func (s *DocumentService) Create(userID uint, role string, req *CreateDocumentRequest) (*models.Document, error) {
 	if userID == 0 {
 		return nil, errors.New("invalid user")
 	}
 
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = models.DocumentVisibilityPrivate
+	}
+	if visibility == models.DocumentVisibilityShared && role != models.RoleAdmin {
+		return nil, ErrForbidden
+	}
+	if req.KnowledgeBaseID != nil {
+		if err := validateKnowledgeBaseOwnership(s.kbRepo, userID, []uint{*req.KnowledgeBaseID}); err != nil {
+			return nil, err
+		}
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	provenance, err := req.Provenance.toModel()
+	if err != nil {
+		return nil, err
+	}
+	reviewBy, err := parseReviewBy(req.ReviewBy)
+	if err != nil {
+		return nil, err
+	}
+	if req.SupersededByID != nil {
+		if err := s.validateSupersededBy(userID, 0, *req.SupersededByID); err != nil {
+			return nil, err
+		}
+	}
+
+	// 患者身份信息在存储、查重和嵌入之前去除
+	content := req.Content
+	title, redaction := s.deidentify(req.Title, &content)
+	if utf8.RuneCountInString(title) > maxDocumentTitleLen {
+		return nil, ErrTitleTooLong
+	}
+
 	doc := &models.Document{
-		UserID:  userID,
-		Title:   req.Title,
-		Content: req.Content,
-		Status:  "ready",
+		UserID:     userID,
+		Title:      title,
+		Content:    content,
+		Visibility: visibility,
+		Encoding:   req.Encoding,
+		Version:    1,
+		Status:     models.DocumentStatusPending,
+
+		KnowledgeBaseID: req.KnowledgeBaseID,
+		Tags:            tags,
+		Category:        req.Category,
+		PublicationYear: req.PublicationYear,
+		Source:          strings.TrimSpace(req.Source),
+		Provenance:      provenance,
+		ReviewBy:        reviewBy,
+		SupersededByID:  req.SupersededByID,
+	}
+	if redaction != nil {
+		doc.PHIRedactions = redaction.Total
+	}
+	if err := s.checkDuplicates(doc); err != nil {
+		return nil, err
+	}
+	// 原始文件先于事务写入存储，事务失败时再删除，避免文档记录指向不存在的文件。
+	// 原始文件无法去除身份信息，识别出身份信息时不保存
+	if req.File != nil && redaction != nil && redaction.Total > 0 {
+		redaction.OriginalDiscarded = true
+	} else if req.File != nil && s.blobs != nil {
+		if err := s.storeOriginal(context.Background(), doc, req.File); err != nil {
+			logger.L.Error("failed to store original file",
+				zap.Error(err),
+				zap.Uint("user_id", userID),
+				zap.String("filename", req.File.Name),
+			)
+			return nil, err
+		}
 	}
 
-	// 先保存文档到数据库，获得 ID
-	if err := s.documentRepo.Create(doc); err != nil {
+	// 文档、首个版本快照与 outbox 事件在同一事务中写入，向量化由分发器在提交后异步完成
+	err = s.documentRepo.Transaction(func(tx *gorm.DB) error {
+		if err := lockDocumentKnowledgeBase(s.kbRepo, tx, doc); err != nil {
+			return err
+		}
+		if err := s.documentRepo.WithTx(tx).Create(doc); err != nil {
+			return err
+		}
+		if err := s.versionRepo.WithTx(tx).Create(newDocumentVersion(doc, nil)); err != nil {
+			return err
+		}
+		if redaction != nil {
+			redaction.DocumentID = doc.ID
+			redaction.Version = doc.Version
+			if err := s.redactions.WithTx(tx).Create(redaction); err != nil {
+				return err
+			}
+		}
+		return s.outboxRepo.WithTx(tx).Create(newDocumentOutboxEvent(models.OutboxEventDocumentCreated, doc.ID, doc.UserID))
+	})
+	if err != nil {
 		logger.L.Error("failed to create document",
 			zap.Error(err),
 			zap.Uint("user_id", userID),
-			zap.String("title", req.Title),
+			zap.String("title", doc.Title),
 		)
+		s.deleteOriginal(doc.FileKey)
 		return nil, err
 	}
+	s.dispatcher.Notify()
 
-	if s.ragService != nil && s.ragService.IsEnabled() {
-		if err := s.ragService.IndexDocument(context.Background(), doc); err != nil {
-			logger.L.Error("failed to index document into RAG",
-				zap.Error(err),
-				zap.Uint("document_id", doc.ID),
-				zap.Uint("user_id", doc.UserID),
-			)
-			doc.Status = "indexing_failed"
-			s.documentRepo.Update(doc)
-		} else {
-			logger.L.Info("document indexed into RAG",
-				zap.Uint("document_id", doc.ID),
-				zap.Uint("user_id", doc.UserID),
-			)
+	s.events.Publish(userID, DocumentEvent{
+		DocumentID: doc.ID,
+		Title:      doc.Title,
+		Status:     doc.Status,
+		Error:      doc.ErrorMessage,
+	})
+
+	return doc, nil
+}
+
+// UpdateMetadata 修改文档的标签、类别等元数据。向量无需重建，
+// 通过 outbox 事件只更新 Chroma 中各块的元数据
+func (s *DocumentService) UpdateMetadata(userID uint, role string, docID uint, req *UpdateDocumentMetadataRequest) (*models.Document, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+
+	doc, err := s.manageableDocument(s.documentRepo, userID, role, docID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyMetadataUpdate(userID, doc, req); err != nil {
+		return nil, err
+	}
+
+	err = s.documentRepo.Transaction(func(tx *gorm.DB) error {
+		if err := lockDocumentKnowledgeBase(s.kbRepo, tx, doc); err != nil {
+			return err
 		}
+		if err := s.documentRepo.WithTx(tx).UpdateMetadata(doc); err != nil {
+			return err
+		}
+		return s.outboxRepo.WithTx(tx).Create(newDocumentOutboxEvent(models.OutboxEventDocumentMetadataUpdated, doc.ID, doc.UserID))
+	})
+	if err != nil {
+		logger.L.Error("failed to update document metadata",
+			zap.Error(err),
+			zap.Uint("document_id", docID),
+			zap.Uint("user_id", userID),
+		)
+		return nil, err
 	}
+	s.dispatcher.Notify()
 
 	return doc, nil
 }
 
-func (s *DocumentService) List(userID uint) ([]models.Document, error) {
+// applyMetadataUpdate 将请求中提供的元数据字段写入 doc。知识库必须属于文档所有者：
+// 管理员修改他人的共享文档时，不能把文档移入管理员自己的知识库
+func (s *DocumentService) applyMetadataUpdate(userID uint, doc *models.Document, req *UpdateDocumentMetadataRequest) error {
+	if req.KnowledgeBaseID != nil {
+		if *req.KnowledgeBaseID == 0 {
+			doc.KnowledgeBaseID = nil
+		} else {
+			if err := validateKnowledgeBaseOwnership(s.kbRepo, doc.UserID, []uint{*req.KnowledgeBaseID}); err != nil {
+				return err
+			}
+			doc.KnowledgeBaseID = req.KnowledgeBaseID
+		}
+	}
+	if req.Tags != nil {
+		tags, err := normalizeTags(*req.Tags)
+		if err != nil {
+			return err
+		}
+		doc.Tags = tags
+	}
+	if req.Category != nil {
+		doc.Category = *req.Category
+	}
+	if req.PublicationYear != nil {
+		doc.PublicationYear = *req.PublicationYear
+	}
+	if req.Source != nil {
+		doc.Source = strings.TrimSpace(*req.Source)
+	}
+	if req.ReviewBy != nil {
+		reviewBy, err := parseReviewBy(*req.ReviewBy)
+		if err != nil {
+			return err
+		}
+		doc.ReviewBy = reviewBy
+	}
+	if req.SupersededByID != nil {
+		if *req.SupersededByID == 0 {
+			doc.SupersededByID = nil
+		} else {
+			if err := s.validateSupersededBy(userID, doc.ID, *req.SupersededByID); err != nil {
+				return err
+			}
+			doc.SupersededByID = req.SupersededByID
+		}
+	}
+	return nil
+}
+
+// Update 修改文档的标题、内容和元数据。只有内容变化时才生成新版本并重新分块和嵌入（由索引任务覆盖旧块并删除多余的块），
+// 否则只在原地更新 Chroma 中各块的元数据
+func (s *DocumentService) Update(userID uint, role string, docID uint, req *UpdateDocumentRequest) (*models.Document, error) {
+	return s.update(userID, role, docID, req, nil)
+}
+
+// update 执行更新，restoredFrom 非空表示内容来自恢复的旧版本
+func (s *DocumentService) update(userID uint, role string, docID uint, req *UpdateDocumentRequest, restoredFrom *int) (*models.Document, error) {
 	if userID == 0 {
 		return nil, errors.New("invalid user")
 	}
-	return s.documentRepo.ListByUser(userID)
+
+	doc, err := s.manageableDocument(s.documentRepo, userID, role, docID)
+	if err != nil {
+		return nil, err
+	}
+	original := *doc
+
+	// 新的标题和内容同样先去除身份信息，再与当前内容比较
+	var redaction *models.DocumentRedaction
+	if req.Title != nil || req.Content != nil {
+		redacted := *req
+		title := doc.Title
+		if req.Title != nil {
+			title = strings.TrimSpace(*req.Title)
+		}
+		if req.Content != nil {
+			content := *req.Content
+			redacted.Content = &content
+		}
+		title, redaction = s.deidentify(title, redacted.Content)
+		if req.Title != nil {
+			redacted.Title = &title
+		}
+		req = &redacted
+	}
+
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, errors.New("title cannot be empty")
+		}
+		if utf8.RuneCountInString(title) > maxDocumentTitleLen {
+			return nil, ErrTitleTooLong
+		}
+		doc.Title = title
+	}
+	if err := s.applyMetadataUpdate(userID, doc, &req.UpdateDocumentMetadataRequest); err != nil {
+		return nil, err
+	}
+	contentChanged := req.Content != nil && *req.Content != doc.Content
+	if contentChanged {
+		if strings.TrimSpace(*req.Content) == "" {
+			return nil, errors.New("content cannot be empty")
+		}
+		doc.Content = *req.Content
+		doc.Status = models.DocumentStatusPending
+		doc.ErrorMessage = ""
+		if err := s.checkDuplicates(doc); err != nil {
+			return nil, err
+		}
+	}
+	previousVersion := doc.Version
+	if contentChanged {
+		doc.Version++
+		if redaction != nil {
+			doc.PHIRedactions = redaction.Total
+		}
+	}
+
+	eventType := models.OutboxEventDocumentMetadataUpdated
+	if contentChanged {
+		eventType = models.OutboxEventDocumentUpdated
+	}
+	err = s.documentRepo.Transaction(func(tx *gorm.DB) error {
+		if err := lockDocumentKnowledgeBase(s.kbRepo, tx, doc); err != nil {
+			return err
+		}
+		repo := s.documentRepo.WithTx(tx)
+		// 内容变化或标题中有替换时记录报告
+		if redaction != nil && (contentChanged || redaction.Total > 0) {
+			redaction.DocumentID = doc.ID
+			redaction.Version = doc.Version
+			if err := s.redactions.WithTx(tx).Create(redaction); err != nil {
+				return err
+			}
+		}
+		if !contentChanged {
+			if err := repo.UpdateMetadata(doc); err != nil {
+				return err
+			}
+			return s.outboxRepo.WithTx(tx).Create(newDocumentOutboxEvent(eventType, doc.ID, doc.UserID))
+		}
+
+		// 早于版本功能创建的文档没有快照，先补存变更前的内容
+		versions := s.versionRepo.WithTx(tx)
+		exists, err := versions.Exists(doc.ID, previousVersion)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := versions.Create(&models.DocumentVersion{
+				DocumentID: doc.ID,
+				Version:    previousVersion,
+				Title:      original.Title,
+				Content:    original.Content,
+				UserID:     original.UserID,
+			}); err != nil {
+				return err
+			}
+		}
+		if err := repo.UpdateContent(doc); err != nil {
+			return err
+		}
+		version := newDocumentVersion(doc, restoredFrom)
+		version.UserID = userID
+		if err := versions.Create(version); err != nil {
+			return err
+		}
+		return s.outboxRepo.WithTx(tx).Create(newDocumentOutboxEvent(eventType, doc.ID, doc.UserID))
+	})
+	if err != nil {
+		logger.L.Error("failed to update document",
+			zap.Error(err),
+			zap.Uint("document_id", docID),
+			zap.Uint("user_id", userID),
+			zap.Bool("content_changed", contentChanged),
+		)
+		return nil, err
+	}
+	s.dispatcher.Notify()
+
+	if contentChanged {
+		// 索引状态事件推送给文档所有者
+		s.events.Publish(doc.UserID, DocumentEvent{
+			DocumentID: doc.ID,
+			Title:      doc.Title,
+			Status:     doc.Status,
+		})
+	}
+
+	return doc, nil
 }
 
 func (s *DocumentService) Get(userID, docID uint) (*models.Document, error) {
 	if userID == 0 {
 		return nil, errors.New("invalid user")
 	}
-	return s.documentRepo.GetByIDAndUser(docID, userID)
+	return s.documentRepo.GetAccessible(docID, userID)
+}
+
+// Subscribe 订阅用户自己文档的索引状态事件
+func (s *DocumentService) Subscribe(userID uint) (<-chan DocumentEvent, func()) {
+	return s.events.Subscribe(userID)
 }
 
-func (s *DocumentService) Delete(userID, docID uint) error {
+// Delete 删除文档。用户可以删除自己的文档，管理员还可以删除任何共享文档
+func (s *DocumentService) Delete(userID uint, role string, docID uint) error {
 	if userID == 0 {
 		return errors.New("invalid user")
 	}
 
-	// 先删除 Chroma 中的向量数据（如果启用）
-	if s.ragService != nil && s.ragService.IsEnabled() {
-		if err := s.ragService.DeleteDocument(context.Background(), docID, userID); err != nil {
-			logger.L.Error("failed to delete document from RAG",
-				zap.Error(err),
-				zap.Uint("document_id", docID),
-				zap.Uint("user_id", userID),
-			)
+	// 删除数据库中的文档记录，并在同一事务中记录删除事件，由分发器清理 Chroma 中的向量；
+	// 原始文件在事务提交后删除
+	var fileKey string
+	err := s.documentRepo.Transaction(func(tx *gorm.DB) error {
+		doc, err := s.manageableDocument(s.documentRepo.WithTx(tx), userID, role, docID)
+		if err != nil {
+			return err
 		}
-	}
-
-	// 删除数据库中的文档记录
-	if err := s.documentRepo.DeleteByIDAndUser(docID, userID); err != nil {
+		fileKey = doc.FileKey
+		if err := s.documentRepo.WithTx(tx).DeleteByIDAndUser(docID, doc.UserID); err != nil {
+			return err
+		}
+		if err := s.versionRepo.WithTx(tx).DeleteByDocument(docID); err != nil {
+			return err
+		}
+		if err := s.redactions.WithTx(tx).DeleteByDocument(docID); err != nil {
+			return err
+		}
+		// Chroma 中的块按所有者写入，删除事件须使用所有者 ID
+		return s.outboxRepo.WithTx(tx).Create(newDocumentOutboxEvent(models.OutboxEventDocumentDeleted, docID, doc.UserID))
+	})
+	if err != nil {
 		logger.L.Error("failed to delete document from database",
 			zap.Error(err),
 			zap.Uint("document_id", docID),
@@ -119,6 +575,8 @@ func (s *DocumentService) Delete(userID, docID uint) error {
 		)
 		return err
 	}
+	s.dispatcher.Notify()
+	s.deleteOriginal(fileKey)
 
 	logger.L.Info("document deleted successfully",
 		zap.Uint("document_id", docID),
@@ -127,3 +585,139 @@ func (s *DocumentService) Delete(userID, docID uint) error {
 
 	return nil
 }
+
+// ReviewDueDocument 是复审报告中的一项
+type ReviewDueDocument struct {
+	ID             uint      `json:"id"`
+	UserID         uint      `json:"user_id"`
+	Title          string    `json:"title"`
+	Visibility     string    `json:"visibility"`
+	ReviewBy       time.Time `json:"review_by"`
+	DaysOverdue    int       `json:"days_overdue"`
+	SupersededByID *uint     `json:"superseded_by_id,omitempty"`
+}
+
+// 复审报告每页的默认和最大条数
+const (
+	defaultReviewPageSize = 50
+	maxReviewPageSize     = 200
+)
+
+// ReviewReport 是已过复审日期的文档列表中的一页，Total 为过期文档总数
+type ReviewReport struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Total       int64               `json:"total"`
+	Limit       int                 `json:"limit"`
+	Offset      int                 `json:"offset"`
+	Documents   []ReviewDueDocument `json:"documents"`
+}
+
+// ReviewDue 分页生成复审报告，包含所有用户的文档，供管理员使用
+func (s *DocumentService) ReviewDue(now time.Time, limit, offset int) (*ReviewReport, error) {
+	if limit <= 0 {
+		limit = defaultReviewPageSize
+	}
+	limit = min(limit, maxReviewPageSize)
+	offset = max(offset, 0)
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	docs, total, err := s.documentRepo.ListReviewDue(today, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReviewReport{
+		GeneratedAt: now,
+		Total:       total,
+		Limit:       limit,
+		Offset:      offset,
+		Documents:   make([]ReviewDueDocument, 0, len(docs)),
+	}
+	for _, doc := range docs {
+		report.Documents = append(report.Documents, ReviewDueDocument{
+			ID:             doc.ID,
+			UserID:         doc.UserID,
+			Title:          doc.Title,
+			Visibility:     documentVisibility(&doc),
+			ReviewBy:       *doc.ReviewBy,
+			DaysOverdue:    int(today.Sub(*doc.ReviewBy).Hours() / 24),
+			SupersededByID: doc.SupersededByID,
+		})
+	}
+	return report, nil
+}
+
+// manageableDocument 返回用户可以修改和删除的文档：自己的私有或共享文档，管理员还可以管理任何共享文档。
+// 共享文档组成全组织知识库，由管理员共同维护
+func (s *DocumentService) manageableDocument(repo *repositories.DocumentRepository, userID uint, role string, docID uint) (*models.Document, error) {
+	return repo.GetManageable(docID, userID, role == models.RoleAdmin)
+}
+
+// validateSupersededBy 检查取代文档存在、对用户可见，且不是文档自身
+func (s *DocumentService) validateSupersededBy(userID, docID, supersededByID uint) error {
+	if supersededByID == 0 || supersededByID == docID {
+		return errors.New("invalid superseded_by_id")
+	}
+	if _, err := s.documentRepo.GetAccessible(supersededByID, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("superseding document not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// truncateRunes 将 s 截断为最多 n 个字符
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// parseReviewBy 解析 YYYY-MM-DD 格式的复审日期，空字符串表示不设置
+func parseReviewBy(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	reviewBy, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, errors.New("invalid review_by, expected YYYY-MM-DD")
+	}
+	return &reviewBy, nil
+}
+
+// normalizeTags 去除标签首尾空白、空标签和重复项，并检查数量和长度限制
+func normalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxDocumentTagLen {
+			return nil, fmt.Errorf("tag %q exceeds %d characters", tag, maxDocumentTagLen)
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		out = append(out, tag)
+	}
+	if len(out) > maxDocumentTags {
+		return nil, fmt.Errorf("too many tags (max %d)", maxDocumentTags)
+	}
+	return out, nil
+}
+
+func newDocumentOutboxEvent(eventType string, docID, userID uint) *models.OutboxEvent {
+	return &models.OutboxEvent{
+		EventType:  eventType,
+		DocumentID: docID,
+		UserID:     userID,
+		Status:     models.OutboxStatusPending,
+		NextRunAt:  time.Now(),
+	}
+}
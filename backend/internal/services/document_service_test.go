@@ -0,0 +1,50 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"medical-qa-assistant/internal/models"
+)
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		in   string
+		n    int
+		want string
+	}{
+		{"", 3, ""},
+		{"abc", 3, "abc"},
+		{"abcd", 3, "abc"},
+		{"糖尿病指南", 3, "糖尿病"},
+		{"糖尿病", 5, "糖尿病"},
+	}
+	for _, tt := range tests {
+		if got := truncateRunes(tt.in, tt.n); got != tt.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.in, tt.n, got, tt.want)
+		}
+	}
+}
+
+// 超长标题在写库之前被拒绝，长度按字符而不是字节计算
+func TestCreateRejectsLongTitle(t *testing.T) {
+	s := &DocumentService{}
+	req := &CreateDocumentRequest{Title: strings.Repeat("指", maxDocumentTitleLen+1), Content: "内容"}
+	if _, err := s.Create(1, models.RoleUser, req); !errors.Is(err, ErrTitleTooLong) {
+		t.Errorf("Create = %v, want ErrTitleTooLong", err)
+	}
+}
+
+// 压缩包中路径过长的文件记录为带错误码的拒绝条目，不影响批次中的其他文件
+func TestCreateEntryLongFilename(t *testing.T) {
+	s := &UploadBatchService{documentService: &DocumentService{}}
+	name := strings.Repeat("目录/", 200) + strings.Repeat("长", 300) + ".txt"
+	item := s.createEntry(1, models.RoleUser, &CreateDocumentRequest{}, BatchEntry{Filename: name, Content: "内容"})
+	if item.Status != models.UploadBatchItemRejected || item.ErrorCode != batchCodeTitleTooLong {
+		t.Errorf("item = %+v, want rejected with %q", item, batchCodeTitleTooLong)
+	}
+	if n := len([]rune(item.Filename)); n != maxBatchItemFilenameLen {
+		t.Errorf("item filename has %d characters, want %d", n, maxBatchItemFilenameLen)
+	}
+}
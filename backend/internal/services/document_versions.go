@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/textdiff"
+
+	"gorm.io/gorm"
+)
+
+// 版本差异中每处修改前后保留的上下文行数
+const versionDiffContext = 3
+
+// ErrVersionNotFound 表示文档没有指定的版本
+var ErrVersionNotFound = errors.New("document version not found")
+
+// VersionDiff 是两个版本之间按行比较的结果
+type VersionDiff struct {
+	DocumentID   uint   `json:"document_id"`
+	From         int    `json:"from"`
+	To           int    `json:"to"`
+	FromTitle    string `json:"from_title"`
+	ToTitle      string `json:"to_title"`
+	LinesAdded   int    `json:"lines_added"`
+	LinesRemoved int    `json:"lines_removed"`
+	Diff         string `json:"diff"` // unified 格式，内容相同时为空
+}
+
+// newDocumentVersion 以文档当前的标题和内容生成版本快照
+func newDocumentVersion(doc *models.Document, restoredFrom *int) *models.DocumentVersion {
+	return &models.DocumentVersion{
+		DocumentID:   doc.ID,
+		Version:      doc.Version,
+		Title:        doc.Title,
+		Content:      doc.Content,
+		UserID:       doc.UserID,
+		RestoredFrom: restoredFrom,
+	}
+}
+
+// ListVersions 按版本号倒序返回文档的版本（不含内容），可见的文档都可以查看
+func (s *DocumentService) ListVersions(userID, docID uint) ([]models.DocumentVersion, error) {
+	doc, err := s.Get(userID, docID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.versionRepo.ListByDocument(doc.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		// 早于版本功能创建且从未修改过的文档，只有当前版本
+		current := newDocumentVersion(doc, nil)
+		current.Content = ""
+		current.CreatedAt = doc.CreatedAt
+		versions = append(versions, *current)
+	}
+	return versions, nil
+}
+
+// GetVersion 返回文档指定版本的内容
+func (s *DocumentService) GetVersion(userID, docID uint, version int) (*models.DocumentVersion, error) {
+	doc, err := s.Get(userID, docID)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadVersion(doc, version)
+}
+
+// DiffVersions 比较文档的两个版本
+func (s *DocumentService) DiffVersions(userID, docID uint, from, to int) (*VersionDiff, error) {
+	doc, err := s.Get(userID, docID)
+	if err != nil {
+		return nil, err
+	}
+	fromVersion, err := s.loadVersion(doc, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := s.loadVersion(doc, to)
+	if err != nil {
+		return nil, err
+	}
+
+	result := textdiff.Lines(fromVersion.Content, toVersion.Content, versionDiffContext)
+	return &VersionDiff{
+		DocumentID:   doc.ID,
+		From:         from,
+		To:           to,
+		FromTitle:    fromVersion.Title,
+		ToTitle:      toVersion.Title,
+		LinesAdded:   result.Added,
+		LinesRemoved: result.Removed,
+		Diff:         result.Unified(fmt.Sprintf("v%d", from), fmt.Sprintf("v%d", to)),
+	}, nil
+}
+
+// RestoreVersion 以旧版本的标题和内容生成一个新版本，并重新索引。可以修改文档的用户才能恢复
+func (s *DocumentService) RestoreVersion(userID uint, role string, docID uint, version int) (*models.Document, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	doc, err := s.manageableDocument(s.documentRepo, userID, role, docID)
+	if err != nil {
+		return nil, err
+	}
+	if version == doc.Version {
+		return nil, errors.New("version is already current")
+	}
+	old, err := s.loadVersion(doc, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.update(userID, role, docID, &UpdateDocumentRequest{
+		Title:   &old.Title,
+		Content: &old.Content,
+	}, &version)
+}
+
+// loadVersion 读取版本快照；没有快照的当前版本（早于版本功能创建的文档）直接取文档内容
+func (s *DocumentService) loadVersion(doc *models.Document, version int) (*models.DocumentVersion, error) {
+	v, err := s.versionRepo.Get(doc.ID, version)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if version == doc.Version {
+			current := newDocumentVersion(doc, nil)
+			current.CreatedAt = doc.UpdatedAt
+			return current, nil
+		}
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
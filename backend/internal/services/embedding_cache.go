@@ -0,0 +1,58 @@
+package services
+
+import (
+	"container/list"
+	"sync"
+)
+
+// 缓存的查询向量条数
+const queryEmbeddingCacheSize = 256
+
+// embeddingCache 是查询文本到嵌入向量的 LRU 缓存。
+// 同一问题翻页或重复检索时不再调用嵌入接口
+type embeddingCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // 最近使用的在前
+	entries map[string]*list.Element
+}
+
+type embeddingCacheEntry struct {
+	key    string
+	vector []float32
+}
+
+func newEmbeddingCache(size int) *embeddingCache {
+	return &embeddingCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *embeddingCache) get(key string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*embeddingCacheEntry).vector, true
+}
+
+func (c *embeddingCache) put(key string, vector []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*embeddingCacheEntry).vector = vector
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&embeddingCacheEntry{key: key, vector: vector})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*embeddingCacheEntry).key)
+	}
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 单个索引任务允许运行的最长时间（包括嵌入和写入 Chroma）
+const indexJobTimeout = 10 * time.Minute
+
+// 重试退避的上限，索引任务和 outbox 事件共用
+const maxIndexRetryBackoff = 30 * time.Minute
+
+// retryDelay 返回第 attempts 次失败后的等待时间：base * 2^(attempts-1)，不超过 maxIndexRetryBackoff
+func retryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxIndexRetryBackoff {
+			return maxIndexRetryBackoff
+		}
+	}
+	return delay
+}
+
+// indexJobStore 是 IndexWorker 使用的任务队列操作，由 repositories.IndexJobRepository 实现
+type indexJobStore interface {
+	Create(job *models.IndexJob) error
+	Update(job *models.IndexJob) error
+	HasPending(documentID uint) (bool, error)
+	ClaimNext(now time.Time) (*models.IndexJob, error)
+	RequeueProcessing() (int64, error)
+}
+
+// indexDocumentStore 是 IndexWorker 读取文档和推进索引状态所需的操作，由 repositories.DocumentRepository 实现
+type indexDocumentStore interface {
+	GetByID(id uint) (*models.Document, error)
+	UpdateStatus(id uint, status, errorMessage string) error
+	MarkReady(id uint, chunkCount int) error
+}
+
+// IndexWorker 从持久化的任务队列中领取文档索引任务，并通过 RAGService 完成分块、嵌入和写入
+type IndexWorker struct {
+	jobRepo      indexJobStore
+	documentRepo indexDocumentStore
+	ragService   *RAGService
+	events       *DocumentEventHub
+
+	workers      int
+	maxAttempts  int
+	pollInterval time.Duration
+	retryBackoff time.Duration
+
+	// wake 用于在新任务入队时立即唤醒空闲的 worker
+	wake      chan struct{}
+	startOnce sync.Once
+}
+
+func NewIndexWorker(
+	jobRepo *repositories.IndexJobRepository,
+	documentRepo *repositories.DocumentRepository,
+	ragService *RAGService,
+	events *DocumentEventHub,
+	workers, maxAttempts int,
+	pollInterval, retryBackoff time.Duration,
+) *IndexWorker {
+	if workers <= 0 {
+		workers = 1
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	if retryBackoff <= 0 {
+		retryBackoff = 10 * time.Second
+	}
+	return &IndexWorker{
+		jobRepo:      jobRepo,
+		documentRepo: documentRepo,
+		ragService:   ragService,
+		events:       events,
+		workers:      workers,
+		maxAttempts:  maxAttempts,
+		pollInterval: pollInterval,
+		retryBackoff: retryBackoff,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Enqueue 为文档创建一个索引任务。若该文档已有等待中的任务则不会重复创建
+func (w *IndexWorker) Enqueue(doc *models.Document) error {
+	if doc == nil || doc.ID == 0 {
+		return errors.New("invalid document for indexing")
+	}
+
+	pending, err := w.jobRepo.HasPending(doc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check pending index jobs: %w", err)
+	}
+	if !pending {
+		job := &models.IndexJob{
+			DocumentID: doc.ID,
+			UserID:     doc.UserID,
+			Status:     models.IndexJobStatusPending,
+			NextRunAt:  time.Now(),
+		}
+		if err := w.jobRepo.Create(job); err != nil {
+			return fmt.Errorf("failed to create index job: %w", err)
+		}
+		logger.L.Info("index job enqueued",
+			zap.Uint("job_id", job.ID),
+			zap.Uint("document_id", doc.ID),
+			zap.Uint("user_id", doc.UserID),
+		)
+	}
+
+	w.notify()
+	return nil
+}
+
+// Start 恢复上次未完成的任务并启动 worker 池，ctx 取消时所有 worker 退出
+func (w *IndexWorker) Start(ctx context.Context) {
+	w.startOnce.Do(func() {
+		requeued, err := w.jobRepo.RequeueProcessing()
+		if err != nil {
+			logger.L.Error("failed to requeue unfinished index jobs", zap.Error(err))
+		} else if requeued > 0 {
+			logger.L.Info("requeued unfinished index jobs", zap.Int64("count", requeued))
+		}
+
+		logger.L.Info("index worker pool starting",
+			zap.Int("workers", w.workers),
+			zap.Int("max_attempts", w.maxAttempts),
+			zap.Duration("poll_interval", w.pollInterval),
+		)
+		for i := 0; i < w.workers; i++ {
+			go w.run(ctx, i)
+		}
+	})
+}
+
+func (w *IndexWorker) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 是单个 worker 的主循环：领取任务直到队列为空，然后等待唤醒或下一次轮询
+func (w *IndexWorker) run(ctx context.Context, workerID int) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			job, err := w.jobRepo.ClaimNext(time.Now())
+			if err != nil {
+				logger.L.Error("failed to claim index job",
+					zap.Error(err),
+					zap.Int("worker", workerID),
+				)
+				break
+			}
+			if job == nil {
+				break
+			}
+			w.process(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// process 执行一个索引任务，并根据结果推进文档状态：processing → ready / failed，
+// 可重试的失败会以指数退避重新排队
+func (w *IndexWorker) process(ctx context.Context, job *models.IndexJob) {
+	doc, err := w.documentRepo.GetByID(job.DocumentID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 文档在排队期间被删除，任务无需再执行
+		logger.L.Info("document gone, dropping index job",
+			zap.Uint("job_id", job.ID),
+			zap.Uint("document_id", job.DocumentID),
+		)
+		w.finishJob(job, models.IndexJobStatusDone, "")
+		return
+	}
+	if err != nil {
+		w.retryOrFail(job, fmt.Errorf("failed to load document: %w", err))
+		return
+	}
+
+	if err := w.documentRepo.UpdateStatus(doc.ID, models.DocumentStatusProcessing, ""); err != nil {
+		logger.L.Warn("failed to mark document processing",
+			zap.Error(err),
+			zap.Uint("document_id", doc.ID),
+		)
+	}
+	w.events.Publish(job.UserID, DocumentEvent{
+		DocumentID: doc.ID,
+		Title:      doc.Title,
+		Status:     models.DocumentStatusProcessing,
+		Attempt:    job.Attempts,
+	})
+
+	jobCtx, cancel := context.WithTimeout(ctx, indexJobTimeout)
+	defer cancel()
+
+	chunkCount, err := w.index(jobCtx, doc)
+	if err != nil {
+		w.retryOrFail(job, err)
+		return
+	}
+
+	// 索引期间文档可能已被删除，此时删除事件可能早于本次写入执行，需要再清理一次
+	current, err := w.documentRepo.GetByID(doc.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.L.Info("document deleted during indexing, removing vectors",
+			zap.Uint("job_id", job.ID),
+			zap.Uint("document_id", doc.ID),
+		)
+		if err := w.ragService.DeleteDocument(jobCtx, doc.ID, doc.UserID); err != nil {
+			w.retryOrFail(job, err)
+			return
+		}
+		w.finishJob(job, models.IndexJobStatusDone, "")
+		return
+	}
+	// 索引期间内容又被修改，写入的是旧内容，重新入队并保持文档为待索引状态
+	if err == nil && current.Content != doc.Content {
+		logger.L.Info("document content changed during indexing, re-enqueueing",
+			zap.Uint("job_id", job.ID),
+			zap.Uint("document_id", doc.ID),
+		)
+		if err := w.Enqueue(current); err != nil {
+			w.retryOrFail(job, err)
+			return
+		}
+		w.finishJob(job, models.IndexJobStatusDone, "")
+		return
+	}
+	// 索引期间元数据被修改（标签、知识库、可见范围等）：对应的元数据事件可能已先于本次写入执行，
+	// 写入的块带着旧元数据，需要按当前文档重新覆盖
+	if err == nil && !reflect.DeepEqual(documentMetadata(current), documentMetadata(doc)) {
+		logger.L.Info("document metadata changed during indexing, re-applying",
+			zap.Uint("job_id", job.ID),
+			zap.Uint("document_id", doc.ID),
+		)
+		if err := w.ragService.UpdateDocumentMetadata(jobCtx, current); err != nil {
+			w.retryOrFail(job, err)
+			return
+		}
+		doc = current
+	}
+
+	if err := w.documentRepo.MarkReady(doc.ID, chunkCount); err != nil {
+		logger.L.Error("failed to mark document ready",
+			zap.Error(err),
+			zap.Uint("document_id", doc.ID),
+		)
+	}
+	w.finishJob(job, models.IndexJobStatusDone, "")
+	w.events.Publish(job.UserID, DocumentEvent{
+		DocumentID: doc.ID,
+		Title:      doc.Title,
+		Status:     models.DocumentStatusReady,
+		ChunkCount: chunkCount,
+		Attempt:    job.Attempts,
+	})
+
+	logger.L.Info("document indexed into RAG",
+		zap.Uint("job_id", job.ID),
+		zap.Uint("document_id", doc.ID),
+		zap.Uint("user_id", doc.UserID),
+		zap.Int("attempt", job.Attempts),
+		zap.Int("chunk_count", chunkCount),
+	)
+}
+
+// index 写入（按块 ID 覆盖）文档的全部块，再删除序号超出新块数的旧块，使新建和内容变更走同一条路径。
+// 先写后删，重新索引期间文档始终可以被检索到
+func (w *IndexWorker) index(ctx context.Context, doc *models.Document) (int, error) {
+	if !w.ragService.IsEnabled() {
+		return 0, nil
+	}
+	chunkCount, err := w.ragService.IndexDocument(ctx, doc)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.ragService.DeleteStaleChunks(ctx, doc, chunkCount); err != nil {
+		return 0, err
+	}
+	return chunkCount, nil
+}
+
+func (w *IndexWorker) retryOrFail(job *models.IndexJob, cause error) {
+	if job.Attempts >= w.maxAttempts {
+		logger.L.Error("index job failed permanently",
+			zap.Error(cause),
+			zap.Uint("job_id", job.ID),
+			zap.Uint("document_id", job.DocumentID),
+			zap.Int("attempts", job.Attempts),
+		)
+		if err := w.documentRepo.UpdateStatus(job.DocumentID, models.DocumentStatusFailed, cause.Error()); err != nil {
+			logger.L.Error("failed to mark document failed",
+				zap.Error(err),
+				zap.Uint("document_id", job.DocumentID),
+			)
+		}
+		w.finishJob(job, models.IndexJobStatusFailed, cause.Error())
+		w.events.Publish(job.UserID, DocumentEvent{
+			DocumentID: job.DocumentID,
+			Status:     models.DocumentStatusFailed,
+			Attempt:    job.Attempts,
+			Error:      cause.Error(),
+		})
+		return
+	}
+
+	delay := retryDelay(w.retryBackoff, job.Attempts)
+	logger.L.Warn("index job failed, will retry",
+		zap.Error(cause),
+		zap.Uint("job_id", job.ID),
+		zap.Uint("document_id", job.DocumentID),
+		zap.Int("attempts", job.Attempts),
+		zap.Duration("retry_in", delay),
+	)
+
+	// 等待重试期间文档回到 pending，并保留最近一次的错误信息
+	if err := w.documentRepo.UpdateStatus(job.DocumentID, models.DocumentStatusPending, cause.Error()); err != nil {
+		logger.L.Error("failed to mark document pending for retry",
+			zap.Error(err),
+			zap.Uint("document_id", job.DocumentID),
+		)
+	}
+
+	w.events.Publish(job.UserID, DocumentEvent{
+		DocumentID: job.DocumentID,
+		Status:     models.DocumentStatusPending,
+		Attempt:    job.Attempts,
+		Error:      cause.Error(),
+	})
+
+	job.Status = models.IndexJobStatusPending
+	job.LastError = cause.Error()
+	job.NextRunAt = time.Now().Add(delay)
+	if err := w.jobRepo.Update(job); err != nil {
+		logger.L.Error("failed to reschedule index job",
+			zap.Error(err),
+			zap.Uint("job_id", job.ID),
+		)
+	}
+}
+
+func (w *IndexWorker) finishJob(job *models.IndexJob, status, lastError string) {
+	job.Status = status
+	job.LastError = lastError
+	if err := w.jobRepo.Update(job); err != nil {
+		logger.L.Error("failed to update index job",
+			zap.Error(err),
+			zap.Uint("job_id", job.ID),
+			zap.String("status", status),
+		)
+	}
+}
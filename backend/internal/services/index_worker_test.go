@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// fakeJobStore 是内存中的索引任务队列
+type fakeJobStore struct {
+	mu   sync.Mutex
+	jobs []*models.IndexJob
+}
+
+func (s *fakeJobStore) Create(job *models.IndexJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.ID = uint(len(s.jobs) + 1)
+	copied := *job
+	s.jobs = append(s.jobs, &copied)
+	return nil
+}
+
+func (s *fakeJobStore) Update(job *models.IndexJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *job
+	s.jobs[job.ID-1] = &copied
+	return nil
+}
+
+func (s *fakeJobStore) HasPending(documentID uint) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.DocumentID == documentID && job.Status == models.IndexJobStatusPending {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeJobStore) ClaimNext(now time.Time) (*models.IndexJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.Status == models.IndexJobStatusPending && !job.NextRunAt.After(now) {
+			job.Status = models.IndexJobStatusProcessing
+			job.Attempts++
+			copied := *job
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *fakeJobStore) RequeueProcessing() (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeJobStore) job(id uint) models.IndexJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.jobs[id-1]
+}
+
+// fakeDocumentStore 是内存中的文档表，beforeGet 在每次 GetByID 前调用，用于模拟并发修改
+type fakeDocumentStore struct {
+	mu        sync.Mutex
+	docs      map[uint]*models.Document
+	gets      int
+	beforeGet func(gets int, docs map[uint]*models.Document)
+}
+
+func newFakeDocumentStore(docs ...*models.Document) *fakeDocumentStore {
+	s := &fakeDocumentStore{docs: make(map[uint]*models.Document)}
+	for _, doc := range docs {
+		s.docs[doc.ID] = doc
+	}
+	return s
+}
+
+func (s *fakeDocumentStore) GetByID(id uint) (*models.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gets++
+	if s.beforeGet != nil {
+		s.beforeGet(s.gets, s.docs)
+	}
+	doc, ok := s.docs[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *doc
+	return &copied, nil
+}
+
+func (s *fakeDocumentStore) UpdateStatus(id uint, status, errorMessage string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc, ok := s.docs[id]; ok {
+		doc.Status = status
+		doc.ErrorMessage = errorMessage
+	}
+	return nil
+}
+
+func (s *fakeDocumentStore) MarkReady(id uint, chunkCount int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if doc, ok := s.docs[id]; ok {
+		doc.Status = models.DocumentStatusReady
+		doc.ErrorMessage = ""
+		doc.ChunkCount = chunkCount
+	}
+	return nil
+}
+
+func (s *fakeDocumentStore) get(id uint) models.Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.docs[id]
+}
+
+// newTestWorker 返回使用内存队列和文档表的 IndexWorker，重试退避为 10 秒、最多尝试 3 次
+func newTestWorker(rag *RAGService, docs *fakeDocumentStore) (*IndexWorker, *fakeJobStore) {
+	logger.L = zap.NewNop()
+	jobs := &fakeJobStore{}
+	w := &IndexWorker{
+		jobRepo:      jobs,
+		documentRepo: docs,
+		ragService:   rag,
+		events:       NewDocumentEventHub(),
+		workers:      1,
+		maxAttempts:  3,
+		pollInterval: time.Second,
+		retryBackoff: 10 * time.Second,
+		wake:         make(chan struct{}, 1),
+	}
+	return w, jobs
+}
+
+// claim 入队并领取文档的索引任务
+func claim(t *testing.T, w *IndexWorker, jobs *fakeJobStore, doc *models.Document) *models.IndexJob {
+	t.Helper()
+	if err := w.Enqueue(doc); err != nil {
+		t.Fatal(err)
+	}
+	job, err := jobs.ClaimNext(time.Now())
+	if err != nil || job == nil {
+		t.Fatalf("ClaimNext = %v, %v", job, err)
+	}
+	return job
+}
+
+// DocumentUpdated 事件最终由 IndexWorker.index 重新写入文档的块
+func TestIndexReindexAfterContentUpdate(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	w := &IndexWorker{ragService: rag}
+	ctx := context.Background()
+
+	doc := &models.Document{
+		ID:      3,
+		UserID:  7,
+		Title:   "高血压指南",
+		Content: strings.Repeat("血压控制目标为 130/80 mmHg。", 120),
+		Tags:    []string{"高血压", "老年"},
+		Version: 1,
+	}
+	first, err := w.index(ctx, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first < 2 {
+		t.Fatalf("expected several chunks, got %d", first)
+	}
+
+	// 内容变短且移除了一个标签
+	doc.Content = "血压控制目标为 140/90 mmHg。"
+	doc.Tags = []string{"高血压"}
+	doc.Version = 2
+	second, err := w.index(ctx, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != 1 || len(fake.records) != 1 {
+		t.Fatalf("after update: chunk count %d, stored %d, want 1", second, len(fake.records))
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{Tags: []string{"老年"}}); len(got) != 0 {
+		t.Errorf("removed tag still matches chunks of documents %v", got)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{Tags: []string{"高血压"}}); len(got) != 1 {
+		t.Errorf("kept tag matched %v, want one chunk", got)
+	}
+	for id, record := range fake.records {
+		if v, _ := record.metadata["version"].(float64); v != 2 {
+			t.Errorf("chunk %s has version %v, want 2", id, v)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{8, 1280 * time.Second},
+		{9, maxIndexRetryBackoff},
+		{100, maxIndexRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(10*time.Second, tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(10s, %d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// 未达到最大尝试次数时任务按指数退避重新排队，文档回到 pending 并保留错误信息
+func TestRetryOrFailBackoff(t *testing.T) {
+	doc := &models.Document{ID: 1, UserID: 7, Status: models.DocumentStatusProcessing}
+	docs := newFakeDocumentStore(doc)
+	w, jobs := newTestWorker(nil, docs)
+	events, cancel := w.events.Subscribe(7)
+	defer cancel()
+
+	job := claim(t, w, jobs, doc)
+	job.Attempts = 2
+	before := time.Now()
+	w.retryOrFail(job, errors.New("embedding timeout"))
+
+	stored := jobs.job(job.ID)
+	if stored.Status != models.IndexJobStatusPending || stored.LastError != "embedding timeout" {
+		t.Errorf("job = %+v, want pending with last error", stored)
+	}
+	// 第 2 次失败后等待 base * 2
+	if wait := stored.NextRunAt.Sub(before); wait < 20*time.Second || wait > 21*time.Second {
+		t.Errorf("next run in %v, want ~20s", wait)
+	}
+	if got := docs.get(1); got.Status != models.DocumentStatusPending || got.ErrorMessage != "embedding timeout" {
+		t.Errorf("document status %q error %q", got.Status, got.ErrorMessage)
+	}
+	if event := <-events; event.Status != models.DocumentStatusPending || event.Attempt != 2 {
+		t.Errorf("event = %+v", event)
+	}
+}
+
+// 达到最大尝试次数后任务和文档都标记为 failed，不再排队
+func TestRetryOrFailGivesUp(t *testing.T) {
+	doc := &models.Document{ID: 1, UserID: 7, Status: models.DocumentStatusProcessing}
+	docs := newFakeDocumentStore(doc)
+	w, jobs := newTestWorker(nil, docs)
+
+	job := claim(t, w, jobs, doc)
+	job.Attempts = w.maxAttempts
+	w.retryOrFail(job, errors.New("chroma unavailable"))
+
+	if stored := jobs.job(job.ID); stored.Status != models.IndexJobStatusFailed || stored.LastError != "chroma unavailable" {
+		t.Errorf("job = %+v, want failed", stored)
+	}
+	if got := docs.get(1); got.Status != models.DocumentStatusFailed {
+		t.Errorf("document status = %q, want failed", got.Status)
+	}
+	if next, _ := jobs.ClaimNext(time.Now().Add(time.Hour)); next != nil {
+		t.Errorf("failed job claimed again: %+v", next)
+	}
+}
+
+// Chroma 持续不可用时，任务依次退避重试，直到用完尝试次数
+func TestProcessRetriesUntilMaxAttempts(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	fake.unavailable = true
+	doc := &models.Document{ID: 1, UserID: 7, Title: "指南", Content: "二甲双胍"}
+	docs := newFakeDocumentStore(doc)
+	w, jobs := newTestWorker(rag, docs)
+	if err := w.Enqueue(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for attempt := 1; attempt <= w.maxAttempts; attempt++ {
+		job, err := jobs.ClaimNext(now.Add(time.Hour))
+		if err != nil || job == nil {
+			t.Fatalf("attempt %d: ClaimNext = %v, %v", attempt, job, err)
+		}
+		w.process(context.Background(), job)
+	}
+	if stored := jobs.job(1); stored.Status != models.IndexJobStatusFailed || stored.Attempts != w.maxAttempts {
+		t.Errorf("job = %+v, want failed after %d attempts", stored, w.maxAttempts)
+	}
+	if got := docs.get(1); got.Status != models.DocumentStatusFailed || got.ErrorMessage == "" {
+		t.Errorf("document status %q error %q", got.Status, got.ErrorMessage)
+	}
+}
+
+// 索引任务嵌入期间元数据事件先一步更新了 Chroma，任务随后写入的块带着旧元数据，
+// 完成时必须按文档当前的元数据重新覆盖，而不是悄悄撤销这次修改
+func TestProcessReappliesMetadataChangedDuringIndexing(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	doc := &models.Document{
+		ID:         3,
+		UserID:     7,
+		Title:      "高血压指南",
+		Content:    strings.Repeat("血压控制目标为 130/80 mmHg。", 120),
+		Tags:       []string{"高血压"},
+		Visibility: models.DocumentVisibilityPrivate,
+		Version:    1,
+	}
+	docs := newFakeDocumentStore(doc)
+	w, jobs := newTestWorker(rag, docs)
+	ctx := context.Background()
+	if _, err := w.index(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	job := claim(t, w, jobs, doc)
+	kb := uint(5)
+	fake.beforeUpsert = func() {
+		// 管理员修改标签并移入知识库，分发器立即应用了 metadata_updated 事件
+		docs.mu.Lock()
+		stored := docs.docs[3]
+		stored.Tags = []string{"糖尿病"}
+		stored.KnowledgeBaseID = &kb
+		current := *stored
+		docs.mu.Unlock()
+		if err := rag.UpdateDocumentMetadata(ctx, &current); err != nil {
+			t.Error(err)
+		}
+	}
+	w.process(ctx, job)
+
+	if fake.beforeUpsert != nil {
+		t.Fatal("metadata update was not interleaved with the index job")
+	}
+	if got := docs.get(3); got.Status != models.DocumentStatusReady {
+		t.Errorf("document status = %q, want ready", got.Status)
+	}
+	if stored := jobs.job(job.ID); stored.Status != models.IndexJobStatusDone {
+		t.Errorf("job status = %q, want done", stored.Status)
+	}
+	if len(fake.records) == 0 {
+		t.Fatal("no chunks stored")
+	}
+	for id, record := range fake.records {
+		if record.metadata["tag:高血压"] == true || record.metadata["tag:糖尿病"] != true {
+			t.Errorf("chunk %s tags = %v", id, record.metadata)
+		}
+		if v, _ := record.metadata["knowledge_base_id"].(float64); v != 5 {
+			t.Errorf("chunk %s knowledge_base_id = %v, want 5", id, record.metadata["knowledge_base_id"])
+		}
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{Tags: []string{"高血压"}}); len(got) != 0 {
+		t.Errorf("removed tag still matches chunks of documents %v", got)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrKnowledgeBaseNotFound 表示知识库不存在或不属于当前用户
+var ErrKnowledgeBaseNotFound = errors.New("knowledge base not found")
+
+// ErrKnowledgeBaseNotEmpty 表示知识库中仍有文档，不能删除
+var ErrKnowledgeBaseNotEmpty = errors.New("knowledge base still contains documents")
+
+// KnowledgeBaseService 包含知识库管理的业务逻辑
+type KnowledgeBaseService struct {
+	kbRepo *repositories.KnowledgeBaseRepository
+}
+
+func NewKnowledgeBaseService(kbRepo *repositories.KnowledgeBaseRepository) *KnowledgeBaseService {
+	return &KnowledgeBaseService{kbRepo: kbRepo}
+}
+
+type KnowledgeBaseRequest struct {
+	Name        string `json:"name" binding:"required,min=1,max=100"`
+	Description string `json:"description" binding:"max=2000"`
+}
+
+func (s *KnowledgeBaseService) Create(userID uint, req *KnowledgeBaseRequest) (*models.KnowledgeBase, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+
+	kb := &models.KnowledgeBase{
+		UserID:      userID,
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+	}
+	if kb.Name == "" {
+		return nil, errors.New("name is empty")
+	}
+	if err := s.kbRepo.Create(kb); err != nil {
+		logger.L.Error("failed to create knowledge base",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.String("name", kb.Name),
+		)
+		return nil, err
+	}
+	return kb, nil
+}
+
+func (s *KnowledgeBaseService) List(userID uint) ([]models.KnowledgeBase, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	return s.kbRepo.ListByUser(userID)
+}
+
+func (s *KnowledgeBaseService) Get(userID, kbID uint) (*models.KnowledgeBase, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	return s.kbRepo.GetByIDAndUser(kbID, userID)
+}
+
+func (s *KnowledgeBaseService) Update(userID, kbID uint, req *KnowledgeBaseRequest) (*models.KnowledgeBase, error) {
+	kb, err := s.Get(userID, kbID)
+	if err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is empty")
+	}
+	kb.Name = name
+	kb.Description = req.Description
+	if err := s.kbRepo.Update(kb); err != nil {
+		logger.L.Error("failed to update knowledge base",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Uint("knowledge_base_id", kbID),
+		)
+		return nil, err
+	}
+	return kb, nil
+}
+
+// Delete 删除空知识库；仍包含文档时返回 ErrKnowledgeBaseNotEmpty。
+// 计数与删除在同一事务中进行，并对知识库行加排他锁：写入文档的事务对所在知识库加共享锁（见 lockDocumentKnowledgeBase），
+// 两者互斥，不会出现删除后仍有文档指向该知识库的情况
+func (s *KnowledgeBaseService) Delete(userID, kbID uint) error {
+	if userID == 0 {
+		return errors.New("invalid user")
+	}
+
+	err := s.kbRepo.Transaction(func(tx *gorm.DB) error {
+		repo := s.kbRepo.WithTx(tx)
+		if err := repo.LockByIDAndUser(kbID, userID, true); err != nil {
+			return err
+		}
+		count, err := repo.CountDocuments(kbID)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrKnowledgeBaseNotEmpty
+		}
+		return repo.DeleteByIDAndUser(kbID, userID)
+	})
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, ErrKnowledgeBaseNotEmpty) {
+		logger.L.Error("failed to delete knowledge base",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Uint("knowledge_base_id", kbID),
+		)
+	}
+	return err
+}
+
+// lockDocumentKnowledgeBase 在写入文档的事务中对文档所在的知识库加共享锁，并确认它仍属于文档所有者，
+// 防止文档写入一个正在被删除的知识库。文档不属于任何知识库时不做处理
+func lockDocumentKnowledgeBase(kbRepo *repositories.KnowledgeBaseRepository, tx *gorm.DB, doc *models.Document) error {
+	if doc.KnowledgeBaseID == nil {
+		return nil
+	}
+	err := kbRepo.WithTx(tx).LockByIDAndUser(*doc.KnowledgeBaseID, doc.UserID, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrKnowledgeBaseNotFound
+	}
+	return err
+}
+
+// validateKnowledgeBaseOwnership 确认所有知识库都属于该用户
+func validateKnowledgeBaseOwnership(kbRepo *repositories.KnowledgeBaseRepository, userID uint, kbIDs []uint) error {
+	if len(kbIDs) == 0 {
+		return nil
+	}
+	unique := uniqueIDs(kbIDs)
+	count, err := kbRepo.CountOwned(unique, userID)
+	if err != nil {
+		return err
+	}
+	if count != int64(len(unique)) {
+		return ErrKnowledgeBaseNotFound
+	}
+	return nil
+}
+
+// uniqueIDs 去除重复 ID 并保持原有顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]struct{}, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		out = append(out, id)
+	}
+	return out
+}
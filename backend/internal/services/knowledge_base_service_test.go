@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"gorm.io/gorm"
+)
+
+func TestKnowledgeBaseDelete(t *testing.T) {
+	db := openTestDB(t)
+	kbRepo := repositories.NewKnowledgeBaseRepository(db)
+	s := NewKnowledgeBaseService(kbRepo)
+	docs := newTestDocumentService(db)
+
+	empty, err := s.Create(1, &KnowledgeBaseRequest{Name: "儿科"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := s.Create(1, &KnowledgeBaseRequest{Name: "心内科"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := docs.Create(1, models.RoleUser, &CreateDocumentRequest{Title: "指南", Content: "高血压分级", KnowledgeBaseID: &full.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Delete(2, empty.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Delete by another user = %v, want ErrRecordNotFound", err)
+	}
+	if err := s.Delete(1, full.ID); !errors.Is(err, ErrKnowledgeBaseNotEmpty) {
+		t.Errorf("Delete non-empty = %v, want ErrKnowledgeBaseNotEmpty", err)
+	}
+	if err := s.Delete(1, empty.ID); err != nil {
+		t.Errorf("Delete empty = %v", err)
+	}
+	if _, err := s.Get(1, empty.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Get after delete = %v, want ErrRecordNotFound", err)
+	}
+}
+
+// 写入文档的事务持有知识库的共享锁时，删除等待其提交，随后看到新文档而拒绝删除
+func TestKnowledgeBaseDeleteWaitsForDocumentWrite(t *testing.T) {
+	db := openTestDB(t)
+	kbRepo := repositories.NewKnowledgeBaseRepository(db)
+	s := NewKnowledgeBaseService(kbRepo)
+	kb, err := s.Create(1, &KnowledgeBaseRequest{Name: "儿科"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx := db.Begin()
+	doc := &models.Document{UserID: 1, Title: "指南", Content: "正文", Status: models.DocumentStatusPending, Version: 1, KnowledgeBaseID: &kb.ID}
+	if err := lockDocumentKnowledgeBase(kbRepo, tx, doc); err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+	if err := tx.Create(doc).Error; err != nil {
+		tx.Rollback()
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.Delete(1, kb.ID) }()
+	select {
+	case err := <-done:
+		tx.Rollback()
+		t.Fatalf("Delete returned %v while a document write held the knowledge base", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if err := tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; !errors.Is(err, ErrKnowledgeBaseNotEmpty) {
+		t.Errorf("Delete = %v, want ErrKnowledgeBaseNotEmpty", err)
+	}
+}
+
+// 管理员修改他人的共享文档时，知识库按文档所有者校验
+func TestUpdateMetadataKnowledgeBaseOfDocumentOwner(t *testing.T) {
+	db := openTestDB(t)
+	kbs := NewKnowledgeBaseService(repositories.NewKnowledgeBaseRepository(db))
+	s := newTestDocumentService(db)
+
+	ownerKB, err := kbs.Create(1, &KnowledgeBaseRequest{Name: "心内科"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actorKB, err := kbs.Create(2, &KnowledgeBaseRequest{Name: "心内科"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := s.Create(1, models.RoleAdmin, &CreateDocumentRequest{Title: "指南", Content: "高血压分级", Visibility: models.DocumentVisibilityShared})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.UpdateMetadata(2, models.RoleAdmin, doc.ID, &UpdateDocumentMetadataRequest{KnowledgeBaseID: &actorKB.ID})
+	if !errors.Is(err, ErrKnowledgeBaseNotFound) {
+		t.Errorf("move into acting admin's knowledge base = %v, want ErrKnowledgeBaseNotFound", err)
+	}
+	updated, err := s.UpdateMetadata(2, models.RoleAdmin, doc.ID, &UpdateDocumentMetadataRequest{KnowledgeBaseID: &ownerKB.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.KnowledgeBaseID == nil || *updated.KnowledgeBaseID != ownerKB.ID {
+		t.Errorf("knowledge base = %v, want %d", updated.KnowledgeBaseID, ownerKB.ID)
+	}
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 单个 outbox 事件允许运行的最长时间
+const outboxEventTimeout = 2 * time.Minute
+
+// outboxStore 是 OutboxDispatcher 使用的事件队列操作，由 repositories.OutboxRepository 实现
+type outboxStore interface {
+	Update(event *models.OutboxEvent) error
+	ClaimNext(now time.Time) (*models.OutboxEvent, error)
+	RequeueProcessing() (int64, error)
+}
+
+// OutboxDispatcher 按顺序分发文档 outbox 事件，将 MySQL 中已提交的变更应用到向量库。
+// 同一文档的事件按写入顺序逐个应用：较早的事件在退避等待时，该文档之后的事件也不会被领取。
+// 事件处理都是幂等的，失败时以指数退避重试；超过最大尝试次数的事件标记为 failed 并将文档标记为失败，
+// 不再阻塞该文档之后的事件，由对账任务或重新保存文档修复
+type OutboxDispatcher struct {
+	outboxRepo   outboxStore
+	documentRepo indexDocumentStore
+	ragService   *RAGService
+	indexWorker  *IndexWorker
+
+	maxAttempts  int
+	pollInterval time.Duration
+	retryBackoff time.Duration
+
+	wake      chan struct{}
+	startOnce sync.Once
+}
+
+func NewOutboxDispatcher(
+	outboxRepo *repositories.OutboxRepository,
+	documentRepo *repositories.DocumentRepository,
+	ragService *RAGService,
+	indexWorker *IndexWorker,
+	maxAttempts int,
+	pollInterval, retryBackoff time.Duration,
+) *OutboxDispatcher {
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	if pollInterval <= 0 {
+		pollInterval = 5 * time.Second
+	}
+	if retryBackoff <= 0 {
+		retryBackoff = 10 * time.Second
+	}
+	return &OutboxDispatcher{
+		outboxRepo:   outboxRepo,
+		documentRepo: documentRepo,
+		ragService:   ragService,
+		indexWorker:  indexWorker,
+		maxAttempts:  maxAttempts,
+		pollInterval: pollInterval,
+		retryBackoff: retryBackoff,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Notify 在事务提交后调用，立即唤醒分发器而不必等待下一次轮询
+func (d *OutboxDispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start 恢复中断的事件并启动分发循环。使用单个 goroutine 以保持同一文档事件的顺序
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	d.startOnce.Do(func() {
+		requeued, err := d.outboxRepo.RequeueProcessing()
+		if err != nil {
+			logger.L.Error("failed to requeue unfinished outbox events", zap.Error(err))
+		} else if requeued > 0 {
+			logger.L.Info("requeued unfinished outbox events", zap.Int64("count", requeued))
+		}
+
+		go d.run(ctx)
+	})
+}
+
+func (d *OutboxDispatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			event, err := d.outboxRepo.ClaimNext(time.Now())
+			if err != nil {
+				logger.L.Error("failed to claim outbox event", zap.Error(err))
+				break
+			}
+			if event == nil {
+				break
+			}
+			d.dispatch(ctx, event)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *OutboxDispatcher) dispatch(ctx context.Context, event *models.OutboxEvent) {
+	eventCtx, cancel := context.WithTimeout(ctx, outboxEventTimeout)
+	defer cancel()
+
+	if err := d.apply(eventCtx, event); err != nil {
+		if event.Attempts >= d.maxAttempts {
+			d.giveUp(event, err)
+			return
+		}
+		delay := retryDelay(d.retryBackoff, event.Attempts)
+		logger.L.Warn("outbox event failed, will retry",
+			zap.Error(err),
+			zap.Uint("event_id", event.ID),
+			zap.String("event_type", event.EventType),
+			zap.Uint("document_id", event.DocumentID),
+			zap.Int("attempts", event.Attempts),
+			zap.Duration("retry_in", delay),
+		)
+		event.Status = models.OutboxStatusPending
+		event.LastError = err.Error()
+		event.NextRunAt = time.Now().Add(delay)
+		if err := d.outboxRepo.Update(event); err != nil {
+			logger.L.Error("failed to reschedule outbox event",
+				zap.Error(err),
+				zap.Uint("event_id", event.ID),
+			)
+		}
+		return
+	}
+
+	now := time.Now()
+	event.Status = models.OutboxStatusDone
+	event.LastError = ""
+	event.ProcessedAt = &now
+	if err := d.outboxRepo.Update(event); err != nil {
+		logger.L.Error("failed to mark outbox event done",
+			zap.Error(err),
+			zap.Uint("event_id", event.ID),
+		)
+		return
+	}
+
+	logger.L.Info("outbox event dispatched",
+		zap.Uint("event_id", event.ID),
+		zap.String("event_type", event.EventType),
+		zap.Uint("document_id", event.DocumentID),
+	)
+}
+
+// giveUp 将多次失败的事件标记为 failed。文档仍存在时标记为索引失败并记录原因，
+// 提示向量库中的数据可能与 MySQL 不一致
+func (d *OutboxDispatcher) giveUp(event *models.OutboxEvent, cause error) {
+	logger.L.Error("outbox event failed permanently",
+		zap.Error(cause),
+		zap.Uint("event_id", event.ID),
+		zap.String("event_type", event.EventType),
+		zap.Uint("document_id", event.DocumentID),
+		zap.Int("attempts", event.Attempts),
+	)
+	event.Status = models.OutboxStatusFailed
+	event.LastError = cause.Error()
+	if err := d.outboxRepo.Update(event); err != nil {
+		logger.L.Error("failed to mark outbox event failed",
+			zap.Error(err),
+			zap.Uint("event_id", event.ID),
+		)
+	}
+	if event.EventType == models.OutboxEventDocumentDeleted {
+		return
+	}
+	if err := d.documentRepo.UpdateStatus(event.DocumentID, models.DocumentStatusFailed, cause.Error()); err != nil {
+		logger.L.Error("failed to mark document failed",
+			zap.Error(err),
+			zap.Uint("document_id", event.DocumentID),
+		)
+	}
+}
+
+// apply 将事件应用到向量库：
+//   - 创建/更新：交给索引队列，由 worker 覆盖写入新块并删除多余的旧块，重复入队会被去重
+//   - 元数据更新：用文档当前的元数据覆盖各块元数据，不重新嵌入
+//   - 删除：按 document_id 删除 Chroma 中的向量，文档不存在向量时为空操作
+func (d *OutboxDispatcher) apply(ctx context.Context, event *models.OutboxEvent) error {
+	switch event.EventType {
+	case models.OutboxEventDocumentCreated, models.OutboxEventDocumentUpdated:
+		doc, err := d.documentRepo.GetByID(event.DocumentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 文档已被删除，后续的删除事件会清理向量
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load document: %w", err)
+		}
+		return d.indexWorker.Enqueue(doc)
+
+	case models.OutboxEventDocumentMetadataUpdated:
+		doc, err := d.documentRepo.GetByID(event.DocumentID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load document: %w", err)
+		}
+		return d.ragService.UpdateDocumentMetadata(ctx, doc)
+
+	case models.OutboxEventDocumentDeleted:
+		return d.ragService.DeleteDocument(ctx, event.DocumentID, event.UserID)
+
+	default:
+		logger.L.Warn("unknown outbox event type, skipping",
+			zap.Uint("event_id", event.ID),
+			zap.String("event_type", event.EventType),
+		)
+		return nil
+	}
+}
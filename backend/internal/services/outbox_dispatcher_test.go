@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+)
+
+// fakeOutboxStore 是内存中的 outbox 表，ClaimNext 的领取规则与 OutboxRepository.ClaimNext 相同：
+// 按 ID 顺序领取到期的 pending 事件，同一文档有更早的未完成（非 done/failed）事件时跳过
+type fakeOutboxStore struct {
+	mu     sync.Mutex
+	events []*models.OutboxEvent
+}
+
+func (s *fakeOutboxStore) add(eventType string, documentID, userID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event := newDocumentOutboxEvent(eventType, documentID, userID)
+	event.ID = uint(len(s.events) + 1)
+	s.events = append(s.events, event)
+}
+
+func (s *fakeOutboxStore) Update(event *models.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *event
+	s.events[event.ID-1] = &copied
+	return nil
+}
+
+func (s *fakeOutboxStore) ClaimNext(now time.Time) (*models.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blocked := make(map[uint]bool)
+	for _, event := range s.events {
+		if event.Status == models.OutboxStatusDone || event.Status == models.OutboxStatusFailed {
+			continue
+		}
+		if !blocked[event.DocumentID] && event.Status == models.OutboxStatusPending && !event.NextRunAt.After(now) {
+			event.Status = models.OutboxStatusProcessing
+			event.Attempts++
+			copied := *event
+			return &copied, nil
+		}
+		blocked[event.DocumentID] = true
+	}
+	return nil, nil
+}
+
+func (s *fakeOutboxStore) RequeueProcessing() (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeOutboxStore) event(id uint) models.OutboxEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.events[id-1]
+}
+
+// newTestDispatcher 返回使用内存 outbox 表的分发器，最多尝试 3 次，退避基数 10 秒
+func newTestDispatcher(rag *RAGService, docs *fakeDocumentStore) (*OutboxDispatcher, *fakeOutboxStore, *fakeJobStore) {
+	worker, jobs := newTestWorker(rag, docs)
+	outbox := &fakeOutboxStore{}
+	d := NewOutboxDispatcher(nil, nil, rag, worker, 3, time.Second, 10*time.Second)
+	d.outboxRepo = outbox
+	d.documentRepo = docs
+	return d, outbox, jobs
+}
+
+// drain 像 run 一样领取并分发到 now 为止所有可领取的事件，返回分发的事件 ID
+func drain(t *testing.T, d *OutboxDispatcher, now time.Time) []uint {
+	t.Helper()
+	var ids []uint
+	for {
+		event, err := d.outboxRepo.ClaimNext(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event == nil {
+			return ids
+		}
+		ids = append(ids, event.ID)
+		d.dispatch(context.Background(), event)
+	}
+}
+
+// 创建事件交给索引队列，删除事件清理向量，成功后事件标记为 done
+func TestDispatcherAppliesEvents(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	doc := &models.Document{ID: 1, UserID: 7, Title: "指南", Content: "二甲双胍", Version: 1}
+	docs := newFakeDocumentStore(doc)
+	d, outbox, jobs := newTestDispatcher(rag, docs)
+	if _, err := d.indexWorker.index(context.Background(), &models.Document{ID: 2, UserID: 7, Content: "旧文档", Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	outbox.add(models.OutboxEventDocumentCreated, 1, 7)
+	outbox.add(models.OutboxEventDocumentDeleted, 2, 7)
+	// 文档已不存在时创建事件为空操作
+	outbox.add(models.OutboxEventDocumentCreated, 3, 7)
+	if got := drain(t, d, time.Now()); len(got) != 3 {
+		t.Fatalf("dispatched %v, want 3 events", got)
+	}
+	for id := uint(1); id <= 3; id++ {
+		if event := outbox.event(id); event.Status != models.OutboxStatusDone || event.ProcessedAt == nil {
+			t.Errorf("event %d = %+v, want done", id, event)
+		}
+	}
+	if pending, _ := jobs.HasPending(1); !pending {
+		t.Error("created event did not enqueue an index job")
+	}
+	if pending, _ := jobs.HasPending(3); pending {
+		t.Error("index job enqueued for a missing document")
+	}
+	if len(fake.records) != 0 {
+		t.Errorf("deleted document still has %d chunks", len(fake.records))
+	}
+}
+
+// 失败的事件按退避重试；等待期间同一文档之后的事件不被领取，其他文档不受影响
+func TestDispatcherRetryKeepsDocumentOrder(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	docs := newFakeDocumentStore(
+		&models.Document{ID: 1, UserID: 7, Title: "指南", Content: "二甲双胍", Tags: []string{"糖尿病"}},
+		&models.Document{ID: 2, UserID: 7, Title: "共识", Content: "阿司匹林"},
+	)
+	d, outbox, jobs := newTestDispatcher(rag, docs)
+	outbox.add(models.OutboxEventDocumentMetadataUpdated, 1, 7)
+	outbox.add(models.OutboxEventDocumentDeleted, 1, 7)
+	outbox.add(models.OutboxEventDocumentCreated, 2, 7)
+
+	fake.unavailable = true
+	start := time.Now()
+	if got := drain(t, d, start); len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("dispatched %v, want [1 3]", got)
+	}
+	first := outbox.event(1)
+	if first.Status != models.OutboxStatusPending || first.Attempts != 1 || first.LastError == "" {
+		t.Errorf("failed event = %+v, want pending for retry", first)
+	}
+	if wait := first.NextRunAt.Sub(start); wait < 10*time.Second || wait > 11*time.Second {
+		t.Errorf("retry in %v, want ~10s", wait)
+	}
+	if event := outbox.event(2); event.Status != models.OutboxStatusPending || event.Attempts != 0 {
+		t.Errorf("later event of the same document = %+v, want untouched", event)
+	}
+	if pending, _ := jobs.HasPending(2); !pending {
+		t.Error("other document blocked by the failing event")
+	}
+
+	// 退避结束前不重试
+	if got := drain(t, d, start.Add(5*time.Second)); len(got) != 0 {
+		t.Errorf("dispatched %v before backoff elapsed", got)
+	}
+	fake.unavailable = false
+	if got := drain(t, d, start.Add(time.Minute)); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Fatalf("dispatched %v after recovery, want [1 2]", got)
+	}
+	if event := outbox.event(1); event.Status != models.OutboxStatusDone || event.Attempts != 2 {
+		t.Errorf("retried event = %+v, want done after 2 attempts", event)
+	}
+}
+
+// 超过最大尝试次数后事件标记为 failed、文档标记为失败，之后的事件不再被阻塞
+func TestDispatcherGivesUp(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	docs := newFakeDocumentStore(&models.Document{ID: 1, UserID: 7, Title: "指南", Content: "二甲双胍"})
+	d, outbox, _ := newTestDispatcher(rag, docs)
+	outbox.add(models.OutboxEventDocumentMetadataUpdated, 1, 7)
+	outbox.add(models.OutboxEventDocumentDeleted, 1, 7)
+
+	fake.unavailable = true
+	now := time.Now()
+	for attempt := 1; attempt <= d.maxAttempts; attempt++ {
+		// 每次等到退避结束后只领取一次
+		now = now.Add(time.Hour)
+		event, err := d.outboxRepo.ClaimNext(now)
+		if err != nil || event == nil || event.ID != 1 {
+			t.Fatalf("attempt %d: claimed %+v, %v; want event 1", attempt, event, err)
+		}
+		d.dispatch(context.Background(), event)
+	}
+	event := outbox.event(1)
+	if event.Status != models.OutboxStatusFailed || event.Attempts != d.maxAttempts || event.LastError == "" {
+		t.Errorf("event = %+v, want failed after %d attempts", event, d.maxAttempts)
+	}
+	if doc := docs.get(1); doc.Status != models.DocumentStatusFailed || doc.ErrorMessage == "" {
+		t.Errorf("document status %q error %q, want failed", doc.Status, doc.ErrorMessage)
+	}
+
+	fake.unavailable = false
+	if got := drain(t, d, now.Add(time.Hour)); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("dispatched %v after giving up, want the delete event", got)
+	}
+	if event := outbox.event(2); event.Status != models.OutboxStatusDone {
+		t.Errorf("delete event = %+v, want done", event)
+	}
+}
+
+// 文档写入与 outbox 事件在同一事务中提交，事务失败时两者都不落库；
+// 提交后的事件由分发器按顺序交给索引队列
+func TestDocumentWritesOutboxInTransaction(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestDocumentService(db)
+	eventTypes := func(docID uint) []string {
+		var events []models.OutboxEvent
+		if err := db.Where("document_id = ?", docID).Order("id asc").Find(&events).Error; err != nil {
+			t.Fatal(err)
+		}
+		types := make([]string, len(events))
+		for i, event := range events {
+			types[i] = event.EventType
+		}
+		return types
+	}
+
+	doc, err := s.Create(7, models.RoleUser, &CreateDocumentRequest{Title: "指南", Content: "二甲双胍起始剂量 500 mg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []string{"糖尿病"}
+	if _, err := s.UpdateMetadata(7, models.RoleUser, doc.ID, &UpdateDocumentMetadataRequest{Tags: &tags}); err != nil {
+		t.Fatal(err)
+	}
+	if got := eventTypes(doc.ID); len(got) != 2 || got[0] != models.OutboxEventDocumentCreated || got[1] != models.OutboxEventDocumentMetadataUpdated {
+		t.Errorf("events = %v", got)
+	}
+
+	// 分发器领取创建事件并入队索引任务；元数据事件排在其后
+	jobRepo := repositories.NewIndexJobRepository(db)
+	documentRepo := repositories.NewDocumentRepository(db)
+	worker := NewIndexWorker(jobRepo, documentRepo, nil, nil, 1, 1, 0, 0)
+	d := NewOutboxDispatcher(repositories.NewOutboxRepository(db), documentRepo, nil, worker, 3, 0, 0)
+	if got := drain(t, d, time.Now().Add(time.Second)); len(got) != 2 {
+		t.Fatalf("dispatched %v, want 2 events", got)
+	}
+	if pending, err := jobRepo.HasPending(doc.ID); err != nil || !pending {
+		t.Errorf("HasPending = %v, %v; want an index job", pending, err)
+	}
+
+	if err := s.Delete(7, models.RoleUser, doc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := eventTypes(doc.ID); len(got) != 3 || got[2] != models.OutboxEventDocumentDeleted {
+		t.Errorf("events after delete = %v", got)
+	}
+
+	// outbox 写入失败时文档和版本快照一起回滚
+	if err := db.Migrator().DropTable(&models.OutboxEvent{}); err != nil {
+		t.Fatal(err)
+	}
+	defer db.AutoMigrate(&models.OutboxEvent{})
+	if _, err := s.Create(7, models.RoleUser, &CreateDocumentRequest{Title: "回滚", Content: "不应保存"}); err == nil {
+		t.Fatal("Create succeeded without outbox table")
+	}
+	var count int64
+	db.Model(&models.Document{}).Where("title = ?", "回滚").Count(&count)
+	if count != 0 {
+		t.Errorf("document committed without its outbox event")
+	}
+	db.Model(&models.DocumentVersion{}).Where("title = ?", "回滚").Count(&count)
+	if count != 0 {
+		t.Errorf("version snapshot committed without its outbox event")
+	}
+}
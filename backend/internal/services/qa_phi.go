@@ -0,0 +1,180 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/phi"
+
+	"go.uber.org/zap"
+)
+
+// 提问中身份信息的处理策略
+const (
+	QuestionPHIPolicyOff   = "off"   // 原样发送
+	QuestionPHIPolicyMask  = "mask"  // 替换为 [姓名1] 等占位符，同一对话中同一值的占位符不变
+	QuestionPHIPolicyBlock = "block" // 拒绝包含身份信息的提问
+)
+
+// 对话的占位符状态只保存在内存中，闲置超过该时长即丢弃
+const (
+	questionConversationTTL  = 2 * time.Hour
+	maxQuestionConversations = 10000
+)
+
+// QuestionPHIError 表示 block 策略下提问包含身份信息，Kinds 为识别出的类别
+type QuestionPHIError struct {
+	Kinds []string
+}
+
+func (e *QuestionPHIError) Error() string {
+	return "question contains personal identifiers (" + strings.Join(e.Kinds, ", ") + "), remove them and ask again"
+}
+
+// QuestionRedaction 说明提问中被替换的身份信息，Question 为实际用于检索和发送给模型的文本
+type QuestionRedaction struct {
+	Question string         `json:"question"`
+	Total    int            `json:"total"`
+	Counts   map[string]int `json:"counts"`
+	Findings []phi.Finding  `json:"findings"`
+}
+
+// preparedQuestion 是处理身份信息后的提问
+type preparedQuestion struct {
+	text           string
+	conversationID string
+	redaction      *QuestionRedaction // 没有替换时为 nil
+}
+
+// prepareQuestion 按策略处理提问中的身份信息。mask 策略下未指定对话时生成新的对话 ID，
+// 客户端在后续提问中带上它即可让同一值沿用相同的占位符
+func (s *QAService) prepareQuestion(userID uint, req *AskRequest, question string) (*preparedQuestion, error) {
+	prepared := &preparedQuestion{text: question, conversationID: req.ConversationID}
+	if s.opts.QuestionPHIPolicy == QuestionPHIPolicyOff || !s.questionRedactor.Enabled() {
+		return prepared, nil
+	}
+
+	if s.opts.QuestionPHIPolicy == QuestionPHIPolicyBlock {
+		_, report := s.questionRedactor.Redact(question)
+		if report.Total() == 0 {
+			return prepared, nil
+		}
+		logger.L.Warn("question rejected for personal identifiers",
+			zap.Uint("user_id", userID),
+			zap.Any("counts", report.Counts),
+		)
+		return nil, &QuestionPHIError{Kinds: reportKinds(report)}
+	}
+
+	if prepared.conversationID == "" {
+		id, err := newConversationID()
+		if err != nil {
+			return nil, err
+		}
+		prepared.conversationID = id
+	}
+	text, report := s.conversations.redact(userID, prepared.conversationID, question)
+	if report.Total() == 0 {
+		return prepared, nil
+	}
+	logger.L.Info("question redacted",
+		zap.Uint("user_id", userID),
+		zap.String("conversation_id", prepared.conversationID),
+		zap.Int("total", report.Total()),
+		zap.Any("counts", report.Counts),
+	)
+	prepared.text = text
+	prepared.redaction = &QuestionRedaction{
+		Question: text,
+		Total:    report.Total(),
+		Counts:   report.Counts,
+		Findings: report.Findings,
+	}
+	return prepared, nil
+}
+
+// reportKinds 返回报告中出现的类别，按名称排序
+func reportKinds(report *phi.Report) []string {
+	kinds := make([]string, 0, len(report.Counts))
+	for kind := range report.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func newConversationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate conversation id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// conversationStore 按用户和对话保存处理会话，使同一对话中的占位符编号保持一致
+type conversationStore struct {
+	redactor *phi.Redactor
+	mu       sync.Mutex
+	items    map[string]*conversationEntry
+}
+
+type conversationEntry struct {
+	mu       sync.Mutex
+	session  *phi.Session
+	lastUsed time.Time
+}
+
+func newConversationStore(redactor *phi.Redactor) *conversationStore {
+	return &conversationStore{redactor: redactor, items: make(map[string]*conversationEntry)}
+}
+
+// redact 在对话的会话中处理文本；对话 ID 按用户隔离，其他用户无法沿用同一会话
+func (c *conversationStore) redact(userID uint, conversationID, text string) (string, *phi.Report) {
+	entry := c.acquire(fmt.Sprintf("%d:%s", userID, conversationID))
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	return entry.session.Redact(text)
+}
+
+func (c *conversationStore) acquire(key string) *conversationEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entry, ok := c.items[key]
+	if ok && now.Sub(entry.lastUsed) > questionConversationTTL {
+		ok = false
+	}
+	if !ok {
+		if len(c.items) >= maxQuestionConversations {
+			c.evict(now)
+		}
+		entry = &conversationEntry{session: c.redactor.NewSession()}
+		c.items[key] = entry
+	}
+	entry.lastUsed = now
+	return entry
+}
+
+// evict 丢弃过期的对话，仍然已满时丢弃最久未使用的一个
+func (c *conversationStore) evict(now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range c.items {
+		if now.Sub(entry.lastUsed) > questionConversationTTL {
+			delete(c.items, key)
+			continue
+		}
+		if oldestKey == "" || entry.lastUsed.Before(oldest) {
+			oldestKey, oldest = key, entry.lastUsed
+		}
+	}
+	if len(c.items) >= maxQuestionConversations {
+		delete(c.items, oldestKey)
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"testing"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/phi"
+
+	"go.uber.org/zap"
+)
+
+func newTestQAService(t *testing.T, policy string) *QAService {
+	t.Helper()
+	logger.L = zap.NewNop()
+	redactor, err := phi.NewRedactor(phi.ModePseudonymize, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewQAService("", "test-model", "", nil, nil, nil, redactor, QAOptions{QuestionPHIPolicy: policy})
+}
+
+func TestPrepareQuestionClinicalPhrasingUnchanged(t *testing.T) {
+	s := newTestQAService(t, QuestionPHIPolicyMask)
+	for _, question := range []string{
+		"现住院的糖尿病患者如何调整胰岛素",
+		"对于现住院治疗的患者，低血糖如何处理",
+		"家住附近的高血压患者多久随访一次",
+	} {
+		prepared, err := s.prepareQuestion(1, &AskRequest{}, question)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prepared.text != question || prepared.redaction != nil {
+			t.Errorf("prepareQuestion(%q) = %q, want unchanged", question, prepared.text)
+		}
+	}
+}
+
+func TestPrepareQuestionConsistentWithinConversation(t *testing.T) {
+	s := newTestQAService(t, QuestionPHIPolicyMask)
+	first, err := s.prepareQuestion(1, &AskRequest{}, "患者张三，男，45岁，电话13812345678，血红蛋白偏低怎么办")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.conversationID == "" {
+		t.Fatal("expected a generated conversation id")
+	}
+	if first.text != "患者[姓名1]，男，45岁，电话[电话1]，血红蛋白偏低怎么办" {
+		t.Errorf("first = %q", first.text)
+	}
+
+	second, _ := s.prepareQuestion(1, &AskRequest{ConversationID: first.conversationID}, "张三需要复查吗，新电话13900001111")
+	if second.text != "[姓名1]需要复查吗，新电话[电话2]" {
+		t.Errorf("second = %q", second.text)
+	}
+
+	// 其他用户使用相同的对话 ID 不会沿用该会话
+	other, _ := s.prepareQuestion(2, &AskRequest{ConversationID: first.conversationID}, "张三需要复查吗")
+	if other.text != "张三需要复查吗" {
+		t.Errorf("other user = %q", other.text)
+	}
+}
+
+func TestPrepareQuestionBlock(t *testing.T) {
+	s := newTestQAService(t, QuestionPHIPolicyBlock)
+	_, err := s.prepareQuestion(1, &AskRequest{}, "身份证号110105199003071239，电话13812345678")
+	phiErr, ok := err.(*QuestionPHIError)
+	if !ok {
+		t.Fatalf("err = %v, want *QuestionPHIError", err)
+	}
+	if len(phiErr.Kinds) != 2 || phiErr.Kinds[0] != phi.KindIDCard || phiErr.Kinds[1] != phi.KindPhone {
+		t.Errorf("kinds = %v", phiErr.Kinds)
+	}
+	if _, err := s.prepareQuestion(1, &AskRequest{}, "现住院的糖尿病患者如何调整胰岛素"); err != nil {
+		t.Errorf("clinical question blocked: %v", err)
+	}
+}
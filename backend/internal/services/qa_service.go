@@ -6,8 +6,14 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"medical-qa-assistant/internal/extractor"
 	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/phi"
+	"medical-qa-assistant/internal/repositories"
 
 	openai "github.com/sashabaranov/go-openai"
 	"go.uber.org/zap"
@@ -15,48 +21,144 @@ import (
 
 // QAService 通过云 LLM 提供商处理问答，并在可用时集成 RAG
 type QAService struct {
-	client *openai.Client
-	model  string
-	rag    *RAGService
+	client       *openai.Client
+	model        string
+	rag          *RAGService
+	kbRepo       *repositories.KnowledgeBaseRepository
+	documentRepo *repositories.DocumentRepository
+	opts         QAOptions
+
+	// 提问在检索和发送给模型前按 opts.QuestionPHIPolicy 处理身份信息
+	questionRedactor *phi.Redactor
+	conversations    *conversationStore
 }
 
-func NewQAService(apiKey, model, baseURL string, rag *RAGService) *QAService {
+// 已被取代文档的处理策略
+const (
+	SupersededPolicyDownrank = "downrank" // 排在其他片段之后
+	SupersededPolicyExclude  = "exclude"  // 不作为上下文
+)
+
+// 每次提问使用的文档片段数
+const retrievalTopK = 5
+
+// QAOptions 是问答上下文构建的可调参数
+type QAOptions struct {
+	// 只选定一篇文档且其长度（字符数）不超过该值时，直接使用全文作为上下文
+	FullDocumentMaxChars int
+	// SupersededPolicy 为 downrank 或 exclude
+	SupersededPolicy string
+	// 文档发布超过该年数时，在提示词中注明发布日期并提醒时效性，0 表示不提醒
+	StaleDocumentAgeYears int
+	// QuestionPHIPolicy 为 off、mask 或 block
+	QuestionPHIPolicy string
+}
+
+func NewQAService(
+	apiKey, model, baseURL string,
+	rag *RAGService,
+	kbRepo *repositories.KnowledgeBaseRepository,
+	documentRepo *repositories.DocumentRepository,
+	questionRedactor *phi.Redactor,
+	opts QAOptions,
+) *QAService {
+	if opts.SupersededPolicy != SupersededPolicyExclude {
+		opts.SupersededPolicy = SupersededPolicyDownrank
+	}
+	// 未知取值按 mask 处理，配置写错时不会把身份信息原样发出
+	if opts.QuestionPHIPolicy != QuestionPHIPolicyOff && opts.QuestionPHIPolicy != QuestionPHIPolicyBlock {
+		opts.QuestionPHIPolicy = QuestionPHIPolicyMask
+	}
+	svc := &QAService{
+		model:            model,
+		rag:              rag,
+		kbRepo:           kbRepo,
+		documentRepo:     documentRepo,
+		opts:             opts,
+		questionRedactor: questionRedactor,
+		conversations:    newConversationStore(questionRedactor),
+	}
 	if apiKey == "" {
 		// 保持客户端为 nil；Ask 将返回明确的错误
-		return &QAService{model: model, rag: rag}
+		return svc
 	}
 	cfg := openai.DefaultConfig(apiKey)
 	if baseURL != "" {
 		cfg.BaseURL = baseURL
 	}
-	return &QAService{
-		client: openai.NewClientWithConfig(cfg),
-		model:  model,
-		rag:    rag,
-	}
+	svc.client = openai.NewClientWithConfig(cfg)
+	return svc
 }
 
 type AskRequest struct {
 	Question string `json:"question" binding:"required,min=1"`
+	// ConversationID 标识一次对话，同一对话中同一身份信息总是替换为相同的占位符；
+	// 为空时服务端生成并在响应中返回
+	ConversationID string `json:"conversation_id" binding:"omitempty,max=64"`
+
+	// KnowledgeBaseIDs 限定只从这些知识库中检索，为空时检索全部可访问文档
+	KnowledgeBaseIDs []uint `json:"knowledge_base_ids"`
+	// DocumentIDs 限定只从这些文档中检索，例如“根据这份指南……”
+	DocumentIDs []uint `json:"document_ids"`
+
+	// 按文档元数据过滤：命中任一标签、类别、出版年份范围
+	Tags     []string `json:"tags"`
+	Category string   `json:"category" binding:"omitempty,oneof=guideline textbook drug_label paper note"`
+	YearFrom int      `json:"year_from" binding:"omitempty,min=0"`
+	YearTo   int      `json:"year_to" binding:"omitempty,min=0"`
 }
 
 type AskResponse struct {
-	Answer string `json:"answer"`
+	Answer         string             `json:"answer"`
+	Sources        []Source           `json:"sources"`
+	ConversationID string             `json:"conversation_id,omitempty"`
+	Redaction      *QuestionRedaction `json:"redaction,omitempty"` // 提问中有身份信息被替换时返回
+}
+
+// Source 是回答引用的文档片段，Index 与提示词中的【片段 N】编号一致
+type Source struct {
+	Index         int    `json:"index"`
+	DocumentID    uint   `json:"document_id"`
+	Title         string `json:"title"`
+	ChunkIndex    int    `json:"chunk_index"`
+	Scope         string `json:"scope"`                    // private, shared
+	WholeDocument bool   `json:"whole_document,omitempty"` // 使用了整篇文档而非检索片段
+	Page          int    `json:"page,omitempty"`           // 片段起始页码，文档未分页时省略
+	PageEnd       int    `json:"page_end,omitempty"`
+	Section       string `json:"section,omitempty"` // 片段所在章节
+	Version       int    `json:"version,omitempty"` // 片段所属的文档版本
+
+	Provenance *models.DocumentProvenance `json:"provenance,omitempty"`
+}
+
+// askScope 是校验后的提问范围
+type askScope struct {
+	filter RetrievalFilter
+	// wholeDocument 非空时直接以整篇文档作为上下文，跳过向量检索
+	wholeDocument *models.Document
 }
 
-func (s *QAService) Ask(ctx context.Context, userID uint, question string) (*AskResponse, error) {
+func (s *QAService) Ask(ctx context.Context, userID uint, req *AskRequest) (*AskResponse, error) {
 	if userID == 0 {
 		return nil, errors.New("invalid user")
 	}
 	if s.client == nil {
 		return nil, errors.New("llm client not configured (missing LLM API key)")
 	}
-	trimmed := strings.TrimSpace(question)
+	trimmed := strings.TrimSpace(req.Question)
 	if trimmed == "" {
 		return nil, errors.New("question is empty")
 	}
+	scope, err := s.resolveScope(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	prepared, err := s.prepareQuestion(userID, req, trimmed)
+	if err != nil {
+		return nil, err
+	}
 
-	messages, err := s.buildMessagesWithContext(ctx, userID, trimmed)
+	messages, sources, err := s.buildMessagesWithContext(ctx, userID, prepared.text, scope)
 	if err != nil {
 		logger.L.Error("failed to build messages with context",
 			zap.Error(err),
@@ -92,24 +194,38 @@ func (s *QAService) Ask(ctx context.Context, userID uint, question string) (*Ask
 		zap.Uint("user_id", userID),
 		zap.Int("answer_length", len(answer)),
 	)
-	return &AskResponse{Answer: answer}, nil
+	return &AskResponse{
+		Answer:         answer,
+		Sources:        sources,
+		ConversationID: prepared.conversationID,
+		Redaction:      prepared.redaction,
+	}, nil
 }
 
 // AskStream 通过 SSE 处理流式问答
-// 当数据块到达时，将它们写入提供的写入函数
-func (s *QAService) AskStream(ctx context.Context, userID uint, question string, writeChunk func(string) error) error {
+// 检索完成后先通过 writeSources 输出引用来源、对话 ID 和提问的身份信息处理结果，
+// 之后当数据块到达时，将它们写入提供的写入函数
+func (s *QAService) AskStream(ctx context.Context, userID uint, req *AskRequest, writeSources func([]Source, string, *QuestionRedaction) error, writeChunk func(string) error) error {
 	if userID == 0 {
 		return errors.New("invalid user")
 	}
 	if s.client == nil {
 		return errors.New("llm client not configured (missing LLM API key)")
 	}
-	trimmed := strings.TrimSpace(question)
+	trimmed := strings.TrimSpace(req.Question)
 	if trimmed == "" {
 		return errors.New("question is empty")
 	}
+	scope, err := s.resolveScope(userID, req)
+	if err != nil {
+		return err
+	}
+	prepared, err := s.prepareQuestion(userID, req, trimmed)
+	if err != nil {
+		return err
+	}
 
-	messages, err := s.buildMessagesWithContext(ctx, userID, trimmed)
+	messages, sources, err := s.buildMessagesWithContext(ctx, userID, prepared.text, scope)
 	if err != nil {
 		logger.L.Error("failed to build messages with context (stream)",
 			zap.Error(err),
@@ -117,15 +233,18 @@ func (s *QAService) AskStream(ctx context.Context, userID uint, question string,
 		)
 		return err
 	}
+	if err := writeSources(sources, prepared.conversationID, prepared.redaction); err != nil {
+		return fmt.Errorf("failed to write sources: %w", err)
+	}
 
-	req := openai.ChatCompletionRequest{
+	chatReq := openai.ChatCompletionRequest{
 		Model:       s.model,
 		Messages:    messages,
 		Temperature: 0.2,
 		Stream:      true,
 	}
 
-	stream, err := s.client.CreateChatCompletionStream(ctx, req)
+	stream, err := s.client.CreateChatCompletionStream(ctx, chatReq)
 	if err != nil {
 		logger.L.Error("failed to create LLM stream",
 			zap.Error(err),
@@ -157,8 +276,46 @@ func (s *QAService) AskStream(ctx context.Context, userID uint, question string,
 	}
 }
 
-// buildMessagesWithContext 构建聊天消息，包括在启用 RAG 时检索到的文档上下文
-func (s *QAService) buildMessagesWithContext(ctx context.Context, userID uint, question string) ([]openai.ChatCompletionMessage, error) {
+// resolveScope 校验请求中的知识库和文档范围，并转换为 RAG 过滤条件
+func (s *QAService) resolveScope(userID uint, req *AskRequest) (*askScope, error) {
+	scope := &askScope{
+		filter: RetrievalFilter{
+			KnowledgeBaseIDs: uniqueIDs(req.KnowledgeBaseIDs),
+			DocumentIDs:      uniqueIDs(req.DocumentIDs),
+			Category:         req.Category,
+			YearFrom:         req.YearFrom,
+			YearTo:           req.YearTo,
+		},
+	}
+	if err := validateKnowledgeBaseOwnership(s.kbRepo, userID, scope.filter.KnowledgeBaseIDs); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	scope.filter.Tags = tags
+
+	if len(scope.filter.DocumentIDs) > 0 {
+		docs, err := s.documentRepo.ListAccessibleByIDs(scope.filter.DocumentIDs, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) != len(scope.filter.DocumentIDs) {
+			return nil, errors.New("document not found")
+		}
+
+		// 只问一篇短文档时，全文比检索片段更完整，且无需嵌入调用
+		if len(docs) == 1 && s.opts.FullDocumentMaxChars > 0 &&
+			utf8.RuneCountInString(docs[0].Content) <= s.opts.FullDocumentMaxChars {
+			scope.wholeDocument = &docs[0]
+		}
+	}
+	return scope, nil
+}
+
+// buildMessagesWithContext 构建聊天消息，包括在启用 RAG 时检索到的文档上下文，并返回片段对应的引用来源
+func (s *QAService) buildMessagesWithContext(ctx context.Context, userID uint, question string, scope *askScope) ([]openai.ChatCompletionMessage, []Source, error) {
 	// 默认系统提示词
 	systemPrompt := `
 	你是一名专业、谨慎的医学问答助手，仅用于提供医学知识层面的信息支持。
@@ -181,36 +338,82 @@ func (s *QAService) buildMessagesWithContext(ctx context.Context, userID uint, q
 	- 避免绝对化表述（如“一定”“必须”“完全治愈”）
 	- 必要时提醒用户咨询专业医生
 
+	- 若引用的文档标注为已被取代、已过复审日期或发布较早，应提醒用户注意其时效性
+
 	你的目标是：**在保证安全与准确的前提下，帮助用户理解医学问题，而不是替代医生。**
 	`
 
-	var contextText string
-	if s.rag != nil && s.rag.IsEnabled() {
-		chunks, err := s.rag.RetrieveRelevantChunks(ctx, userID, question, 5)
+	var chunks []models.Chunk
+	if doc := scope.wholeDocument; doc != nil {
+		chunks = []models.Chunk{{
+			DocumentID: doc.ID,
+			UserID:     doc.UserID,
+			Content:    strings.ReplaceAll(doc.Content, extractor.PageBreak, "\n"),
+			Title:      doc.Title,
+			Visibility: documentVisibility(doc),
+			Version:    doc.Version,
+		}}
+	} else if s.rag != nil && s.rag.IsEnabled() {
+		// 多取一些候选片段，剔除或后移已被取代的文档后仍能凑满 retrievalTopK 个
+		var err error
+		chunks, err = s.rag.RetrieveRelevantChunks(ctx, userID, question, retrievalTopK*2, scope.filter)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if len(chunks) > 0 {
-			var sb strings.Builder
-			sb.WriteString(`
-			以下是与用户问题相关的医学文档片段（可能来自指南、教材或医学资料）：
+	}
+	docs := s.sourceDocuments(userID, chunks, scope)
+	if scope.wholeDocument == nil {
+		chunks = s.rankBySupersession(chunks, docs)
+	}
 
-			请严格按照以下规则回答：
-			1. 回答时必须优先基于文档片段中的信息进行推理和总结；
-			2. 如果文档片段无法直接回答问题，可补充通用医学知识；
-			3. 如果无法基于可靠医学信息回答，请明确说明“文档和医学常识均不足以支持明确结论”；
-			4. 禁止编造文档中不存在的结论或数据；
-			5. 回答应保持医学审慎性，避免诊断式或处方式表述。
+	var contextText string
+	sources := []Source{}
+	if len(chunks) > 0 {
+		var sb strings.Builder
+		sb.WriteString(`
+		以下是与用户问题相关的医学文档片段（可能来自指南、教材或医学资料）：
+
+		请严格按照以下规则回答：
+		1. 回答时必须优先基于文档片段中的信息进行推理和总结；
+		2. 如果文档片段无法直接回答问题，可补充通用医学知识；
+		3. 如果无法基于可靠医学信息回答，请明确说明“文档和医学常识均不足以支持明确结论”；
+		4. 禁止编造文档中不存在的结论或数据；
+		5. 回答应保持医学审慎性，避免诊断式或处方式表述。
 
-			医学文档片段如下：
-			`)
+		医学文档片段如下：
+		`)
 
-			for i, ch := range chunks {
-				sb.WriteString(fmt.Sprintf("【片段 %d】:\n%s\n\n", i+1, ch.Content))
+		now := time.Now()
+		for i, ch := range chunks {
+			doc := docs[ch.DocumentID]
+			label := describeSource(ch, doc)
+			if notes := s.freshnessNotes(doc, docs, now); len(notes) > 0 {
+				label += "；" + strings.Join(notes, "，")
+			}
+			if scope.wholeDocument != nil {
+				sb.WriteString(fmt.Sprintf("【片段 %d】（文档全文，%s）:\n%s\n\n", i+1, label, ch.Content))
+			} else {
+				sb.WriteString(fmt.Sprintf("【片段 %d】（%s）:\n%s\n\n", i+1, label, ch.Content))
 			}
-			sb.WriteString("回答时请：\n- 优先基于上述片段中的信息进行推理；\n- 如果文档中没有足够信息，可以查找网上相关的医学知识，但是请记住不要编造；\n- 用中文回答。\n")
-			contextText = sb.String()
+			source := Source{
+				Index:         i + 1,
+				DocumentID:    ch.DocumentID,
+				Title:         ch.Title,
+				ChunkIndex:    ch.Index,
+				Scope:         ch.Visibility,
+				WholeDocument: scope.wholeDocument != nil,
+				Page:          ch.Page,
+				PageEnd:       ch.PageEnd,
+				Section:       ch.Section,
+				Version:       ch.Version,
+			}
+			if doc != nil {
+				source.Provenance = &doc.Provenance
+			}
+			sources = append(sources, source)
 		}
+		sb.WriteString("回答时请：\n- 优先基于上述片段中的信息进行推理；\n- 如果文档中没有足够信息，可以查找网上相关的医学知识，但是请记住不要编造；\n- 用中文回答。\n")
+		contextText = sb.String()
 	}
 
 	systemContent := systemPrompt
@@ -228,5 +431,157 @@ func (s *QAService) buildMessagesWithContext(ctx context.Context, userID uint, q
 			Content: question,
 		},
 	}
-	return messages, nil
+	return messages, sources, nil
+}
+
+// sourceDocuments 加载片段所属文档及取代它们的新文档，用于在引用中附带出处和时效信息。
+// 只加载用户可访问的文档，取代文档是他人的私有文档时不出现其标题。
+// 加载失败不影响回答，只是引用中缺少这些信息
+func (s *QAService) sourceDocuments(userID uint, chunks []models.Chunk, scope *askScope) map[uint]*models.Document {
+	docs := make(map[uint]*models.Document)
+	ids := make([]uint, 0, len(chunks)*2)
+	if doc := scope.wholeDocument; doc != nil {
+		docs[doc.ID] = doc
+		if doc.SupersededByID != nil {
+			ids = append(ids, *doc.SupersededByID)
+		}
+	} else {
+		for _, ch := range chunks {
+			ids = append(ids, ch.DocumentID)
+		}
+	}
+	if len(ids) == 0 {
+		return docs
+	}
+
+	if err := s.loadDocuments(userID, uniqueIDs(ids), docs); err != nil {
+		return docs
+	}
+	var successors []uint
+	for _, doc := range docs {
+		if doc.SupersededByID != nil {
+			if _, ok := docs[*doc.SupersededByID]; !ok {
+				successors = append(successors, *doc.SupersededByID)
+			}
+		}
+	}
+	if len(successors) > 0 {
+		_ = s.loadDocuments(userID, uniqueIDs(successors), docs)
+	}
+	return docs
+}
+
+func (s *QAService) loadDocuments(userID uint, ids []uint, into map[uint]*models.Document) error {
+	loaded, err := s.documentRepo.ListAccessibleByIDs(ids, userID)
+	if err != nil {
+		logger.L.Warn("failed to load source documents",
+			zap.Error(err),
+		)
+		return err
+	}
+	for i := range loaded {
+		into[loaded[i].ID] = &loaded[i]
+	}
+	return nil
+}
+
+// rankBySupersession 按策略处理已被取代文档的片段（排除或稳定地后移），并截取前 retrievalTopK 个
+func (s *QAService) rankBySupersession(chunks []models.Chunk, docs map[uint]*models.Document) []models.Chunk {
+	current := make([]models.Chunk, 0, len(chunks))
+	var superseded []models.Chunk
+	for _, ch := range chunks {
+		if doc := docs[ch.DocumentID]; doc != nil && doc.SupersededByID != nil {
+			superseded = append(superseded, ch)
+			continue
+		}
+		current = append(current, ch)
+	}
+	if s.opts.SupersededPolicy == SupersededPolicyDownrank {
+		current = append(current, superseded...)
+	}
+	if len(current) > retrievalTopK {
+		current = current[:retrievalTopK]
+	}
+	return current
+}
+
+// freshnessNotes 返回文档的时效提示：已被取代、已过复审日期、发布时间早于配置年限
+func (s *QAService) freshnessNotes(doc *models.Document, docs map[uint]*models.Document, now time.Time) []string {
+	if doc == nil {
+		return nil
+	}
+	var notes []string
+	if doc.SupersededByID != nil {
+		if successor := docs[*doc.SupersededByID]; successor != nil {
+			notes = append(notes, fmt.Sprintf("已被《%s》取代", successor.Title))
+		} else {
+			notes = append(notes, "已被新版本取代")
+		}
+	}
+	if doc.ReviewBy != nil && doc.ReviewBy.Before(now) {
+		notes = append(notes, fmt.Sprintf("已过复审日期 %s", doc.ReviewBy.Format("2006-01-02")))
+	}
+	if s.opts.StaleDocumentAgeYears > 0 {
+		if published, label := documentDate(doc); !published.IsZero() &&
+			published.AddDate(s.opts.StaleDocumentAgeYears, 0, 0).Before(now) {
+			notes = append(notes, fmt.Sprintf("发布于 %s，已超过 %d 年，内容可能已过时", label, s.opts.StaleDocumentAgeYears))
+		}
+	}
+	return notes
+}
+
+// documentDate 返回文档的发布日期及其展示文本，优先使用出处中的发布日期，其次为出版年份
+func documentDate(doc *models.Document) (time.Time, string) {
+	if doc.Provenance.PublishedOn != nil {
+		return *doc.Provenance.PublishedOn, doc.Provenance.PublishedOn.Format("2006-01-02")
+	}
+	if doc.PublicationYear > 0 {
+		return time.Date(doc.PublicationYear, time.January, 1, 0, 0, 0, 0, time.UTC), fmt.Sprintf("%d 年", doc.PublicationYear)
+	}
+	return time.Time{}, ""
+}
+
+// describeSource 生成片段的出处说明，如“来源：《指南》第 12 页，中华医学会，2023-05-01 发布，第 2 版，文档版本 v3，共享知识库”
+func describeSource(ch models.Chunk, doc *models.Document) string {
+	parts := []string{fmt.Sprintf("来源：《%s》%s", ch.Title, pageLabel(ch.Page, ch.PageEnd))}
+	if ch.Section != "" {
+		parts[0] += fmt.Sprintf("「%s」", ch.Section)
+	}
+	if doc != nil {
+		p := doc.Provenance
+		if p.Publisher != "" {
+			parts = append(parts, p.Publisher)
+		}
+		if p.PublishedOn != nil {
+			parts = append(parts, p.PublishedOn.Format("2006-01-02")+" 发布")
+		}
+		if p.Edition != "" {
+			parts = append(parts, p.Edition)
+		}
+	}
+	if ch.Version > 0 {
+		parts = append(parts, fmt.Sprintf("文档版本 v%d", ch.Version))
+	}
+	parts = append(parts, scopeLabel(ch.Visibility))
+	return strings.Join(parts, "，")
+}
+
+// pageLabel 返回“第 N 页”或“第 N-M 页”，未分页时为空
+func pageLabel(page, pageEnd int) string {
+	switch {
+	case page <= 0:
+		return ""
+	case pageEnd > page:
+		return fmt.Sprintf("第 %d-%d 页", page, pageEnd)
+	default:
+		return fmt.Sprintf("第 %d 页", page)
+	}
+}
+
+// scopeLabel 返回引用来源范围的中文说明
+func scopeLabel(visibility string) string {
+	if visibility == models.DocumentVisibilityShared {
+		return "共享知识库"
+	}
+	return "个人文档"
 }
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"medical-qa-assistant/internal/models"
+)
+
+func TestFreshnessNotes(t *testing.T) {
+	s := &QAService{opts: QAOptions{StaleDocumentAgeYears: 5}}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	successorID := uint(2)
+	reviewBy := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	doc := &models.Document{ID: 1, SupersededByID: &successorID, ReviewBy: &reviewBy, PublicationYear: 2015}
+
+	// 取代文档不可访问（未加载）时不透露其标题
+	got := s.freshnessNotes(doc, map[uint]*models.Document{1: doc}, now)
+	want := []string{"已被新版本取代", "已过复审日期 2025-06-01", "发布于 2015 年，已超过 5 年，内容可能已过时"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("freshnessNotes() = %q, want %q", got, want)
+	}
+
+	successor := &models.Document{ID: 2, Title: "高血压指南 2024 版"}
+	got = s.freshnessNotes(doc, map[uint]*models.Document{1: doc, 2: successor}, now)
+	if got[0] != "已被《高血压指南 2024 版》取代" {
+		t.Errorf("freshnessNotes()[0] = %q", got[0])
+	}
+}
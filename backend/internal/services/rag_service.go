@@ -4,7 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"medical-qa-assistant/internal/logger"
 	"medical-qa-assistant/internal/models"
@@ -14,17 +17,22 @@ import (
 	"go.uber.org/zap"
 )
 
+// 文档分块的默认长度（字符数）
+const defaultChunkSize = 800
+
 // RAGService 封装了文档分块、嵌入向量生成和使用 Chroma 进行检索的功能
 type RAGService struct {
 	embedClient  *openai.Client
 	embedModel   string
 	chromaClient *chroma.Client
+	queryCache   *embeddingCache
 }
 
 // NewRAGService 创建一个新的 RAGService。如果 apiKey 为空，服务将被禁用
 func NewRAGService(apiKey, baseURL, embedModel, chromaBaseURL, chromaCollection string) *RAGService {
 	rag := &RAGService{
 		embedModel: embedModel,
+		queryCache: newEmbeddingCache(queryEmbeddingCacheSize),
 	}
 
 	if apiKey != "" {
@@ -58,32 +66,37 @@ func (s *RAGService) IsEnabled() bool {
 	return s != nil && s.embedClient != nil
 }
 
-// IndexDocument 对文档进行分块，生成嵌入向量并存储到 Chroma
-func (s *RAGService) IndexDocument(ctx context.Context, doc *models.Document) error {
+// IndexDocument 对文档进行分块，生成嵌入向量并存储到 Chroma，返回写入的块数
+func (s *RAGService) IndexDocument(ctx context.Context, doc *models.Document) (int, error) {
 	if !s.IsEnabled() {
 		logger.L.Info("RAG disabled, skipping document indexing",
 			zap.Uint("document_id", doc.ID),
 			zap.Uint("user_id", doc.UserID),
 		)
-		return nil
+		return 0, nil
 	}
 	if doc == nil || doc.ID == 0 || doc.UserID == 0 {
-		return errors.New("invalid document for indexing")
+		return 0, errors.New("invalid document for indexing")
 	}
 
-	chunks := chunkText(doc.Content, 800) // 简单的基于字符的分块
+	chunks := chunkText(doc.Content, defaultChunkSize) // 按章节和长度分块，保留页码
 	if len(chunks) == 0 {
 		logger.L.Info("no chunks generated for document, skipping indexing",
 			zap.Uint("document_id", doc.ID),
 			zap.Uint("user_id", doc.UserID),
 		)
-		return nil
+		return 0, nil
+	}
+
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.text
 	}
 
 	// 批量生成嵌入向量
 	resp, err := s.embedClient.CreateEmbeddings(ctx, openai.EmbeddingRequest{
 		Model: openai.EmbeddingModel(s.embedModel),
-		Input: chunks,
+		Input: texts,
 	})
 	if err != nil {
 		logger.L.Error("failed to create embeddings for document",
@@ -92,10 +105,10 @@ func (s *RAGService) IndexDocument(ctx context.Context, doc *models.Document) er
 			zap.Uint("user_id", doc.UserID),
 			zap.Int("chunk_count", len(chunks)),
 		)
-		return fmt.Errorf("failed to create embeddings: %w", err)
+		return 0, fmt.Errorf("failed to create embeddings: %w", err)
 	}
 	if len(resp.Data) != len(chunks) {
-		return fmt.Errorf("embeddings count mismatch: got %d, want %d", len(resp.Data), len(chunks))
+		return 0, fmt.Errorf("embeddings count mismatch: got %d, want %d", len(resp.Data), len(chunks))
 	}
 
 	// 准备 Chroma 数据
@@ -108,15 +121,29 @@ func (s *RAGService) IndexDocument(ctx context.Context, doc *models.Document) er
 		// 生成唯一 ID：document_id-chunk_index-user_id
 		ids[i] = fmt.Sprintf("%d-%d-%d", doc.ID, i, doc.UserID)
 		embeddings[i] = resp.Data[i].Embedding
-		documents[i] = chunk
-		metadatas[i] = map[string]interface{}{
-			"document_id": int(doc.ID),
-			"user_id":     int(doc.UserID),
-			"chunk_index": i,
-			"title":       doc.Title,
+		documents[i] = chunk.text
+		metadatas[i] = documentMetadata(doc)
+		metadatas[i]["chunk_index"] = i
+		metadatas[i]["char_start"] = chunk.start
+		metadatas[i]["char_end"] = chunk.end
+		if chunk.page > 0 {
+			metadatas[i]["page"] = chunk.page
+			metadatas[i]["page_end"] = chunk.pageEnd
+		}
+		if chunk.section != "" {
+			metadatas[i]["section"] = chunk.section
 		}
 	}
 
+	// upsert 会与已有元数据合并：同 ID 旧块上本次未写入的键（已移除的标签、page、section 等）需显式清空
+	existing, err := s.chunkMetadatas(ctx, doc)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		clearMissingKeys(metadatas[i], existing[id])
+	}
+
 	// 存储到 Chroma
 	if err := s.chromaClient.Add(ctx, ids, embeddings, documents, metadatas); err != nil {
 		logger.L.Error("failed to add document chunks to Chroma",
@@ -125,14 +152,65 @@ func (s *RAGService) IndexDocument(ctx context.Context, doc *models.Document) er
 			zap.Uint("user_id", doc.UserID),
 			zap.Int("chunk_count", len(chunks)),
 		)
-		return fmt.Errorf("failed to add documents to Chroma: %w", err)
+		return 0, fmt.Errorf("failed to add documents to Chroma: %w", err)
 	}
 
-	return nil
+	return len(chunks), nil
+}
+
+// chunkMetadatas 返回文档已写入 Chroma 的各块元数据，按块 ID 索引
+func (s *RAGService) chunkMetadatas(ctx context.Context, doc *models.Document) (map[string]map[string]interface{}, error) {
+	resp, err := s.chromaClient.Get(ctx, chroma.GetRequest{
+		Where: map[string]interface{}{
+			"$and": []map[string]interface{}{
+				{"document_id": int(doc.ID)},
+				{"user_id": int(doc.UserID)},
+			},
+		},
+		Include: []string{"metadatas"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get existing document chunks: %w", err)
+	}
+	metadatas := make(map[string]map[string]interface{}, len(resp.IDs))
+	for i, id := range resp.IDs {
+		if i < len(resp.Metadatas) {
+			metadatas[id] = resp.Metadatas[i]
+		}
+	}
+	return metadatas, nil
+}
+
+// clearMissingKeys 为 old 中有而 metadata 中没有的键写入空值：布尔为 false，数值为 0，字符串为空。
+// 读取元数据时这些空值都视为未设置
+func clearMissingKeys(metadata, old map[string]interface{}) {
+	for key, value := range old {
+		if _, ok := metadata[key]; ok {
+			continue
+		}
+		switch value.(type) {
+		case bool:
+			metadata[key] = false
+		case float64:
+			metadata[key] = 0
+		case string:
+			metadata[key] = ""
+		}
+	}
+}
+
+// RetrievalFilter 在默认检索范围（个人文档 + 共享知识库）之上进一步缩小范围，零值表示不额外限制
+type RetrievalFilter struct {
+	KnowledgeBaseIDs []uint
+	DocumentIDs      []uint
+	Tags             []string // 命中任一标签即可
+	Category         string
+	YearFrom         int
+	YearTo           int
 }
 
-// RetrieveRelevantChunks 从 Chroma 返回给定问题和用户的前 k 个相关文档块
-func (s *RAGService) RetrieveRelevantChunks(ctx context.Context, userID uint, question string, topK int) ([]models.Chunk, error) {
+// RetrieveRelevantChunks 从 Chroma 返回给定问题的前 k 个相关文档块，检索范围为用户的个人文档和共享知识库
+func (s *RAGService) RetrieveRelevantChunks(ctx context.Context, userID uint, question string, topK int, filter RetrievalFilter) ([]models.Chunk, error) {
 	if !s.IsEnabled() {
 		logger.L.Info("RAG disabled, skipping retrieval",
 			zap.Uint("user_id", userID),
@@ -150,33 +228,12 @@ func (s *RAGService) RetrieveRelevantChunks(ctx context.Context, userID uint, qu
 		topK = 5
 	}
 
-	// 将问题转换为嵌入向量
-	logger.L.Info("creating question embedding",
-		zap.Uint("user_id", userID),
-		zap.String("model", s.embedModel),
-	)
-	embedResp, err := s.embedClient.CreateEmbeddings(ctx, openai.EmbeddingRequest{
-		Model: openai.EmbeddingModel(s.embedModel),
-		Input: []string{trimmed},
-	})
+	queryVec, err := s.embedQuery(ctx, userID, trimmed)
 	if err != nil {
-		logger.L.Error("failed to create question embedding",
-			zap.Error(err),
-			zap.Uint("user_id", userID),
-			zap.String("model", s.embedModel),
-		)
-		return nil, fmt.Errorf("failed to create question embedding: %w", err)
-	}
-	if len(embedResp.Data) == 0 {
-		return nil, errors.New("no embedding returned for question")
+		return nil, err
 	}
 
-	queryVec := embedResp.Data[0].Embedding
-
-	// 使用用户过滤器查询 Chroma
-	where := map[string]interface{}{
-		"user_id": int(userID),
-	}
+	where := buildRetrievalWhere(userID, filter)
 
 	queryResp, err := s.chromaClient.Query(ctx, queryVec, topK, where)
 	if err != nil {
@@ -209,6 +266,24 @@ func (s *RAGService) RetrieveRelevantChunks(ctx context.Context, userID uint, qu
 		if idx, ok := metadata["chunk_index"].(float64); ok {
 			chunk.Index = int(idx)
 		}
+		if title, ok := metadata["title"].(string); ok {
+			chunk.Title = title
+		}
+		chunk.Visibility = models.DocumentVisibilityPrivate
+		if visibility, ok := metadata["visibility"].(string); ok && visibility != "" {
+			chunk.Visibility = visibility
+		}
+		chunk.Version = int(metadataUint(metadata, "version"))
+		chunk.Page = int(metadataUint(metadata, "page"))
+		chunk.PageEnd = int(metadataUint(metadata, "page_end"))
+		chunk.CharStart = int(metadataUint(metadata, "char_start"))
+		chunk.CharEnd = int(metadataUint(metadata, "char_end"))
+		if section, ok := metadata["section"].(string); ok {
+			chunk.Section = section
+		}
+		if len(queryResp.Distances) > 0 && i < len(queryResp.Distances[0]) {
+			chunk.Distance = queryResp.Distances[0][i]
+		}
 
 		chunks = append(chunks, chunk)
 	}
@@ -216,6 +291,37 @@ func (s *RAGService) RetrieveRelevantChunks(ctx context.Context, userID uint, qu
 	return chunks, nil
 }
 
+// embedQuery 将问题转换为嵌入向量，相同问题优先使用缓存
+func (s *RAGService) embedQuery(ctx context.Context, userID uint, question string) ([]float32, error) {
+	if vec, ok := s.queryCache.get(question); ok {
+		return vec, nil
+	}
+
+	logger.L.Info("creating question embedding",
+		zap.Uint("user_id", userID),
+		zap.String("model", s.embedModel),
+	)
+	embedResp, err := s.embedClient.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Model: openai.EmbeddingModel(s.embedModel),
+		Input: []string{question},
+	})
+	if err != nil {
+		logger.L.Error("failed to create question embedding",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.String("model", s.embedModel),
+		)
+		return nil, fmt.Errorf("failed to create question embedding: %w", err)
+	}
+	if len(embedResp.Data) == 0 {
+		return nil, errors.New("no embedding returned for question")
+	}
+
+	vec := embedResp.Data[0].Embedding
+	s.queryCache.put(question, vec)
+	return vec, nil
+}
+
 // DeleteDocument 从 Chroma 中删除指定文档的所有向量数据
 func (s *RAGService) DeleteDocument(ctx context.Context, docID, userID uint) error {
 	if !s.IsEnabled() {
@@ -273,22 +379,436 @@ func (s *RAGService) DeleteDocument(ctx context.Context, docID, userID uint) err
 	return nil
 }
 
+// DeleteStaleChunks 删除文档中 chunk_index >= keep 的块。块 ID 按序号生成，重新索引时会覆盖同序号的旧块，
+// 内容变短后多出来的旧块需要单独删除
+func (s *RAGService) DeleteStaleChunks(ctx context.Context, doc *models.Document, keep int) (int, error) {
+	if !s.IsEnabled() {
+		return 0, nil
+	}
+	if doc == nil || doc.ID == 0 || doc.UserID == 0 {
+		return 0, errors.New("invalid document for stale chunk cleanup")
+	}
+
+	ids, err := s.chromaClient.GetIDsByMetadata(ctx, map[string]interface{}{
+		"$and": []map[string]interface{}{
+			{"document_id": int(doc.ID)},
+			{"user_id": int(doc.UserID)},
+			{"chunk_index": map[string]interface{}{"$gte": keep}},
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get stale chunk ids: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := s.chromaClient.Delete(ctx, ids); err != nil {
+		return 0, fmt.Errorf("failed to delete stale chunks from Chroma: %w", err)
+	}
+
+	logger.L.Info("stale document chunks deleted from Chroma",
+		zap.Uint("document_id", doc.ID),
+		zap.Int("kept", keep),
+		zap.Int("deleted", len(ids)),
+	)
+	return len(ids), nil
+}
+
+// 扫描 Chroma 时每页获取的记录数
+const chromaScanPageSize = 500
 
-// chunkText 是一个简单的辅助函数，将文本分割成大约 maxLen 字符的块
-func chunkText(text string, maxLen int) []string {
-	text = strings.TrimSpace(text)
-	if text == "" || maxLen <= 0 {
+// UpdateDocumentMetadata 只更新文档各块的元数据（标题、标签、类别等），不重新生成嵌入向量
+func (s *RAGService) UpdateDocumentMetadata(ctx context.Context, doc *models.Document) error {
+	if !s.IsEnabled() {
 		return nil
 	}
+	if doc == nil || doc.ID == 0 || doc.UserID == 0 {
+		return errors.New("invalid document for metadata update")
+	}
+
+	resp, err := s.chromaClient.Get(ctx, chroma.GetRequest{
+		Where: map[string]interface{}{
+			"$and": []map[string]interface{}{
+				{"document_id": int(doc.ID)},
+				{"user_id": int(doc.UserID)},
+			},
+		},
+		Include: []string{"metadatas"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get document chunks: %w", err)
+	}
+	if len(resp.IDs) == 0 {
+		return nil
+	}
+
+	current := make(map[string]struct{}, len(doc.Tags))
+	for _, tag := range doc.Tags {
+		current[tagMetadataKey(tag)] = struct{}{}
+	}
+
+	metadatas := make([]map[string]interface{}, len(resp.IDs))
+	for i := range resp.IDs {
+		metadata := documentMetadata(doc)
+		if i < len(resp.Metadatas) {
+			old := resp.Metadatas[i]
+			metadata["chunk_index"] = int(metadataUint(old, "chunk_index"))
+			// 块内容仍是写入时的版本，新版本的块由重新索引写入
+			if _, ok := old["version"]; ok {
+				metadata["version"] = int(metadataUint(old, "version"))
+			}
+			// update 会与已有元数据合并，已移除的标签需显式置为 false
+			for key := range old {
+				if _, keep := current[key]; !keep && strings.HasPrefix(key, tagMetadataKey("")) {
+					metadata[key] = false
+				}
+			}
+		}
+		metadatas[i] = metadata
+	}
+
+	if err := s.chromaClient.UpdateMetadatas(ctx, resp.IDs, metadatas); err != nil {
+		logger.L.Error("failed to update document metadata in Chroma",
+			zap.Error(err),
+			zap.Uint("document_id", doc.ID),
+			zap.Uint("user_id", doc.UserID),
+		)
+		return fmt.Errorf("failed to update metadata in Chroma: %w", err)
+	}
+
+	logger.L.Info("document metadata updated in Chroma",
+		zap.Uint("document_id", doc.ID),
+		zap.Int("chunk_count", len(resp.IDs)),
+	)
+	return nil
+}
+
+// ChunkRecord 是 Chroma 中一条向量记录的元数据视图，不包含向量本身
+type ChunkRecord struct {
+	ID         string
+	DocumentID uint
+	UserID     uint
+	Index      int
+}
+
+// ScanChunks 分页遍历集合中的所有向量记录的元数据，每页回调一次。
+// Chroma 只支持 offset 分页，扫描期间有其他写入或删除时记录会错位：重复的记录在此去重，
+// 但被跳过的记录无法发现，调用方不能仅凭扫描结果断定某篇文档缺块，需用 DocumentChunkIndices 复核
+func (s *RAGService) ScanChunks(ctx context.Context, fn func([]ChunkRecord) error) error {
+	if !s.IsEnabled() {
+		return errors.New("rag disabled")
+	}
+
+	seen := make(map[string]struct{})
+	for offset := 0; ; offset += chromaScanPageSize {
+		resp, err := s.chromaClient.Get(ctx, chroma.GetRequest{
+			Limit:   chromaScanPageSize,
+			Offset:  offset,
+			Include: []string{"metadatas"},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scan Chroma: %w", err)
+		}
+		if len(resp.IDs) == 0 {
+			return nil
+		}
 
-	var chunks []string
-	runes := []rune(text)
-	for start := 0; start < len(runes); start += maxLen {
-		end := start + maxLen
-		if end > len(runes) {
-			end = len(runes)
+		records := make([]ChunkRecord, 0, len(resp.IDs))
+		for i, id := range resp.IDs {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			rec := ChunkRecord{ID: id}
+			if i < len(resp.Metadatas) {
+				metadata := resp.Metadatas[i]
+				rec.DocumentID = metadataUint(metadata, "document_id")
+				rec.UserID = metadataUint(metadata, "user_id")
+				rec.Index = int(metadataUint(metadata, "chunk_index"))
+			}
+			records = append(records, rec)
 		}
-		chunks = append(chunks, string(runes[start:end]))
+		if err := fn(records); err != nil {
+			return err
+		}
+
+		if len(resp.IDs) < chromaScanPageSize {
+			return nil
+		}
+	}
+}
+
+// DocumentChunkIndices 只按文档和所有者过滤查询，返回该文档在 Chroma 中已有块的 chunk_index 集合
+func (s *RAGService) DocumentChunkIndices(ctx context.Context, docID, userID uint) (map[int]struct{}, error) {
+	if !s.IsEnabled() {
+		return nil, errors.New("rag disabled")
+	}
+
+	where := map[string]interface{}{
+		"$and": []map[string]interface{}{
+			{"document_id": int(docID)},
+			{"user_id": int(userID)},
+		},
+	}
+	indices := make(map[int]struct{})
+	for offset := 0; ; offset += chromaScanPageSize {
+		resp, err := s.chromaClient.Get(ctx, chroma.GetRequest{
+			Where:   where,
+			Limit:   chromaScanPageSize,
+			Offset:  offset,
+			Include: []string{"metadatas"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get document chunks: %w", err)
+		}
+		for _, metadata := range resp.Metadatas {
+			indices[int(metadataUint(metadata, "chunk_index"))] = struct{}{}
+		}
+		if len(resp.IDs) < chromaScanPageSize {
+			return indices, nil
+		}
+	}
+}
+
+// DeleteChunks 按 ID 删除向量记录
+func (s *RAGService) DeleteChunks(ctx context.Context, ids []string) error {
+	if !s.IsEnabled() {
+		return errors.New("rag disabled")
+	}
+	if err := s.chromaClient.Delete(ctx, ids); err != nil {
+		return fmt.Errorf("failed to delete chunks from Chroma: %w", err)
+	}
+	return nil
+}
+
+// StoredChunk 是 Chroma 中一个块的完整视图，用于排查分块和检索问题。
+// 早于偏移记录功能索引的块没有 CharStart/CharEnd
+type StoredChunk struct {
+	ID            string                 `json:"id"`
+	Index         int                    `json:"chunk_index"`
+	Content       string                 `json:"content"`
+	ContentChars  int                    `json:"content_chars"`
+	CharStart     *int                   `json:"char_start"`
+	CharEnd       *int                   `json:"char_end"`
+	Version       int                    `json:"version,omitempty"`
+	Page          int                    `json:"page,omitempty"`
+	PageEnd       int                    `json:"page_end,omitempty"`
+	Section       string                 `json:"section,omitempty"`
+	EmbeddingDim  int                    `json:"embedding_dim"`
+	EmbeddingNorm float64                `json:"embedding_norm"`
+	Metadata      map[string]interface{} `json:"metadata"`
+}
+
+// ListDocumentChunks 按 chunk_index 顺序返回文档在 Chroma 中的块，total 为块总数。
+// 先只取元数据排序分页，再按 ID 取本页的文本和向量，避免一次取回整篇文档的向量
+func (s *RAGService) ListDocumentChunks(ctx context.Context, doc *models.Document, offset, limit int) (int, []StoredChunk, error) {
+	if !s.IsEnabled() {
+		return 0, nil, errors.New("rag disabled")
+	}
+
+	where := map[string]interface{}{
+		"$and": []map[string]interface{}{
+			{"document_id": int(doc.ID)},
+			{"user_id": int(doc.UserID)},
+		},
+	}
+	type chunkRef struct {
+		id    string
+		index int
+	}
+	var refs []chunkRef
+	for page := 0; ; page += chromaScanPageSize {
+		resp, err := s.chromaClient.Get(ctx, chroma.GetRequest{
+			Where:   where,
+			Limit:   chromaScanPageSize,
+			Offset:  page,
+			Include: []string{"metadatas"},
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to get document chunks: %w", err)
+		}
+		for i, id := range resp.IDs {
+			ref := chunkRef{id: id}
+			if i < len(resp.Metadatas) {
+				ref.index = int(metadataUint(resp.Metadatas[i], "chunk_index"))
+			}
+			refs = append(refs, ref)
+		}
+		if len(resp.IDs) < chromaScanPageSize {
+			break
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].index < refs[j].index })
+
+	total := len(refs)
+	if offset >= total {
+		return total, []StoredChunk{}, nil
+	}
+	refs = refs[offset:min(offset+limit, total)]
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		ids[i] = ref.id
+	}
+
+	resp, err := s.chromaClient.Get(ctx, chroma.GetRequest{
+		IDs:     ids,
+		Include: []string{"documents", "metadatas", "embeddings"},
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to get document chunks: %w", err)
+	}
+	byID := make(map[string]StoredChunk, len(resp.IDs))
+	for i, id := range resp.IDs {
+		chunk := StoredChunk{ID: id, Metadata: map[string]interface{}{}}
+		if i < len(resp.Documents) {
+			chunk.Content = resp.Documents[i]
+			chunk.ContentChars = utf8.RuneCountInString(chunk.Content)
+		}
+		if i < len(resp.Metadatas) {
+			metadata := resp.Metadatas[i]
+			chunk.Metadata = metadata
+			chunk.Index = int(metadataUint(metadata, "chunk_index"))
+			chunk.Version = int(metadataUint(metadata, "version"))
+			chunk.Page = int(metadataUint(metadata, "page"))
+			chunk.PageEnd = int(metadataUint(metadata, "page_end"))
+			chunk.Section, _ = metadata["section"].(string)
+			if _, ok := metadata["char_end"]; ok {
+				start, end := int(metadataUint(metadata, "char_start")), int(metadataUint(metadata, "char_end"))
+				chunk.CharStart, chunk.CharEnd = &start, &end
+			}
+		}
+		if i < len(resp.Embeddings) {
+			var sum float64
+			for _, v := range resp.Embeddings[i] {
+				sum += float64(v) * float64(v)
+			}
+			chunk.EmbeddingDim = len(resp.Embeddings[i])
+			chunk.EmbeddingNorm = math.Sqrt(sum)
+		}
+		byID[id] = chunk
+	}
+
+	chunks := make([]StoredChunk, 0, len(ids))
+	for _, id := range ids {
+		if chunk, ok := byID[id]; ok {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return total, chunks, nil
+}
+
+// ExpectedChunkCount 返回按当前分块规则索引该文档应产生的块数
+func (s *RAGService) ExpectedChunkCount(doc *models.Document) int {
+	return len(chunkText(doc.Content, defaultChunkSize))
+}
+
+// buildRetrievalWhere 构建 Chroma 查询条件：用户自己的文档或共享文档，再叠加过滤器中的条件
+func buildRetrievalWhere(userID uint, filter RetrievalFilter) map[string]interface{} {
+	conditions := []map[string]interface{}{
+		{
+			"$or": []map[string]interface{}{
+				{"user_id": int(userID)},
+				{"visibility": models.DocumentVisibilityShared},
+			},
+		},
+	}
+
+	if len(filter.KnowledgeBaseIDs) > 0 {
+		conditions = append(conditions, map[string]interface{}{
+			"knowledge_base_id": map[string]interface{}{"$in": toIntSlice(filter.KnowledgeBaseIDs)},
+		})
+	}
+
+	if len(filter.DocumentIDs) > 0 {
+		conditions = append(conditions, map[string]interface{}{
+			"document_id": map[string]interface{}{"$in": toIntSlice(filter.DocumentIDs)},
+		})
+	}
+
+	if len(filter.Tags) == 1 {
+		conditions = append(conditions, map[string]interface{}{tagMetadataKey(filter.Tags[0]): true})
+	} else if len(filter.Tags) > 1 {
+		anyTag := make([]map[string]interface{}, len(filter.Tags))
+		for i, tag := range filter.Tags {
+			anyTag[i] = map[string]interface{}{tagMetadataKey(tag): true}
+		}
+		conditions = append(conditions, map[string]interface{}{"$or": anyTag})
+	}
+
+	if filter.Category != "" {
+		conditions = append(conditions, map[string]interface{}{"category": filter.Category})
+	}
+	if filter.YearFrom > 0 {
+		conditions = append(conditions, map[string]interface{}{
+			"publication_year": map[string]interface{}{"$gte": filter.YearFrom},
+		})
+	}
+	if filter.YearTo > 0 {
+		conditions = append(conditions, map[string]interface{}{
+			"publication_year": map[string]interface{}{"$lte": filter.YearTo},
+		})
+	}
+
+	// Chroma 的 $and 至少需要两个条件
+	if len(conditions) == 1 {
+		return conditions[0]
+	}
+	return map[string]interface{}{"$and": conditions}
+}
+
+func toIntSlice(ids []uint) []int {
+	out := make([]int, len(ids))
+	for i, id := range ids {
+		out[i] = int(id)
+	}
+	return out
+}
+
+// documentMetadata 返回写入每个块的文档级元数据，检索过滤依赖这些字段
+func documentMetadata(doc *models.Document) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"document_id":       int(doc.ID),
+		"user_id":           int(doc.UserID),
+		"title":             doc.Title,
+		"version":           doc.Version,
+		"visibility":        documentVisibility(doc),
+		"knowledge_base_id": documentKnowledgeBaseID(doc),
+		"category":          doc.Category,
+		"publication_year":  doc.PublicationYear,
+		"source":            doc.Source,
+	}
+	// Chroma 的元数据不支持数组，每个标签存为一个布尔键，便于按标签过滤
+	for _, tag := range doc.Tags {
+		metadata[tagMetadataKey(tag)] = true
+	}
+	return metadata
+}
+
+// tagMetadataKey 返回标签在 Chroma 元数据中的键名
+func tagMetadataKey(tag string) string {
+	return "tag:" + tag
+}
+
+// documentKnowledgeBaseID 返回文档所属知识库 ID，未归入知识库时为 0
+func documentKnowledgeBaseID(doc *models.Document) int {
+	if doc.KnowledgeBaseID == nil {
+		return 0
+	}
+	return int(*doc.KnowledgeBaseID)
+}
+
+// documentVisibility 返回文档的可见范围，旧数据未设置时视为私有
+func documentVisibility(doc *models.Document) string {
+	if doc.Visibility == "" {
+		return models.DocumentVisibilityPrivate
+	}
+	return doc.Visibility
+}
+
+// metadataUint 读取 Chroma 元数据中的数值字段（JSON 解码后为 float64）
+func metadataUint(metadata map[string]interface{}, key string) uint {
+	if v, ok := metadata[key].(float64); ok && v >= 0 {
+		return uint(v)
 	}
-	return chunks
+	return 0
 }
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"medical-qa-assistant/internal/models"
+)
+
+func retrieveDocumentIDs(t *testing.T, rag *RAGService, userID uint, filter RetrievalFilter) []uint {
+	t.Helper()
+	chunks, err := rag.RetrieveRelevantChunks(context.Background(), userID, "胰岛素剂量", 20, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint
+	for _, chunk := range chunks {
+		ids = append(ids, chunk.DocumentID)
+	}
+	return ids
+}
+
+func TestIndexDocumentClearsRemovedTags(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	ctx := context.Background()
+	doc := &models.Document{
+		ID:      1,
+		UserID:  7,
+		Title:   "糖尿病指南",
+		Content: "第 1 页\f胰岛素起始剂量为每日 0.2 U/kg。",
+		Tags:    []string{"糖尿病", "胰岛素"},
+		Version: 1,
+	}
+	if _, err := rag.IndexDocument(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{Tags: []string{"胰岛素"}}); len(got) != 1 {
+		t.Fatalf("before update, tag filter matched %v", got)
+	}
+
+	doc.Tags = []string{"糖尿病"}
+	doc.Content = "胰岛素起始剂量为每日 0.1 U/kg。"
+	doc.Version = 2
+	if _, err := rag.IndexDocument(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{Tags: []string{"胰岛素"}}); len(got) != 0 {
+		t.Errorf("removed tag still matches chunks of documents %v", got)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{Tags: []string{"糖尿病"}}); len(got) != 1 {
+		t.Errorf("kept tag matched %v, want one chunk", got)
+	}
+
+	// 新内容没有分页，旧块上的页码也不应保留
+	for id, record := range fake.records {
+		if page, ok := record.metadata["page"].(float64); ok && page != 0 {
+			t.Errorf("chunk %s keeps stale page %v", id, page)
+		}
+		if !strings.Contains(record.document, "0.1") {
+			t.Errorf("chunk %s keeps stale content %q", id, record.document)
+		}
+	}
+}
+
+func TestClearMissingKeys(t *testing.T) {
+	metadata := map[string]interface{}{"title": "新标题", "tag:a": true}
+	clearMissingKeys(metadata, map[string]interface{}{
+		"title":   "旧标题",
+		"tag:a":   true,
+		"tag:b":   true,
+		"page":    float64(3),
+		"section": "第二章",
+	})
+	want := map[string]interface{}{"title": "新标题", "tag:a": true, "tag:b": false, "page": 0, "section": ""}
+	if len(metadata) != len(want) {
+		t.Fatalf("metadata = %v, want %v", metadata, want)
+	}
+	for key, value := range want {
+		if metadata[key] != value {
+			t.Errorf("metadata[%q] = %v, want %v", key, metadata[key], value)
+		}
+	}
+}
+
+func TestUpdateDocumentMetadataMovesKnowledgeBase(t *testing.T) {
+	rag, _ := newFakeRAG(t)
+	ctx := context.Background()
+	kb := uint(5)
+	doc := &models.Document{ID: 2, UserID: 7, Title: "用药笔记", Content: "二甲双胍起始剂量 500 mg。", KnowledgeBaseID: &kb, Version: 1}
+	if _, err := rag.IndexDocument(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{KnowledgeBaseIDs: []uint{5}}); len(got) != 1 {
+		t.Fatalf("before move, knowledge base filter matched %v", got)
+	}
+
+	other := uint(6)
+	doc.KnowledgeBaseID = &other
+	if err := rag.UpdateDocumentMetadata(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{KnowledgeBaseIDs: []uint{5}}); len(got) != 0 {
+		t.Errorf("old knowledge base still matches %v", got)
+	}
+	if got := retrieveDocumentIDs(t, rag, 7, RetrievalFilter{KnowledgeBaseIDs: []uint{6}}); len(got) != 1 {
+		t.Errorf("new knowledge base matched %v, want one chunk", got)
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 遍历 MySQL 文档和删除孤立向量时的批大小
+const reconcileBatchSize = 200
+
+// ErrReconcileRunning 表示已有一次对账正在执行
+var ErrReconcileRunning = errors.New("reconcile already running")
+
+// OrphanedVector 是 Chroma 中找不到对应 MySQL 文档（或归属不一致）的向量记录
+type OrphanedVector struct {
+	ID         string `json:"id"`
+	DocumentID uint   `json:"document_id"`
+	UserID     uint   `json:"user_id"`
+	Reason     string `json:"reason"` // document_missing, owner_mismatch, invalid_metadata
+}
+
+// DocumentDrift 描述一篇文档在 Chroma 中的块与预期不一致
+type DocumentDrift struct {
+	DocumentID     uint   `json:"document_id"`
+	UserID         uint   `json:"user_id"`
+	Title          string `json:"title"`
+	ExpectedChunks int    `json:"expected_chunks"`
+	FoundChunks    int    `json:"found_chunks"`
+}
+
+// ReconcileReport 是一次 MySQL/Chroma 对账的结果
+type ReconcileReport struct {
+	DryRun             bool             `json:"dry_run"`
+	StartedAt          time.Time        `json:"started_at"`
+	FinishedAt         time.Time        `json:"finished_at"`
+	ScannedVectors     int              `json:"scanned_vectors"`
+	ScannedDocuments   int              `json:"scanned_documents"`
+	OrphanedVectors    []OrphanedVector `json:"orphaned_vectors"`
+	MissingDocuments   []DocumentDrift  `json:"missing_documents"`
+	PartialDocuments   []DocumentDrift  `json:"partial_documents"`
+	DeletedVectors     int              `json:"deleted_vectors"`
+	ReindexedDocuments []uint           `json:"reindexed_documents"`
+	Errors             []string         `json:"errors,omitempty"`
+}
+
+// reconcileDocumentStore 是对账所需的文档存储操作
+type reconcileDocumentStore interface {
+	FindIndexStatesInBatches(batchSize int, fn func([]models.Document) error) error
+	GetByID(id uint) (*models.Document, error)
+}
+
+// ReconcileService 比对 MySQL 中的文档与 Chroma 中的向量，报告并修复两者之间的漂移
+type ReconcileService struct {
+	documentRepo reconcileDocumentStore
+	ragService   *RAGService
+	indexWorker  *IndexWorker
+
+	running sync.Mutex
+}
+
+func NewReconcileService(documentRepo *repositories.DocumentRepository, ragService *RAGService, indexWorker *IndexWorker) *ReconcileService {
+	return &ReconcileService{
+		documentRepo: documentRepo,
+		ragService:   ragService,
+		indexWorker:  indexWorker,
+	}
+}
+
+// vectorSet 汇总同一 document_id 下的向量记录
+type vectorSet struct {
+	records []ChunkRecord
+}
+
+// Run 执行一次对账。dryRun 为 true 时只生成报告，不做任何修改；
+// 否则删除孤立向量，并将缺失或不完整的文档重新加入索引队列。
+// 修复在扫描全部完成后才开始；扫描期间并发写入导致漏读的块会在复核时找回，漏掉的孤立向量留到下次对账
+func (s *ReconcileService) Run(ctx context.Context, dryRun bool) (*ReconcileReport, error) {
+	if !s.ragService.IsEnabled() {
+		return nil, errors.New("rag disabled, nothing to reconcile")
+	}
+	if !s.running.TryLock() {
+		return nil, ErrReconcileRunning
+	}
+	defer s.running.Unlock()
+
+	report := &ReconcileReport{
+		DryRun:             dryRun,
+		StartedAt:          time.Now(),
+		OrphanedVectors:    []OrphanedVector{},
+		MissingDocuments:   []DocumentDrift{},
+		PartialDocuments:   []DocumentDrift{},
+		ReindexedDocuments: []uint{},
+	}
+
+	// 1. 扫描 Chroma，按文档归组
+	byDocument := make(map[uint]*vectorSet)
+	err := s.ragService.ScanChunks(ctx, func(records []ChunkRecord) error {
+		for _, rec := range records {
+			report.ScannedVectors++
+			if rec.DocumentID == 0 || rec.UserID == 0 {
+				report.OrphanedVectors = append(report.OrphanedVectors, OrphanedVector{
+					ID:         rec.ID,
+					DocumentID: rec.DocumentID,
+					UserID:     rec.UserID,
+					Reason:     "invalid_metadata",
+				})
+				continue
+			}
+			set := byDocument[rec.DocumentID]
+			if set == nil {
+				set = &vectorSet{}
+				byDocument[rec.DocumentID] = set
+			}
+			set.records = append(set.records, rec)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 遍历 MySQL 文档（不含内容），与其向量对比
+	var toReindex []models.Document
+	err = s.documentRepo.FindIndexStatesInBatches(reconcileBatchSize, func(docs []models.Document) error {
+		for i := range docs {
+			doc := &docs[i]
+			report.ScannedDocuments++
+
+			set := byDocument[doc.ID]
+			delete(byDocument, doc.ID)
+
+			indices := make(map[int]struct{})
+			if set != nil {
+				for _, rec := range set.records {
+					if rec.UserID != doc.UserID {
+						report.OrphanedVectors = append(report.OrphanedVectors, OrphanedVector{
+							ID:         rec.ID,
+							DocumentID: rec.DocumentID,
+							UserID:     rec.UserID,
+							Reason:     "owner_mismatch",
+						})
+						continue
+					}
+					indices[rec.Index] = struct{}{}
+				}
+			}
+
+			// 尚在索引或已失败的文档由索引队列负责，不视为漂移
+			if doc.Status != models.DocumentStatusReady {
+				continue
+			}
+			if doc.ChunkCount > 0 && chunkIndicesComplete(indices, doc.ChunkCount) {
+				continue
+			}
+
+			drift, err := s.verifyDrift(ctx, doc.ID)
+			if err != nil {
+				return err
+			}
+			if drift == nil {
+				continue
+			}
+			switch {
+			case drift.ExpectedChunks > 0 && drift.FoundChunks == 0:
+				report.MissingDocuments = append(report.MissingDocuments, *drift)
+				toReindex = append(toReindex, *doc)
+			default:
+				report.PartialDocuments = append(report.PartialDocuments, *drift)
+				toReindex = append(toReindex, *doc)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 剩余的向量在 MySQL 中没有对应文档
+	for _, set := range byDocument {
+		for _, rec := range set.records {
+			report.OrphanedVectors = append(report.OrphanedVectors, OrphanedVector{
+				ID:         rec.ID,
+				DocumentID: rec.DocumentID,
+				UserID:     rec.UserID,
+				Reason:     "document_missing",
+			})
+		}
+	}
+
+	if !dryRun {
+		s.repair(ctx, report, toReindex)
+	}
+
+	report.FinishedAt = time.Now()
+	logger.L.Info("reconcile finished",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("scanned_vectors", report.ScannedVectors),
+		zap.Int("scanned_documents", report.ScannedDocuments),
+		zap.Int("orphaned_vectors", len(report.OrphanedVectors)),
+		zap.Int("missing_documents", len(report.MissingDocuments)),
+		zap.Int("partial_documents", len(report.PartialDocuments)),
+		zap.Int("deleted_vectors", report.DeletedVectors),
+		zap.Int("reindexed_documents", len(report.ReindexedDocuments)),
+		zap.Duration("duration", report.FinishedAt.Sub(report.StartedAt)),
+	)
+	return report, nil
+}
+
+// verifyDrift 复核扫描结果与记录块数不符（或早于 chunk_count 列、块数为 0）的文档：
+// 只对这些文档加载内容按当前分块规则计算应有块数，并单独查询其在 Chroma 中的块，
+// 排除扫描期间并发写入造成的漏读。文档已删除、不再就绪或块完整时返回 nil
+func (s *ReconcileService) verifyDrift(ctx context.Context, docID uint) (*DocumentDrift, error) {
+	doc, err := s.documentRepo.GetByID(docID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if doc.Status != models.DocumentStatusReady {
+		return nil, nil
+	}
+
+	indices, err := s.ragService.DocumentChunkIndices(ctx, doc.ID, doc.UserID)
+	if err != nil {
+		return nil, err
+	}
+	expected := s.ragService.ExpectedChunkCount(doc)
+	if chunkIndicesComplete(indices, expected) {
+		return nil, nil
+	}
+	return &DocumentDrift{
+		DocumentID:     doc.ID,
+		UserID:         doc.UserID,
+		Title:          doc.Title,
+		ExpectedChunks: expected,
+		FoundChunks:    len(indices),
+	}, nil
+}
+
+// repair 删除孤立向量并重新索引漂移的文档，单项失败记录在报告中而不中断整体修复
+func (s *ReconcileService) repair(ctx context.Context, report *ReconcileReport, toReindex []models.Document) {
+	ids := make([]string, 0, len(report.OrphanedVectors))
+	for _, orphan := range report.OrphanedVectors {
+		ids = append(ids, orphan.ID)
+	}
+	for start := 0; start < len(ids); start += reconcileBatchSize {
+		end := start + reconcileBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := s.ragService.DeleteChunks(ctx, ids[start:end]); err != nil {
+			logger.L.Error("failed to delete orphaned vectors", zap.Error(err))
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.DeletedVectors += end - start
+	}
+
+	for i := range toReindex {
+		doc := &toReindex[i]
+		if err := s.indexWorker.Enqueue(doc); err != nil {
+			logger.L.Error("failed to enqueue document for reindex",
+				zap.Error(err),
+				zap.Uint("document_id", doc.ID),
+			)
+			report.Errors = append(report.Errors, err.Error())
+			continue
+		}
+		report.ReindexedDocuments = append(report.ReindexedDocuments, doc.ID)
+	}
+}
+
+// Start 按固定间隔在后台执行对账，interval 不大于 0 时不启动
+func (s *ReconcileService) Start(ctx context.Context, interval time.Duration, repair bool) {
+	if interval <= 0 {
+		return
+	}
+	logger.L.Info("scheduled reconcile enabled",
+		zap.Duration("interval", interval),
+		zap.Bool("repair", repair),
+	)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.Run(ctx, !repair); err != nil {
+					logger.L.Error("scheduled reconcile failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// chunkIndicesComplete 判断块序号是否恰好为 0..expected-1
+func chunkIndicesComplete(indices map[int]struct{}, expected int) bool {
+	if len(indices) != expected {
+		return false
+	}
+	for i := 0; i < expected; i++ {
+		if _, ok := indices[i]; !ok {
+			return false
+		}
+	}
+	return true
+}
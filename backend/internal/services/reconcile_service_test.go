@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"medical-qa-assistant/internal/models"
+)
+
+// FindIndexStatesInBatches 与仓库一样按 ID 顺序分批返回不含内容的文档
+func (s *fakeDocumentStore) FindIndexStatesInBatches(batchSize int, fn func([]models.Document) error) error {
+	s.mu.Lock()
+	ids := make([]uint, 0, len(s.docs))
+	for id := range s.docs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	states := make([]models.Document, len(ids))
+	for i, id := range ids {
+		doc := s.docs[id]
+		states[i] = models.Document{ID: doc.ID, UserID: doc.UserID, Title: doc.Title, Status: doc.Status, ChunkCount: doc.ChunkCount}
+	}
+	s.mu.Unlock()
+
+	for start := 0; start < len(states); start += batchSize {
+		if err := fn(states[start:min(start+batchSize, len(states))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTestReconcile 返回使用内存文档表和队列的 ReconcileService
+func newTestReconcile(rag *RAGService, docs *fakeDocumentStore) (*ReconcileService, *fakeJobStore) {
+	w, jobs := newTestWorker(rag, docs)
+	return &ReconcileService{documentRepo: docs, ragService: rag, indexWorker: w}, jobs
+}
+
+// indexedDoc 返回已写入 Chroma 的就绪文档，ChunkCount 为实际写入的块数
+func indexedDoc(t *testing.T, rag *RAGService, id uint, content string) *models.Document {
+	t.Helper()
+	doc := &models.Document{ID: id, UserID: 1, Title: fmt.Sprintf("doc %d", id), Content: content,
+		Status: models.DocumentStatusReady, Version: 1}
+	n, err := rag.IndexDocument(context.Background(), doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.ChunkCount = n
+	return doc
+}
+
+func (f *fakeChroma) put(id string, metadata map[string]interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.records[id] = &fakeRecord{metadata: metadata}
+}
+
+func (f *fakeChroma) ids() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ids := make([]string, 0, len(f.records))
+	for id := range f.records {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func driftIDs(drifts []DocumentDrift) []uint {
+	ids := []uint{}
+	for _, d := range drifts {
+		ids = append(ids, d.DocumentID)
+	}
+	return ids
+}
+
+func TestReconcileDryRunAndRepair(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	long := strings.Repeat("高血压患者应定期监测血压，并记录每日读数。\n\n", 120)
+
+	healthy := indexedDoc(t, rag, 1, long)
+	legacy := indexedDoc(t, rag, 2, "二甲双胍是 2 型糖尿病的一线用药。")
+	legacy.ChunkCount = 0 // 早于 chunk_count 列的文档，需按内容复核
+	partial := indexedDoc(t, rag, 3, long)
+	missing := &models.Document{ID: 4, UserID: 1, Title: "doc 4", Content: long, Status: models.DocumentStatusReady, ChunkCount: healthy.ChunkCount}
+	pending := &models.Document{ID: 5, UserID: 1, Title: "doc 5", Content: long, Status: models.DocumentStatusPending}
+	if healthy.ChunkCount < 3 {
+		t.Fatalf("test content produced %d chunks, want at least 3", healthy.ChunkCount)
+	}
+
+	fake.mu.Lock()
+	delete(fake.records, "3-1-1")
+	fake.mu.Unlock()
+	fake.put("99-0-1", map[string]interface{}{"document_id": 99, "user_id": 1, "chunk_index": 0})
+	fake.put("1-99-2", map[string]interface{}{"document_id": 1, "user_id": 2, "chunk_index": 99})
+	fake.put("broken", map[string]interface{}{})
+
+	docs := newFakeDocumentStore(healthy, legacy, partial, missing, pending)
+	s, jobs := newTestReconcile(rag, docs)
+	before := fake.ids()
+
+	report, err := s.Run(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := map[string]string{}
+	for _, orphan := range report.OrphanedVectors {
+		reasons[orphan.ID] = orphan.Reason
+	}
+	wantReasons := map[string]string{"99-0-1": "document_missing", "1-99-2": "owner_mismatch", "broken": "invalid_metadata"}
+	if !reflect.DeepEqual(reasons, wantReasons) {
+		t.Errorf("orphans = %v, want %v", reasons, wantReasons)
+	}
+	if got := driftIDs(report.MissingDocuments); !reflect.DeepEqual(got, []uint{4}) {
+		t.Errorf("missing = %v, want [4]", got)
+	}
+	if got := driftIDs(report.PartialDocuments); !reflect.DeepEqual(got, []uint{3}) {
+		t.Fatalf("partial = %v, want [3]", got)
+	}
+	if d := report.PartialDocuments[0]; d.ExpectedChunks != partial.ChunkCount || d.FoundChunks != partial.ChunkCount-1 {
+		t.Errorf("partial drift = %+v, want %d expected and %d found", d, partial.ChunkCount, partial.ChunkCount-1)
+	}
+	if report.ScannedDocuments != 5 {
+		t.Errorf("scanned documents = %d, want 5", report.ScannedDocuments)
+	}
+
+	// dry run 不修改 Chroma，也不入队
+	if after := fake.ids(); !reflect.DeepEqual(after, before) {
+		t.Errorf("dry run changed Chroma: %d records, want %d", len(after), len(before))
+	}
+	if len(jobs.jobs) != 0 || report.DeletedVectors != 0 || len(report.ReindexedDocuments) != 0 {
+		t.Errorf("dry run repaired: %d jobs, %d deleted, reindexed %v", len(jobs.jobs), report.DeletedVectors, report.ReindexedDocuments)
+	}
+
+	report, err = s.Run(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DeletedVectors != 3 {
+		t.Errorf("deleted vectors = %d, want 3", report.DeletedVectors)
+	}
+	if !reflect.DeepEqual(report.ReindexedDocuments, []uint{3, 4}) {
+		t.Errorf("reindexed = %v, want [3 4]", report.ReindexedDocuments)
+	}
+	var queued []uint
+	for _, job := range jobs.jobs {
+		queued = append(queued, job.DocumentID)
+	}
+	if !reflect.DeepEqual(queued, []uint{3, 4}) {
+		t.Errorf("queued jobs for %v, want [3 4]", queued)
+	}
+	for _, id := range fake.ids() {
+		if _, orphan := wantReasons[id]; orphan {
+			t.Errorf("orphan %s still in Chroma after repair", id)
+		}
+	}
+	if got, want := len(fake.ids()), len(before)-3; got != want {
+		t.Errorf("Chroma has %d records after repair, want %d", got, want)
+	}
+}
+
+func TestReconcileRechecksChunksSkippedByScan(t *testing.T) {
+	rag, fake := newFakeRAG(t)
+	doc := indexedDoc(t, rag, 1, "胰岛素剂量应根据血糖监测结果调整。")
+
+	// 一整页排在 doc 1 之前的孤立向量；扫描第二页前删除其中一条，doc 1 的块随之前移到已扫过的位置
+	for i := 0; i < chromaScanPageSize; i++ {
+		fake.put(fmt.Sprintf("0-%03d-1", i), map[string]interface{}{"document_id": 1000, "user_id": 1, "chunk_index": i})
+	}
+	fake.onGet = func(body map[string]interface{}) {
+		if _, filtered := body["where"]; !filtered && body["offset"] == float64(chromaScanPageSize) {
+			delete(fake.records, "0-000-1")
+		}
+	}
+
+	s, _ := newTestReconcile(rag, newFakeDocumentStore(doc))
+	report, err := s.Run(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ScannedVectors != chromaScanPageSize {
+		t.Fatalf("scanned %d vectors, want %d (doc 1 skipped by the shifted page)", report.ScannedVectors, chromaScanPageSize)
+	}
+	if len(report.MissingDocuments) != 0 || len(report.PartialDocuments) != 0 {
+		t.Errorf("missing = %v, partial = %v, want none", driftIDs(report.MissingDocuments), driftIDs(report.PartialDocuments))
+	}
+}
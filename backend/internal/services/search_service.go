@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+)
+
+// 语义检索每页的默认条数，offset+limit 的上限，以及剔除已删除文档后补取时从 Chroma 取回的最大块数
+const (
+	defaultSemanticPageSize = 10
+	maxSemanticWindow       = 100
+	maxSemanticFetch        = 4 * maxSemanticWindow
+)
+
+// ErrSearchUnavailable 表示未配置嵌入服务，无法进行语义检索或查看向量库中的块
+var ErrSearchUnavailable = errors.New("semantic search is not available")
+
+// ErrInvalidSearch 表示检索参数不合法（分页超限、标签无效、文档不存在等）
+var ErrInvalidSearch = errors.New("invalid search request")
+
+// ErrSearchBackend 表示嵌入接口或 Chroma 调用失败
+var ErrSearchBackend = errors.New("vector search failed")
+
+// searchDocumentStore 是 SearchService 校验文档范围和回填标题所需的查询，由 repositories.DocumentRepository 实现
+type searchDocumentStore interface {
+	ListAccessibleByIDs(ids []uint, userID uint) ([]models.Document, error)
+	ListTitlesByIDs(ids []uint) (map[uint]string, error)
+}
+
+// 高亮时忽略的虚词，含有这些字的双字片段不作为检索词
+const highlightStopRunes = "的了是吗呢啊和与及或在有为对把被从哪何么什怎请"
+
+// SearchService 只执行 RAG 检索，返回相关片段而不生成回答
+type SearchService struct {
+	rag          *RAGService
+	documentRepo searchDocumentStore
+	kbRepo       *repositories.KnowledgeBaseRepository
+}
+
+func NewSearchService(rag *RAGService, documentRepo *repositories.DocumentRepository, kbRepo *repositories.KnowledgeBaseRepository) *SearchService {
+	return &SearchService{
+		rag:          rag,
+		documentRepo: documentRepo,
+		kbRepo:       kbRepo,
+	}
+}
+
+// SearchRequest 是语义检索请求，过滤条件与问答相同
+type SearchRequest struct {
+	Query string `json:"query" binding:"required,min=1,max=1000"`
+
+	KnowledgeBaseIDs []uint   `json:"knowledge_base_ids"`
+	DocumentIDs      []uint   `json:"document_ids"`
+	Tags             []string `json:"tags"`
+	Category         string   `json:"category" binding:"omitempty,oneof=guideline textbook drug_label paper note"`
+	YearFrom         int      `json:"year_from" binding:"omitempty,min=0"`
+	YearTo           int      `json:"year_to" binding:"omitempty,min=0"`
+
+	Limit  int `json:"limit" binding:"omitempty,min=1"`
+	Offset int `json:"offset" binding:"omitempty,min=0"`
+}
+
+// SearchHit 是一条检索到的片段。Score 由向量距离换算为 (0, 1]，越大越相关；
+// Highlights 为查询词在 Content 中的字符偏移，Highlighted 为标出查询词的 HTML 片段
+type SearchHit struct {
+	Rank        int        `json:"rank"`
+	DocumentID  uint       `json:"document_id"`
+	Title       string     `json:"title"`
+	ChunkIndex  int        `json:"chunk_index"`
+	Version     int        `json:"version,omitempty"`
+	Page        int        `json:"page,omitempty"`
+	PageEnd     int        `json:"page_end,omitempty"`
+	Section     string     `json:"section,omitempty"`
+	CharStart   int        `json:"char_start"`
+	CharEnd     int        `json:"char_end,omitempty"`
+	Score       float64    `json:"score"`
+	Distance    float64    `json:"distance"`
+	Content     string     `json:"content"`
+	Highlighted string     `json:"highlighted"`
+	Highlights  []TextSpan `json:"highlights"`
+}
+
+// SearchResponse 是一页检索结果
+type SearchResponse struct {
+	Query   string      `json:"query"`
+	Limit   int         `json:"limit"`
+	Offset  int         `json:"offset"`
+	HasMore bool        `json:"has_more"`
+	Items   []SearchHit `json:"items"`
+}
+
+// Search 检索与查询相关的文档片段并分页返回。每次请求从 Chroma 取回前 offset+limit+1 个仍存在的文档的块，
+// 查询向量有缓存，同一查询翻页不会重复调用嵌入接口
+func (s *SearchService) Search(ctx context.Context, userID uint, req *SearchRequest) (*SearchResponse, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	if !s.rag.IsEnabled() {
+		return nil, ErrSearchUnavailable
+	}
+	query := strings.TrimSpace(req.Query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSemanticPageSize
+	}
+	if req.Offset+limit > maxSemanticWindow {
+		return nil, fmt.Errorf("%w: offset + limit must not exceed %d", ErrInvalidSearch, maxSemanticWindow)
+	}
+
+	filter := RetrievalFilter{
+		KnowledgeBaseIDs: uniqueIDs(req.KnowledgeBaseIDs),
+		DocumentIDs:      uniqueIDs(req.DocumentIDs),
+		Category:         req.Category,
+		YearFrom:         req.YearFrom,
+		YearTo:           req.YearTo,
+	}
+	if err := validateKnowledgeBaseOwnership(s.kbRepo, userID, filter.KnowledgeBaseIDs); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSearch, err)
+	}
+	filter.Tags = tags
+	if len(filter.DocumentIDs) > 0 {
+		docs, err := s.documentRepo.ListAccessibleByIDs(filter.DocumentIDs, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(docs) != len(filter.DocumentIDs) {
+			return nil, fmt.Errorf("%w: document not found", ErrInvalidSearch)
+		}
+	}
+
+	chunks, err := s.retrieveLive(ctx, userID, query, req.Offset+limit+1, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SearchResponse{
+		Query:  query,
+		Limit:  limit,
+		Offset: req.Offset,
+		Items:  []SearchHit{},
+	}
+	if req.Offset >= len(chunks) {
+		return resp, nil
+	}
+	page := chunks[req.Offset:]
+	if len(page) > limit {
+		page = page[:limit]
+		resp.HasMore = true
+	}
+
+	terms := highlightTerms(query)
+	for i, ch := range page {
+		spans := matchSpans(ch.Content, terms)
+		if spans == nil {
+			spans = []TextSpan{}
+		}
+		resp.Items = append(resp.Items, SearchHit{
+			Rank:        req.Offset + i + 1,
+			DocumentID:  ch.DocumentID,
+			Title:       ch.Title,
+			ChunkIndex:  ch.Index,
+			Version:     ch.Version,
+			Page:        ch.Page,
+			PageEnd:     ch.PageEnd,
+			Section:     ch.Section,
+			CharStart:   ch.CharStart,
+			CharEnd:     ch.CharEnd,
+			Score:       1 / (1 + ch.Distance),
+			Distance:    ch.Distance,
+			Content:     ch.Content,
+			Highlighted: markSpans(ch.Content, spans),
+			Highlights:  spans,
+		})
+	}
+	return resp, nil
+}
+
+// retrieveLive 返回前 want 个属于仍存在文档的块。文档删除后向量由 outbox 异步清理，
+// 结果中混有已删除文档的块时加倍补取（最多 maxSemanticFetch 个），保证分页和 has_more 不因剔除而偏移
+func (s *SearchService) retrieveLive(ctx context.Context, userID uint, query string, want int, filter RetrievalFilter) ([]models.Chunk, error) {
+	for n := want; ; n = min(2*n, maxSemanticFetch) {
+		chunks, err := s.rag.RetrieveRelevantChunks(ctx, userID, query, n, filter)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSearchBackend, err)
+		}
+		fetched := len(chunks)
+		chunks, err = s.dropDeleted(chunks)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) >= want || fetched < n || n >= maxSemanticFetch {
+			return chunks, nil
+		}
+	}
+}
+
+// dropDeleted 去掉文档已删除、向量尚未清理的片段，并以数据库中的标题为准
+func (s *SearchService) dropDeleted(chunks []models.Chunk) ([]models.Chunk, error) {
+	if len(chunks) == 0 {
+		return chunks, nil
+	}
+	ids := make([]uint, 0, len(chunks))
+	for _, ch := range chunks {
+		ids = append(ids, ch.DocumentID)
+	}
+	titles, err := s.documentRepo.ListTitlesByIDs(uniqueIDs(ids))
+	if err != nil {
+		return nil, err
+	}
+	kept := chunks[:0]
+	for _, ch := range chunks {
+		if title, ok := titles[ch.DocumentID]; ok {
+			ch.Title = title
+			kept = append(kept, ch)
+		}
+	}
+	return kept, nil
+}
+
+// highlightTerms 从查询中提取用于高亮的词：拉丁字母和数字按词切分（至少两个字符），
+// 中文没有空格分词，按相邻两字切分并跳过含虚词的片段，相邻命中在高亮时会合并成完整词语
+func highlightTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	add := func(term string) {
+		if key := strings.ToLower(term); !seen[key] {
+			seen[key] = true
+			terms = append(terms, term)
+		}
+	}
+
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		var han, other []rune
+		flushOther := func() {
+			if len(other) >= 2 {
+				add(string(other))
+			}
+			other = other[:0]
+		}
+		flushHan := func() {
+			for i := 0; i+2 <= len(han); i++ {
+				if !strings.ContainsRune(highlightStopRunes, han[i]) && !strings.ContainsRune(highlightStopRunes, han[i+1]) {
+					add(string(han[i : i+2]))
+				}
+			}
+			han = han[:0]
+		}
+		for _, r := range word {
+			if unicode.Is(unicode.Han, r) {
+				flushOther()
+				han = append(han, r)
+			} else {
+				flushHan()
+				other = append(other, r)
+			}
+		}
+		flushOther()
+		flushHan()
+	}
+	return terms
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"medical-qa-assistant/internal/models"
+)
+
+// fakeSearchDocs 是 MySQL 中仍存在的文档标题
+type fakeSearchDocs map[uint]string
+
+func (f fakeSearchDocs) ListAccessibleByIDs(ids []uint, userID uint) ([]models.Document, error) {
+	var docs []models.Document
+	for _, id := range ids {
+		if title, ok := f[id]; ok {
+			docs = append(docs, models.Document{ID: id, UserID: userID, Title: title})
+		}
+	}
+	return docs, nil
+}
+
+func (f fakeSearchDocs) ListTitlesByIDs(ids []uint) (map[uint]string, error) {
+	titles := make(map[uint]string)
+	for _, id := range ids {
+		if title, ok := f[id]; ok {
+			titles[id] = title
+		}
+	}
+	return titles, nil
+}
+
+func TestHighlightTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"的了吗", nil},
+		{"a", nil},
+		{"HbA1c", []string{"HbA1c"}},
+		{"HbA1c hba1c", []string{"HbA1c"}},
+		{"胰岛素", []string{"胰岛", "岛素"}},
+		// 含虚词的双字片段被跳过
+		{"胰岛素的剂量", []string{"胰岛", "岛素", "剂量"}},
+		{"二甲双胍500mg", []string{"二甲", "甲双", "双胍", "500mg"}},
+		{"什么是 GLP-1？", []string{"GLP"}},
+		{"血压，血压", []string{"血压"}},
+	}
+	for _, tt := range tests {
+		got := highlightTerms(tt.query)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("highlightTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// newTestSearch 为文档 1-5 各写入一个块，文档 2 已从 MySQL 删除但向量尚未清理
+func newTestSearch(t *testing.T) (*SearchService, *fakeChroma) {
+	t.Helper()
+	rag, fake := newFakeRAG(t)
+	docs := fakeSearchDocs{}
+	for id := uint(1); id <= 5; id++ {
+		doc := &models.Document{ID: id, UserID: 7, Title: fmt.Sprintf("旧标题 %d", id), Content: "胰岛素剂量调整", Version: 1}
+		if _, err := rag.IndexDocument(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+		if id != 2 {
+			docs[id] = fmt.Sprintf("指南 %d", id)
+		}
+	}
+	return &SearchService{rag: rag, documentRepo: docs}, fake
+}
+
+// 已删除文档的块在分页之前剔除，页与页之间不重叠、不遗漏，has_more 按剔除后的结果计算
+func TestSearchPaging(t *testing.T) {
+	s, _ := newTestSearch(t)
+	tests := []struct {
+		offset, limit int
+		want          []uint
+		hasMore       bool
+	}{
+		{0, 2, []uint{1, 3}, true},
+		{2, 2, []uint{4, 5}, false},
+		{0, 4, []uint{1, 3, 4, 5}, false},
+		{0, 3, []uint{1, 3, 4}, true},
+		{4, 2, nil, false},
+	}
+	for _, tt := range tests {
+		resp, err := s.Search(context.Background(), 7, &SearchRequest{Query: "胰岛素剂量", Offset: tt.offset, Limit: tt.limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint
+		for i, hit := range resp.Items {
+			got = append(got, hit.DocumentID)
+			if hit.Rank != tt.offset+i+1 {
+				t.Errorf("offset %d: hit %d rank = %d", tt.offset, i, hit.Rank)
+			}
+			if hit.Title != fmt.Sprintf("指南 %d", hit.DocumentID) {
+				t.Errorf("hit title = %q, want the title from MySQL", hit.Title)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) || resp.HasMore != tt.hasMore {
+			t.Errorf("offset %d limit %d: got %v has_more %v, want %v has_more %v",
+				tt.offset, tt.limit, got, resp.HasMore, tt.want, tt.hasMore)
+		}
+	}
+}
+
+func TestSearchHighlights(t *testing.T) {
+	s, _ := newTestSearch(t)
+	resp, err := s.Search(context.Background(), 7, &SearchRequest{Query: "胰岛素的剂量", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// “胰岛”“岛素”“剂量”相邻，合并为一个高亮区间
+	hit := resp.Items[0]
+	if len(hit.Highlights) != 1 || hit.Highlights[0] != (TextSpan{0, 5}) {
+		t.Errorf("highlights = %+v", hit.Highlights)
+	}
+	if hit.Highlighted != "<mark>胰岛素剂量</mark>调整" {
+		t.Errorf("highlighted = %q", hit.Highlighted)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	s, _ := newTestSearch(t)
+	tests := []struct {
+		name string
+		req  SearchRequest
+		want error
+	}{
+		{"blank query", SearchRequest{Query: "  "}, ErrEmptyQuery},
+		{"window too large", SearchRequest{Query: "剂量", Offset: 95, Limit: 10}, ErrInvalidSearch},
+		{"missing document", SearchRequest{Query: "剂量", DocumentIDs: []uint{2}}, ErrInvalidSearch},
+		{"tag too long", SearchRequest{Query: "剂量", Tags: []string{strings.Repeat("长", maxDocumentTagLen+1)}}, ErrInvalidSearch},
+	}
+	for _, tt := range tests {
+		if _, err := s.Search(context.Background(), 7, &tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Search = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	s.rag.embedClient = nil
+	if _, err := s.Search(context.Background(), 7, &SearchRequest{Query: "剂量"}); !errors.Is(err, ErrSearchUnavailable) {
+		t.Errorf("disabled rag: Search = %v, want ErrSearchUnavailable", err)
+	}
+}
+
+// Chroma 不可用属于上游故障，与参数错误区分
+func TestSearchBackendError(t *testing.T) {
+	s, fake := newTestSearch(t)
+	fake.unavailable = true
+	if _, err := s.Search(context.Background(), 7, &SearchRequest{Query: "二甲双胍"}); !errors.Is(err, ErrSearchBackend) {
+		t.Errorf("Search = %v, want ErrSearchBackend", err)
+	}
+}
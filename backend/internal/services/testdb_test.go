@@ -0,0 +1,63 @@
+package services
+
+import (
+	"os"
+	"testing"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// openTestDB 连接 TEST_MYSQL_DSN 指定的测试库，迁移全部表并清空数据，未设置时跳过测试。
+// 测试会删除数据，不要指向开发或生产库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN not set, skipping MySQL test")
+	}
+	logger.L = zap.NewNop()
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: gormlogger.Default.LogMode(gormlogger.Silent)})
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	tables := []interface{}{
+		&models.User{}, &models.Document{}, &models.DocumentVersion{}, &models.DocumentRedaction{},
+		&models.IndexJob{}, &models.OutboxEvent{}, &models.KnowledgeBase{}, &models.UploadBatch{}, &models.UploadBatchItem{},
+	}
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	for _, table := range tables {
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(table).Error; err != nil {
+			t.Fatalf("clean test database: %v", err)
+		}
+	}
+	return db
+}
+
+// newTestDocumentService 返回使用测试库的 DocumentService，不连接 Chroma
+func newTestDocumentService(db *gorm.DB) *DocumentService {
+	documentRepo := repositories.NewDocumentRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	dispatcher := NewOutboxDispatcher(outboxRepo, documentRepo, nil, nil, 1, 0, 0)
+	return NewDocumentService(
+		documentRepo,
+		repositories.NewDocumentVersionRepository(db),
+		repositories.NewDocumentRedactionRepository(db),
+		outboxRepo,
+		repositories.NewKnowledgeBaseRepository(db),
+		nil,
+		dispatcher,
+		nil,
+		DuplicateOptions{},
+		nil,
+		nil,
+	)
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"errors"
+	"path"
+
+	"medical-qa-assistant/internal/logger"
+	"medical-qa-assistant/internal/models"
+	"medical-qa-assistant/internal/repositories"
+
+	"go.uber.org/zap"
+)
+
+// UploadBatchService 为批量上传的每个文件创建文档，并汇总各文档的索引进度
+type UploadBatchService struct {
+	batchRepo       *repositories.UploadBatchRepository
+	documentRepo    *repositories.DocumentRepository
+	kbRepo          *repositories.KnowledgeBaseRepository
+	documentService *DocumentService
+}
+
+func NewUploadBatchService(
+	batchRepo *repositories.UploadBatchRepository,
+	documentRepo *repositories.DocumentRepository,
+	kbRepo *repositories.KnowledgeBaseRepository,
+	documentService *DocumentService,
+) *UploadBatchService {
+	return &UploadBatchService{
+		batchRepo:       batchRepo,
+		documentRepo:    documentRepo,
+		kbRepo:          kbRepo,
+		documentService: documentService,
+	}
+}
+
+// BatchEntry 是批次中的一个文件。ErrorCode 非空表示文件在提取阶段已被拒绝
+type BatchEntry struct {
+	Filename     string
+	Content      string
+	Encoding     string
+	File         *UploadedFile // 原始文件，随文档保存
+	ErrorCode    string
+	ErrorMessage string
+}
+
+// BatchItemResult 是单个条目的结果及其文档当前的索引状态
+type BatchItemResult struct {
+	models.UploadBatchItem
+	DocumentStatus string `json:"document_status,omitempty"` // 文档已被删除时为 deleted
+	DocumentError  string `json:"document_error,omitempty"`
+}
+
+// UploadBatchProgress 是批次的处理进度
+type UploadBatchProgress struct {
+	ID       uint `json:"id"`
+	Total    int  `json:"total"`
+	Created  int  `json:"created"`
+	Rejected int  `json:"rejected"`
+
+	// 已创建文档的索引进度
+	Indexing    int  `json:"indexing"`
+	Indexed     int  `json:"indexed"`
+	IndexFailed int  `json:"index_failed"`
+	Completed   bool `json:"completed"`             // 所有文档均已结束索引（成功或失败）
+	Interrupted bool `json:"interrupted,omitempty"` // 批次未处理完全部文件（如服务中途重启）
+
+	Items []BatchItemResult `json:"items"`
+}
+
+// 批次条目被服务层拒绝时的错误码
+const (
+	batchCodeCreateFailed = "create_failed"
+	batchCodeForbidden    = "forbidden"
+	batchCodeDuplicate    = "duplicate_document"
+	batchCodeTitleTooLong = "title_too_long"
+)
+
+// 条目文件名（压缩包内的相对路径）的最大保存长度，与 upload_batch_items.filename 列一致
+const maxBatchItemFilenameLen = 512
+
+// CreateBatch 为每个条目创建一篇文档，共享元数据取自 base，标题取文件名。
+// 批次记录先于文档写入，每篇文档创建后立即写入对应条目，进程中途退出时已创建的文档仍可通过批次查到。
+// 单个文件失败不影响其他文件；文档的索引与单文件上传一样经由 outbox 异步完成。
+// 文档在调用方（上传请求）中逐个同步创建，耗时与条目数成正比，条目数由调用方按 MaxBatchFiles 限制；
+// 客户端断开连接不会中止创建，剩余条目仍会写完
+func (s *UploadBatchService) CreateBatch(userID uint, role string, base *CreateDocumentRequest, entries []BatchEntry) (*UploadBatchProgress, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("no files in batch")
+	}
+	// 对整个批次都会失败的条件提前检查，避免生成一批相同的失败条目
+	if base.Visibility == models.DocumentVisibilityShared && role != models.RoleAdmin {
+		return nil, ErrForbidden
+	}
+	if base.KnowledgeBaseID != nil {
+		if err := validateKnowledgeBaseOwnership(s.kbRepo, userID, []uint{*base.KnowledgeBaseID}); err != nil {
+			return nil, err
+		}
+	}
+
+	batch := &models.UploadBatch{UserID: userID, Total: len(entries)}
+	if err := s.batchRepo.Create(batch); err != nil {
+		logger.L.Error("failed to save upload batch",
+			zap.Error(err),
+			zap.Uint("user_id", userID),
+			zap.Int("total", batch.Total),
+		)
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		item := s.createEntry(userID, role, base, entry)
+		if err := s.batchRepo.AddItem(batch, &item); err != nil {
+			logger.L.Error("failed to save upload batch item",
+				zap.Error(err),
+				zap.Uint("batch_id", batch.ID),
+				zap.String("filename", item.Filename),
+			)
+			return nil, err
+		}
+	}
+
+	logger.L.Info("upload batch created",
+		zap.Uint("batch_id", batch.ID),
+		zap.Uint("user_id", userID),
+		zap.Int("total", batch.Total),
+		zap.Int("created", batch.Created),
+		zap.Int("rejected", batch.Rejected),
+	)
+	return s.progress(batch)
+}
+
+// createEntry 为单个条目创建文档，返回待写入的批次条目
+func (s *UploadBatchService) createEntry(userID uint, role string, base *CreateDocumentRequest, entry BatchEntry) models.UploadBatchItem {
+	item := models.UploadBatchItem{Filename: truncateRunes(entry.Filename, maxBatchItemFilenameLen)}
+	if entry.ErrorCode != "" {
+		item.Status = models.UploadBatchItemRejected
+		item.ErrorCode = entry.ErrorCode
+		item.ErrorMessage = entry.ErrorMessage
+		return item
+	}
+
+	req := *base
+	req.Title = path.Base(entry.Filename)
+	req.Content = entry.Content
+	req.Encoding = entry.Encoding
+	req.File = entry.File
+	doc, err := s.documentService.Create(userID, role, &req)
+	if err != nil {
+		item.Status = models.UploadBatchItemRejected
+		item.ErrorCode = batchCodeCreateFailed
+		switch {
+		case errors.Is(err, ErrForbidden):
+			item.ErrorCode = batchCodeForbidden
+		case errors.Is(err, ErrDuplicateDocument):
+			item.ErrorCode = batchCodeDuplicate
+		case errors.Is(err, ErrTitleTooLong):
+			item.ErrorCode = batchCodeTitleTooLong
+		}
+		item.ErrorMessage = err.Error()
+		return item
+	}
+	item.Status = models.UploadBatchItemCreated
+	item.DocumentID = &doc.ID
+	return item
+}
+
+// Progress 返回用户自己批次的处理进度
+func (s *UploadBatchService) Progress(userID, batchID uint) (*UploadBatchProgress, error) {
+	if userID == 0 {
+		return nil, errors.New("invalid user")
+	}
+	batch, err := s.batchRepo.GetByIDAndUser(batchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.progress(batch)
+}
+
+func (s *UploadBatchService) progress(batch *models.UploadBatch) (*UploadBatchProgress, error) {
+	var docIDs []uint
+	for _, item := range batch.Items {
+		if item.DocumentID != nil {
+			docIDs = append(docIDs, *item.DocumentID)
+		}
+	}
+	docs := make(map[uint]models.Document, len(docIDs))
+	if len(docIDs) > 0 {
+		list, err := s.documentRepo.ListStatusesByIDs(docIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range list {
+			docs[doc.ID] = doc
+		}
+	}
+
+	progress := &UploadBatchProgress{
+		ID:       batch.ID,
+		Total:    batch.Total,
+		Created:  batch.Created,
+		Rejected: batch.Rejected,
+		Items:    make([]BatchItemResult, 0, len(batch.Items)),
+	}
+	for _, item := range batch.Items {
+		result := BatchItemResult{UploadBatchItem: item}
+		if item.DocumentID != nil {
+			doc, ok := docs[*item.DocumentID]
+			switch {
+			case !ok:
+				result.DocumentStatus = "deleted"
+			case doc.Status == models.DocumentStatusReady:
+				result.DocumentStatus = doc.Status
+				progress.Indexed++
+			case doc.Status == models.DocumentStatusFailed:
+				result.DocumentStatus = doc.Status
+				result.DocumentError = doc.ErrorMessage
+				progress.IndexFailed++
+			default:
+				result.DocumentStatus = doc.Status
+				progress.Indexing++
+			}
+		}
+		progress.Items = append(progress.Items, result)
+	}
+	// 条目数少于总数说明批次在创建过程中中断，剩余文件不会再处理
+	progress.Interrupted = len(batch.Items) < batch.Total
+	progress.Completed = progress.Indexing == 0
+	return progress, nil
+}
@@ -0,0 +1,266 @@
+// Package textdiff 按行比较两段文本，输出统一格式（unified）的差异
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 操作类型
+const (
+	OpEqual  = ' '
+	OpDelete = '-'
+	OpInsert = '+'
+)
+
+// Line 是差异中的一行
+type Line struct {
+	Op   byte
+	Text string
+}
+
+// Hunk 是一段连续的差异及其上下文，行号从 1 开始
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Result 是两段文本的比较结果
+type Result struct {
+	Added   int
+	Removed int
+	Hunks   []Hunk
+}
+
+// Lines 比较 a 和 b，每个差异块前后保留 context 行上下文
+func Lines(a, b string, context int) *Result {
+	ops := diff(splitLines(a), splitLines(b))
+	result := &Result{}
+	for _, op := range ops {
+		switch op.Op {
+		case OpInsert:
+			result.Added++
+		case OpDelete:
+			result.Removed++
+		}
+	}
+	result.Hunks = hunks(ops, context)
+	return result
+}
+
+// Unified 以统一格式输出差异，fromName/toName 用于 ---/+++ 行
+func (r *Result) Unified(fromName, toName string) string {
+	if len(r.Hunks) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range r.Hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+		for _, line := range h.Lines {
+			sb.WriteByte(line.Op)
+			sb.WriteString(line.Text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	if lines == 0 {
+		// 空范围按惯例指向前一行
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// 编辑距离超过该值时不再求最短编辑序列，中间部分整体按删除加插入输出，避免内存随 D² 增长
+const maxEditDistance = 2000
+
+// diff 先去掉相同的首尾行，再对中间部分使用 Myers 算法求最短编辑序列
+func diff(a, b []string) []Line {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for _, line := range a[:prefix] {
+		out = append(out, Line{Op: OpEqual, Text: line})
+	}
+	out = append(out, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		out = append(out, Line{Op: OpEqual, Text: line})
+	}
+	return out
+}
+
+// myers 的时间复杂度为 O((N+M)D)，每一轮只保存对角线 -d..d 的状态用于回溯
+func myers(a, b []string) []Line {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	maxD := min(n+m, maxEditDistance)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			if x := v[offset+k]; x >= n && x-k >= m {
+				return backtrack(trace, a, b, k)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+// backtrack 从终点沿每轮保存的状态倒推出编辑序列。trace[d][i] 对应对角线 k = i-d
+func backtrack(trace [][]int, a, b []string, k int) []Line {
+	at := func(d, k int) int { return trace[d][k+d] }
+	x, y := len(a), len(b)
+	var out []Line
+	for d := len(trace) - 1; d > 0; d-- {
+		var prevK int
+		if k == -d || (k != d && at(d-1, k-1) < at(d-1, k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(d-1, prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			out = append(out, Line{Op: OpEqual, Text: a[x]})
+		}
+		if x == prevX {
+			y--
+			out = append(out, Line{Op: OpInsert, Text: b[y]})
+		} else {
+			x--
+			out = append(out, Line{Op: OpDelete, Text: a[x]})
+		}
+		k = prevK
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		out = append(out, Line{Op: OpEqual, Text: a[x]})
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+func replaceAll(a, b []string) []Line {
+	out := make([]Line, 0, len(a)+len(b))
+	for _, line := range a {
+		out = append(out, Line{Op: OpDelete, Text: line})
+	}
+	for _, line := range b {
+		out = append(out, Line{Op: OpInsert, Text: line})
+	}
+	return out
+}
+
+// hunks 将编辑序列切分为带上下文的差异块
+func hunks(ops []Line, context int) []Hunk {
+	var result []Hunk
+	oldLine, newLine := 1, 1
+	i := 0
+	for i < len(ops) {
+		// 跳到下一处修改
+		for i < len(ops) && ops[i].Op == OpEqual {
+			i++
+			oldLine++
+			newLine++
+		}
+		if i == len(ops) {
+			break
+		}
+
+		start := max(i-context, 0)
+		h := Hunk{
+			OldStart: oldLine - (i - start),
+			NewStart: newLine - (i - start),
+		}
+		for j := start; j < i; j++ {
+			h.Lines = append(h.Lines, ops[j])
+		}
+		h.OldLines, h.NewLines = i-start, i-start
+
+		// 相邻修改之间的相同行不超过 2*context 时合并到同一个块
+		for i < len(ops) {
+			if ops[i].Op == OpEqual {
+				run := 0
+				for i+run < len(ops) && ops[i+run].Op == OpEqual {
+					run++
+				}
+				if i+run == len(ops) || run > 2*context {
+					tail := min(run, context)
+					h.Lines = append(h.Lines, ops[i:i+tail]...)
+					h.OldLines += tail
+					h.NewLines += tail
+					i += run
+					oldLine += run
+					newLine += run
+					break
+				}
+				h.Lines = append(h.Lines, ops[i:i+run]...)
+				h.OldLines += run
+				h.NewLines += run
+				i += run
+				oldLine += run
+				newLine += run
+				continue
+			}
+			h.Lines = append(h.Lines, ops[i])
+			if ops[i].Op == OpDelete {
+				h.OldLines++
+				oldLine++
+			} else {
+				h.NewLines++
+				newLine++
+			}
+			i++
+		}
+		result = append(result, h)
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package textdiff
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b    string
+		added   int
+		removed int
+		want    string
+	}{
+		{name: "both empty"},
+		{name: "identical", a: "第一行\n第二行\n", b: "第一行\n第二行\n"},
+		{name: "trailing newline only", a: "a\nb", b: "a\nb\n"},
+		{name: "crlf", a: "a\r\nb\r\n", b: "a\nb\n"},
+		{
+			name: "from empty", b: "新增一\n新增二\n", added: 2,
+			want: "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+新增一\n+新增二\n",
+		},
+		{
+			name: "to empty", a: "旧内容\n", removed: 1,
+			want: "--- a\n+++ b\n@@ -1 +0,0 @@\n-旧内容\n",
+		},
+		{
+			name: "insert only", a: "1\n2\n3\n4\n5\n", b: "1\n2\n3\n插入\n4\n5\n", added: 1,
+			want: "--- a\n+++ b\n@@ -2,4 +2,5 @@\n 2\n 3\n+插入\n 4\n 5\n",
+		},
+		{
+			name: "delete only", a: "1\n2\n3\n4\n5\n", b: "1\n2\n4\n5\n", removed: 1,
+			want: "--- a\n+++ b\n@@ -1,5 +1,4 @@\n 1\n 2\n-3\n 4\n 5\n",
+		},
+		{
+			name:  "cjk replace",
+			a:     "二甲双胍起始剂量 500 mg\n每日两次\n餐后服用\n",
+			b:     "二甲双胍起始剂量 500 mg\n每日一次\n餐后服用\n",
+			added: 1, removed: 1,
+			want: "--- a\n+++ b\n@@ -1,3 +1,3 @@\n 二甲双胍起始剂量 500 mg\n-每日两次\n+每日一次\n 餐后服用\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Lines(tt.a, tt.b, 2)
+			if r.Added != tt.added || r.Removed != tt.removed {
+				t.Errorf("added/removed = %d/%d, want %d/%d", r.Added, r.Removed, tt.added, tt.removed)
+			}
+			if got := r.Unified("a", "b"); got != tt.want {
+				t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHunksSplitByContext(t *testing.T) {
+	var a, b []string
+	for i := 1; i <= 20; i++ {
+		a = append(a, fmt.Sprint(i))
+		b = append(b, fmt.Sprint(i))
+	}
+	b[1] = "二"
+	b[17] = "十八"
+	r := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"), 3)
+	if len(r.Hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2:\n%s", len(r.Hunks), r.Unified("a", "b"))
+	}
+	first, second := r.Hunks[0], r.Hunks[1]
+	if first.OldStart != 1 || first.OldLines != 5 || first.NewStart != 1 || first.NewLines != 5 {
+		t.Errorf("first hunk = %+v", first)
+	}
+	if second.OldStart != 15 || second.OldLines != 6 || second.NewStart != 15 || second.NewLines != 6 {
+		t.Errorf("second hunk = %+v", second)
+	}
+
+	// 上下文足够大时两处修改合并为一个块
+	if r := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"), 8); len(r.Hunks) != 1 {
+		t.Errorf("context 8: got %d hunks, want 1", len(r.Hunks))
+	}
+}
+
+// apply 按编辑序列还原两侧文本
+func apply(ops []Line) (before, after []string) {
+	for _, op := range ops {
+		if op.Op != OpInsert {
+			before = append(before, op.Text)
+		}
+		if op.Op != OpDelete {
+			after = append(after, op.Text)
+		}
+	}
+	return before, after
+}
+
+// lcs 用动态规划求最长公共子序列长度，作为最短编辑序列的参照
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestDiffIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"血压", "血糖", "剂量", "a", "b", ""}
+	random := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = words[rng.Intn(len(words))]
+		}
+		return lines
+	}
+	for n := 0; n < 500; n++ {
+		a, b := random(), random()
+		ops := diff(a, b)
+		before, after := apply(ops)
+		if strings.Join(before, "\n") != strings.Join(a, "\n") || strings.Join(after, "\n") != strings.Join(b, "\n") {
+			t.Fatalf("diff(%q, %q) does not reproduce inputs: %v", a, b, ops)
+		}
+		edits := 0
+		for _, op := range ops {
+			if op.Op != OpEqual {
+				edits++
+			}
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("diff(%q, %q) uses %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+// 编辑距离超过 maxEditDistance 时退化为整体删除加插入，结果仍然正确
+func TestDiffFallback(t *testing.T) {
+	a := make([]string, maxEditDistance)
+	b := make([]string, maxEditDistance)
+	for i := range a {
+		a[i] = fmt.Sprintf("旧 %d", i)
+		b[i] = fmt.Sprintf("新 %d", i)
+	}
+	r := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"), 3)
+	if r.Added != len(b) || r.Removed != len(a) || len(r.Hunks) != 1 {
+		t.Errorf("added %d removed %d hunks %d", r.Added, r.Removed, len(r.Hunks))
+	}
+}
@@ -364,4 +364,106 @@ func (c *Client) GetIDsByMetadata(ctx context.Context, where map[string]interfac
 	}
 
 	return getResp.IDs, nil
-}
\ No newline at end of file
+}
+
+// GetRequest 表示按条件分页获取集合记录的请求
+type GetRequest struct {
+	IDs     []string               `json:"ids,omitempty"`
+	Where   map[string]interface{} `json:"where,omitempty"`
+	Limit   int                    `json:"limit,omitempty"`
+	Offset  int                    `json:"offset,omitempty"`
+	Include []string               `json:"include,omitempty"`
+}
+
+// GetResponse 表示来自 Chroma 的 get 响应，未包含在 Include 中的字段为空
+type GetResponse struct {
+	IDs        []string                 `json:"ids"`
+	Documents  []string                 `json:"documents"`
+	Metadatas  []map[string]interface{} `json:"metadatas"`
+	Embeddings [][]float32              `json:"embeddings"`
+}
+
+// Get 按 ID 或 metadata 条件分页获取记录，可通过 include 选择返回的字段
+func (c *Client) Get(ctx context.Context, getReq GetRequest) (*GetResponse, error) {
+	collectionID, err := c.getCollectionID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection id: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v2/tenants/default_tenant/databases/default_database/collections/%s/get", c.baseURL, collectionID)
+
+	jsonData, err := json.Marshal(getReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to get records: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	var getResp GetResponse
+	if err := json.NewDecoder(resp.Body).Decode(&getResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &getResp, nil
+}
+
+// UpdateMetadatas 只更新指定记录的元数据，不改变向量和文本。
+// Chroma 会将传入的键合并到已有元数据中
+func (c *Client) UpdateMetadatas(ctx context.Context, ids []string, metadatas []map[string]interface{}) error {
+	if len(ids) != len(metadatas) {
+		return fmt.Errorf("ids and metadatas must have the same length")
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	collectionID, err := c.getCollectionID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get collection id: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/v2/tenants/default_tenant/databases/default_database/collections/%s/update", c.baseURL, collectionID)
+	reqBody := map[string]interface{}{
+		"ids":       ids,
+		"metadatas": metadatas,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update metadatas: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to update metadatas: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
@@ -38,7 +38,7 @@ api.interceptors.response.use(
 export default api
 
 // Document APIs
-export const fetchDocuments = () => api.get('/documents')
+export const fetchDocuments = (params) => api.get('/documents', { params })
 export const createDocument = (payload) => api.post('/documents', payload)
 export const getDocument = (id) => api.get(`/documents/${id}`)
 export const deleteDocument = (id) => api.delete(`/documents/${id}`)